@@ -2,44 +2,97 @@ package main
 
 import (
 	"archive/zip"
-	"fmt"
+	"common"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"runtime"
 	"strings"
 )
 
 // Expects 1 argument:
 // 1. Working directory (the directory where this script is located).
+// Arguments can also be read from a file by specifying "@path/to/file" (one argument per line).
+//
+// Optional flags (specified before the working directory):
+// --quiet      only print warnings and errors.
+// --verbose    also print debug messages.
+// --timestamps prefix console messages with timestamps.
+// --log-file   path to the file to write all messages to (with timestamps and debug messages).
+// --sha256     expected SHA-256 of the DXC archive (checked on every run).
+// --arch       target architecture, directory in "bin" with DLLs to check ("x64" by default).
+//
+// Flags that are not specified are read from "NE_<FLAG>" environment variables (for example "NE_VERBOSE"),
+// the working directory is read from "NE_DXC_DIR" if not specified. Command line arguments
+// always take precedence over environment variables.
 func main() {
-	var args_count = len(os.Args[1:])
-	if args_count == 0 {
-		fmt.Println("ERROR: download_dxc.go: not enough arguments.")
-		os.Exit(1)
+	var quiet = flag.Bool("quiet", false, "only print warnings and errors")
+	var verbose = flag.Bool("verbose", false, "also print debug messages")
+	var timestamps = flag.Bool("timestamps", false, "prefix console messages with timestamps")
+	var log_file_path = flag.String("log-file", "", "path to the file to write all messages to")
+	var expected_sha256 = flag.String("sha256", "", "expected SHA-256 of the DXC archive")
+	var arch = flag.String("arch", "x64", "target architecture (directory in \"bin\" with DLLs to check)")
+
+	flag.CommandLine.Parse(common.Expand_response_files(os.Args[1:]))
+	common.Apply_environment_overrides()
+	common.Init_log("download_dxc.go", *quiet, *verbose, *timestamps, *log_file_path)
+	defer common.Close_log()
+
+	var working_directory = os.Getenv("NE_DXC_DIR")
+	var args = flag.Args()
+	if len(args) != 0 {
+		working_directory = args[0]
+	}
+	if working_directory == "" {
+		common.Log_fatal("not enough arguments.")
 	}
 
-	var working_directory = os.Args[1]
+	common.Log_verbose("using working directory:", working_directory)
 	var archive_url = "https://github.com/microsoft/DirectXShaderCompiler/releases/download/v1.6.2112/dxc_2021_12_08.zip"
 
-	download_dxc_build(working_directory, archive_url)
-	remove_old_dxc_build(working_directory)
-	unzip(filepath.Join(working_directory, get_archive_name(archive_url)), working_directory)
+	var archive_path = filepath.Join(working_directory, get_archive_name(archive_url))
+	var downloaded = download_dxc_build(working_directory, archive_url)
+	if *expected_sha256 != "" {
+		verify_archive_checksum(archive_path, *expected_sha256)
+	}
+
+	// Checksums and signatures are checked on every run (not only after downloading) so that
+	// replaced files are noticed.
+	var dll_directory = filepath.Join(working_directory, "bin", *arch)
+	var _, err = os.Stat(dll_directory)
+	if downloaded || err != nil {
+		remove_old_dxc_build(working_directory)
+		unzip(archive_path, working_directory)
+	}
+
+	if _, err = os.Stat(dll_directory); err != nil {
+		common.Log_fatal("DXC build has no libraries for the target architecture", *arch, "(expected directory", dll_directory+")")
+	}
+
+	if runtime.GOOS == "windows" {
+		verify_dxc_signatures(working_directory, dll_directory, archive_url)
+	}
 }
 
 func get_archive_name(archive_url string) string {
 	return archive_url[strings.LastIndex(archive_url, "/"):]
 }
 
-func download_dxc_build(working_directory string, URL string) {
+// Downloads the DXC archive if it does not exist, returns true if the archive was downloaded.
+func download_dxc_build(working_directory string, URL string) bool {
 	var filename = filepath.Join(working_directory, get_archive_name(URL))
 
 	var _, err = os.Stat(filename)
 	if err == nil {
 		// Exists.
-		fmt.Println("INFO: download_dxc.go: found DXC build", filename, " - nothing to do")
-		os.Exit(0)
+		common.Log_info("found DXC build", filename)
+		return false
 	}
 
 	// Not found. See if there are any .zip files and remove them.
@@ -54,32 +107,63 @@ func download_dxc_build(working_directory string, URL string) {
 		}
 	}
 
-	fmt.Println("INFO: download_dxc.go: downloading file", filename)
+	common.Log_info("downloading file", filename)
 
 	response, err := http.Get(URL)
 	if err != nil {
-		fmt.Println("ERROR: download_dxc.go:", err)
-		os.Exit(1)
+		common.Log_fatal(err)
 	}
 	defer response.Body.Close()
 
 	if response.StatusCode != 200 {
-		fmt.Println("ERROR: download_dxc.go: received non 200 response code, actual result:", response.StatusCode)
-		os.Exit(1)
+		common.Log_fatal("received non 200 response code, actual result:", response.StatusCode)
 	}
 
+	// Content length is -1 if unknown.
+	common.Check_free_disk_space(working_directory, response.ContentLength, "DXC archive")
+
 	file, err := os.Create(filename)
 	if err != nil {
-		fmt.Println("ERROR: download_dxc.go: failed to create empty file, error:", err)
-		os.Exit(1)
+		common.Log_fatal("failed to create empty file, error:", err)
 	}
 	defer file.Close()
 
 	_, err = io.Copy(file, response.Body)
 	if err != nil {
-		fmt.Println("ERROR: download_dxc.go: failed to copy downloaded bytes, error:", err)
-		os.Exit(1)
+		common.Log_fatal("failed to copy downloaded bytes, error:", err)
+	}
+
+	return true
+}
+
+// Checks SHA-256 of the DXC archive, if it's not the expected one removes the archive
+// (so that the next build will download it again) and exits with an error.
+func verify_archive_checksum(archive_path string, expected_sha256 string) {
+	var actual_sha256 = get_file_sha256(archive_path)
+	if !strings.EqualFold(actual_sha256, expected_sha256) {
+		common.Log_error("SHA-256 of", archive_path, "is", actual_sha256, "but expected", expected_sha256+", removing downloaded DXC build")
+		os.Remove(archive_path)
+		common.Exit_with_error()
+	}
+
+	common.Log_info("SHA-256 of", get_archive_name(archive_path), "is valid")
+}
+
+// Returns hex-encoded SHA-256 of the file.
+func get_file_sha256(file_path string) string {
+	file, err := os.Open(file_path)
+	if err != nil {
+		common.Log_fatal("failed to open", file_path, "error:", err)
+	}
+	defer file.Close()
+
+	var hasher = sha256.New()
+	_, err = io.Copy(hasher, file)
+	if err != nil {
+		common.Log_fatal("failed to read", file_path, "error:", err)
 	}
+
+	return hex.EncodeToString(hasher.Sum(nil))
 }
 
 func remove_old_dxc_build(working_directory string) {
@@ -92,49 +176,108 @@ func remove_old_dxc_build(working_directory string) {
 			// Exists.
 			err = os.RemoveAll(current_path)
 			if err != nil {
-				fmt.Println("ERROR: download_dxc.go: failed to remove old DXC build, error:", err)
-				os.Exit(1)
+				common.Log_fatal("failed to remove old DXC build, error:", err)
 			}
 		}
 	}
 
 }
 
+// Verifies Authenticode signatures of DXC DLLs in the specified directory, if some signature is not valid
+// removes the downloaded archive and extracted files (so that the next build will download them again)
+// and exits with an error. Hashes of verified DLLs are written to a stamp file in the directory
+// so that signatures are only checked again when the DLLs change.
+func verify_dxc_signatures(working_directory string, dll_directory string, archive_url string) {
+	var dlls_to_check = []string{"dxcompiler.dll", "dxil.dll"}
+	var expected_signer = "Microsoft Corporation"
+	var stamp_path = filepath.Join(dll_directory, "signatures.sha256")
+
+	var stamp = ""
+	for _, dll_name := range dlls_to_check {
+		var dll_path = filepath.Join(dll_directory, dll_name)
+
+		var _, err = os.Stat(dll_path)
+		if os.IsNotExist(err) {
+			common.Log_fatal("expected file", dll_path, "does not exist")
+		}
+
+		stamp += get_file_sha256(dll_path) + " " + dll_name + "\n"
+	}
+
+	if old_stamp, err := os.ReadFile(stamp_path); err == nil && string(old_stamp) == stamp {
+		common.Log_verbose("DXC libraries did not change since their signatures were checked, skipping signature check")
+		return
+	}
+
+	for _, dll_name := range dlls_to_check {
+		var dll_path = filepath.Join(dll_directory, dll_name)
+
+		// Ask PowerShell for signature status and signer (single quotes are escaped by doubling them).
+		var command = "$s = Get-AuthenticodeSignature -LiteralPath '" + strings.ReplaceAll(dll_path, "'", "''") + "'; " +
+			"Write-Output $s.Status; Write-Output $s.SignerCertificate.Subject"
+		output, err := exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", command).Output()
+		if err != nil {
+			common.Log_fatal("failed to check signature of", dll_path, "error:", err)
+		}
+
+		var lines = strings.Split(strings.ReplaceAll(strings.TrimSpace(string(output)), "\r\n", "\n"), "\n")
+		var status = strings.TrimSpace(lines[0])
+		var signer = ""
+		if len(lines) > 1 {
+			signer = strings.TrimSpace(lines[1])
+		}
+
+		if status != "Valid" || !strings.Contains(signer, "O="+expected_signer) {
+			common.Log_error("signature of", dll_path, "is not valid (status:", status,
+				"signer:", signer+"), removing downloaded DXC build")
+			os.Remove(filepath.Join(working_directory, get_archive_name(archive_url)))
+			remove_old_dxc_build(working_directory)
+			common.Exit_with_error()
+		}
+
+		common.Log_info("signature of", dll_name, "is valid")
+	}
+
+	common.Write_file(stamp_path, []byte(stamp))
+}
+
 func unzip(src string, dest string) {
 	r, err := zip.OpenReader(src)
 	if err != nil {
-		fmt.Println("ERROR: download_dxc.go: open zip reader, error:", err)
-		os.Exit(1)
+		common.Log_fatal("open zip reader, error:", err)
 	}
 	defer func() {
 		if err := r.Close(); err != nil {
-			fmt.Println("ERROR: download_dxc.go: error:", err)
-			os.Exit(1)
+			common.Log_fatal("error:", err)
 		}
 	}()
 
+	var uncompressed_size int64 = 0
+	for _, f := range r.File {
+		uncompressed_size += int64(f.UncompressedSize64)
+	}
+	common.Check_free_disk_space(dest, uncompressed_size, "extracted DXC build")
+
 	os.MkdirAll(dest, 0755)
 
 	// Closure to address file descriptors issue with all the deferred .Close() methods
 	extractAndWriteFile := func(f *zip.File) {
 		rc, err := f.Open()
 		if err != nil {
-			fmt.Println("ERROR: download_dxc.go: error:", err)
-			os.Exit(1)
+			common.Log_fatal("error:", err)
 		}
 		defer func() {
 			if err := rc.Close(); err != nil {
-				fmt.Println("ERROR: download_dxc.go: error:", err)
-				os.Exit(1)
+				common.Log_fatal("error:", err)
 			}
 		}()
 
 		path := filepath.Join(dest, f.Name)
+		common.Log_verbose("extracting", path)
 
 		// Check for ZipSlip (Directory traversal)
 		if !strings.HasPrefix(path, filepath.Clean(dest)+string(os.PathSeparator)) {
-			fmt.Println("ERROR: download_dxc.go: illegal file path:", path)
-			os.Exit(1)
+			common.Log_fatal("illegal file path:", path)
 		}
 
 		if f.FileInfo().IsDir() {
@@ -143,20 +286,17 @@ func unzip(src string, dest string) {
 			os.MkdirAll(filepath.Dir(path), f.Mode())
 			f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode())
 			if err != nil {
-				fmt.Println("ERROR: download_dxc.go: error:", err)
-				os.Exit(1)
+				common.Log_fatal("error:", err)
 			}
 			defer func() {
 				if err := f.Close(); err != nil {
-					fmt.Println("ERROR: download_dxc.go: error:", err)
-					os.Exit(1)
+					common.Log_fatal("error:", err)
 				}
 			}()
 
 			_, err = io.Copy(f, rc)
 			if err != nil {
-				fmt.Println("ERROR: download_dxc.go: error:", err)
-				os.Exit(1)
+				common.Log_fatal("error:", err)
 			}
 		}
 	}
@@ -1,3 +1,7 @@
 module download_dxc
 
 go 1.18
+
+require common v0.0.0
+
+replace common => ../../src/.scripts/common
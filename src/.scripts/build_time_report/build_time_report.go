@@ -0,0 +1,405 @@
+package main
+
+import (
+	"bufio"
+	"common"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Parses ".ninja_log" of the build directory (written by Ninja) and reports the slowest targets
+// (translation units, libraries, etc.) of the last build. With "--time-trace" also parses
+// Clang's `-ftime-trace` files (written next to object files) and reports headers that took
+// the most time to parse (summed over all translation units).
+//
+// The report is printed and can be written as a Markdown file. With "--trend" build times are
+// also appended to a JSON file to track them over time (for example as a CI artifact).
+//
+// Should be started from the root directory of the repository.
+//
+// Flags:
+// --build-dir  path to the Ninja build directory (required).
+// --top        (optional) number of slowest items to report, 20 by default.
+// --time-trace (optional) also parse Clang time trace files.
+// --report     (optional) path to the Markdown report to write.
+// --trend      (optional) path to the JSON file to append build times to.
+// --trend-max  (optional) number of builds to keep in the trend file, 100 by default (0 to keep all).
+// --quiet      only print warnings and errors.
+// --verbose    also print debug messages.
+//
+// Flags that are not specified are read from "NE_<FLAG>" environment variables (for example "NE_BUILD_DIR").
+// Command line arguments always take precedence over environment variables.
+func main() {
+	var build_directory = flag.String("build-dir", "", "path to the Ninja build directory")
+	var top = flag.Int("top", 20, "(optional) number of slowest items to report")
+	var time_trace = flag.Bool("time-trace", false, "(optional) also parse Clang time trace files")
+	var report_path = flag.String("report", "", "(optional) path to the Markdown report to write")
+	var trend_path = flag.String("trend", "", "(optional) path to the JSON file to append build times to")
+	var trend_max = flag.Int("trend-max", 100, "(optional) number of builds to keep in the trend file")
+	var quiet = flag.Bool("quiet", false, "only print warnings and errors")
+	var verbose = flag.Bool("verbose", false, "also print debug messages")
+
+	flag.Parse()
+	common.Apply_environment_overrides()
+	common.Init_log("build_time_report.go", *quiet, *verbose, false, "")
+
+	if *build_directory == "" {
+		common.Log_fatal("\"--build-dir\" is required")
+	}
+	if *top < 1 {
+		*top = 1
+	}
+
+	var edges = read_ninja_log(filepath.Join(*build_directory, ".ninja_log"))
+	if len(edges) == 0 {
+		common.Log_fatal("no build steps were found in", filepath.Join(*build_directory, ".ninja_log"))
+	}
+
+	var summary = summarize_build(edges)
+	common.Log_info(fmt.Sprintf("last build: %d step(s), %s wall time, %s total time", len(edges),
+		format_duration(summary.wall_ms), format_duration(summary.total_ms)))
+
+	var report = []report_table{
+		get_slowest_edges_table(edges, *top),
+		get_targets_table(edges, *top),
+	}
+	if *time_trace {
+		report = append(report, get_headers_table(*build_directory, *top))
+	}
+
+	for _, table := range report {
+		table.print()
+	}
+
+	if *report_path != "" {
+		write_markdown_report(*report_path, summary, report)
+		common.Log_info("report was written to", *report_path)
+	}
+
+	if *trend_path != "" {
+		append_trend(*trend_path, *trend_max, summary, edges)
+		common.Log_info("build times were added to", *trend_path)
+	}
+}
+
+// A build step from ".ninja_log".
+type ninja_edge struct {
+	output   string
+	start_ms int64
+	end_ms   int64
+}
+
+func (e ninja_edge) duration_ms() int64 {
+	return e.end_ms - e.start_ms
+}
+
+// Reads steps of the last build from ".ninja_log" (version 5 or newer).
+func read_ninja_log(path string) []ninja_edge {
+	file, err := os.Open(path)
+	if err != nil {
+		common.Log_fatal("failed to open", path, "(only Ninja builds are supported), error:", err)
+	}
+	defer file.Close()
+
+	var edges []ninja_edge
+	var indices = map[string]int{}
+	var last_end_ms int64 = 0
+	var scanner = bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var line = scanner.Text()
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// start, end, mtime, output, command hash
+		var fields = strings.Split(line, "\t")
+		if len(fields) < 5 {
+			continue
+		}
+		start_ms, err1 := strconv.ParseInt(fields[0], 10, 64)
+		end_ms, err2 := strconv.ParseInt(fields[1], 10, 64)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+
+		// Times are relative to the start of the build so a smaller end time means a new build.
+		if end_ms < last_end_ms {
+			edges = nil
+			indices = map[string]int{}
+		}
+		last_end_ms = end_ms
+
+		var edge = ninja_edge{output: fields[3], start_ms: start_ms, end_ms: end_ms}
+		if index, ok := indices[edge.output]; ok {
+			edges[index] = edge
+		} else {
+			indices[edge.output] = len(edges)
+			edges = append(edges, edge)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		common.Log_fatal("failed to read", path, "error:", err)
+	}
+
+	return edges
+}
+
+// Total times of a build.
+type build_summary struct {
+	wall_ms  int64 // from the start of the first step to the end of the last step
+	total_ms int64 // sum of durations of all steps
+}
+
+func summarize_build(edges []ninja_edge) build_summary {
+	var summary build_summary
+	var first_start_ms = edges[0].start_ms
+	var last_end_ms int64 = 0
+	for _, edge := range edges {
+		if edge.start_ms < first_start_ms {
+			first_start_ms = edge.start_ms
+		}
+		if edge.end_ms > last_end_ms {
+			last_end_ms = edge.end_ms
+		}
+		summary.total_ms += edge.duration_ms()
+	}
+	summary.wall_ms = last_end_ms - first_start_ms
+	return summary
+}
+
+// Matches outputs such as "src/engine_lib/CMakeFiles/engine_lib.dir/private/game/Game.cpp.o".
+var object_regexp = regexp.MustCompile(`^(?:(.*)/)?CMakeFiles/([^/]+)\.dir/(.+?)\.(?:o|obj)$`)
+
+// Returns the name of the CMake target and the source file of an object file output
+// (empty strings if the output is not an object file).
+func parse_object_output(output string) (string, string) {
+	var match = object_regexp.FindStringSubmatch(filepath.ToSlash(output))
+	if match == nil {
+		return "", ""
+	}
+	return match[2], match[3]
+}
+
+// A table of the report.
+type report_table struct {
+	title   string
+	columns []string
+	rows    [][]string
+}
+
+func (table report_table) print() {
+	common.Log_info(table.title + ":")
+	for _, row := range table.rows {
+		common.Log_info("    " + strings.Join(row, "  "))
+	}
+}
+
+func get_slowest_edges_table(edges []ninja_edge, top int) report_table {
+	var sorted_edges = append([]ninja_edge{}, edges...)
+	sort.Slice(sorted_edges, func(i, j int) bool {
+		return sorted_edges[i].duration_ms() > sorted_edges[j].duration_ms()
+	})
+	if len(sorted_edges) > top {
+		sorted_edges = sorted_edges[:top]
+	}
+
+	var table = report_table{title: "slowest build steps", columns: []string{"time", "target", "output"}}
+	for _, edge := range sorted_edges {
+		var target, source = parse_object_output(edge.output)
+		var name = edge.output
+		if source != "" {
+			name = source
+		}
+		table.rows = append(table.rows, []string{format_duration(edge.duration_ms()), target, name})
+	}
+	return table
+}
+
+// Returns total compilation time per CMake target.
+func get_targets_table(edges []ninja_edge, top int) report_table {
+	var times = map[string]int64{}
+	var counts = map[string]int{}
+	for _, edge := range edges {
+		var target, _ = parse_object_output(edge.output)
+		if target != "" {
+			times[target] += edge.duration_ms()
+			counts[target] += 1
+		}
+	}
+
+	var targets []string
+	for target := range times {
+		targets = append(targets, target)
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		return times[targets[i]] > times[targets[j]]
+	})
+	if len(targets) > top {
+		targets = targets[:top]
+	}
+
+	var table = report_table{title: "compilation time per target", columns: []string{"time", "target", "translation units"}}
+	for _, target := range targets {
+		table.rows = append(table.rows, []string{format_duration(times[target]), target, strconv.Itoa(counts[target])})
+	}
+	return table
+}
+
+// Clang time trace (Chrome trace event format).
+type time_trace struct {
+	TraceEvents []struct {
+		Name     string `json:"name"`
+		Phase    string `json:"ph"`
+		Duration int64  `json:"dur"` // microseconds
+		Args     struct {
+			Detail string `json:"detail"`
+		} `json:"args"`
+	} `json:"traceEvents"`
+}
+
+// Returns headers that took the most time to parse (time includes headers that they include).
+func get_headers_table(build_directory string, top int) report_table {
+	var times = map[string]int64{}
+	var counts = map[string]int{}
+	var trace_count = 0
+	filepath.Walk(build_directory, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || filepath.Ext(path) != ".json" ||
+			!strings.Contains(filepath.ToSlash(path), "/CMakeFiles/") {
+			return nil
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil || !strings.Contains(string(content), "\"traceEvents\"") {
+			return nil
+		}
+
+		var trace time_trace
+		if err := json.Unmarshal(content, &trace); err != nil {
+			common.Log_verbose("failed to parse", path, "error:", err)
+			return nil
+		}
+		trace_count += 1
+
+		// Each header is counted once per translation unit.
+		var seen_headers = map[string]bool{}
+		for _, event := range trace.TraceEvents {
+			if event.Name != "Source" || event.Phase != "X" || event.Args.Detail == "" {
+				continue
+			}
+			var header = common.Get_relative_path(event.Args.Detail)
+			times[header] += event.Duration / 1000
+			if !seen_headers[header] {
+				seen_headers[header] = true
+				counts[header] += 1
+			}
+		}
+		return nil
+	})
+
+	if trace_count == 0 {
+		common.Log_warning("no time trace files were found in", build_directory, "(compile with `-ftime-trace` using Clang)")
+	} else {
+		common.Log_verbose("parsed", trace_count, "time trace file(s)")
+	}
+
+	var headers []string
+	for header := range times {
+		headers = append(headers, header)
+	}
+	sort.Slice(headers, func(i, j int) bool {
+		return times[headers[i]] > times[headers[j]]
+	})
+	if len(headers) > top {
+		headers = headers[:top]
+	}
+
+	var table = report_table{title: "slowest headers", columns: []string{"time", "header", "included by"}}
+	for _, header := range headers {
+		table.rows = append(table.rows, []string{format_duration(times[header]), header,
+			fmt.Sprintf("%d translation unit(s)", counts[header])})
+	}
+	return table
+}
+
+func format_duration(milliseconds int64) string {
+	return (time.Duration(milliseconds) * time.Millisecond).Round(10 * time.Millisecond).String()
+}
+
+func write_markdown_report(path string, summary build_summary, tables []report_table) {
+	var content strings.Builder
+	fmt.Fprintf(&content, "# Build time report\n\nWall time: %s, total time of all steps: %s.\n",
+		format_duration(summary.wall_ms), format_duration(summary.total_ms))
+
+	for _, table := range tables {
+		fmt.Fprintf(&content, "\n## %s\n\n", strings.ToUpper(table.title[:1])+table.title[1:])
+		fmt.Fprintf(&content, "| %s |\n", strings.Join(table.columns, " | "))
+		fmt.Fprintf(&content, "|%s\n", strings.Repeat(" --- |", len(table.columns)))
+		for _, row := range table.rows {
+			fmt.Fprintf(&content, "| %s |\n", strings.Join(row, " | "))
+		}
+	}
+
+	common.Write_file(path, []byte(content.String()))
+}
+
+// Build times of a single build in the trend file.
+type trend_entry struct {
+	Timestamp string           `json:"timestamp"`
+	Commit    string           `json:"commit,omitempty"`
+	WallMs    int64            `json:"wall_ms"`
+	TotalMs   int64            `json:"total_ms"`
+	Targets   map[string]int64 `json:"targets"` // compilation time per CMake target
+}
+
+func append_trend(path string, max_entries int, summary build_summary, edges []ninja_edge) {
+	var trend []trend_entry
+	content, err := os.ReadFile(path)
+	if err == nil {
+		err = json.Unmarshal(content, &trend)
+		if err != nil {
+			common.Log_fatal("failed to parse", path, "error:", err)
+		}
+	} else if !os.IsNotExist(err) {
+		common.Log_fatal("failed to read", path, "error:", err)
+	}
+
+	var entry = trend_entry{Timestamp: time.Now().UTC().Format(time.RFC3339), WallMs: summary.wall_ms,
+		TotalMs: summary.total_ms, Targets: map[string]int64{}}
+	if output, err := exec.Command("git", "rev-parse", "HEAD").Output(); err == nil {
+		entry.Commit = strings.TrimSpace(string(output))
+	}
+	for _, edge := range edges {
+		if target, _ := parse_object_output(edge.output); target != "" {
+			entry.Targets[target] += edge.duration_ms()
+		}
+	}
+
+	if len(trend) != 0 {
+		var previous = trend[len(trend)-1]
+		if previous.WallMs > 0 {
+			common.Log_info(fmt.Sprintf("wall time changed by %+.1f%% since the previous build",
+				float64(summary.wall_ms-previous.WallMs)*100/float64(previous.WallMs)))
+		}
+	}
+
+	trend = append(trend, entry)
+	if max_entries > 0 && len(trend) > max_entries {
+		trend = trend[len(trend)-max_entries:]
+	}
+
+	content, err = json.MarshalIndent(trend, "", "  ")
+	if err != nil {
+		common.Log_fatal("failed to serialize build times, error:", err)
+	}
+	common.Write_file(path, append(content, '\n'))
+}
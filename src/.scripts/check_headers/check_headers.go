@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bytes"
+	"common"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Maximum number of output lines of the compiler to print for a header that does not compile.
+const compiler_output_line_count = 10
+
+// Makes sure that every header in the header directory compiles on its own: for each header
+// a translation unit that only includes the header (`#include "game/Window.h"`) is compiled
+// (syntax check only) and headers that don't compile are reported.
+//
+// If "--build-dir" is specified the compiler and its flags (include directories, defines, C++ standard)
+// are taken from the compile_commands.json entry of a source file from the source directory
+// (generated by CMake with `-DCMAKE_EXPORT_COMPILE_COMMANDS=ON`), otherwise "--compiler" is used
+// with "--include" directories.
+//
+// Should be started from the root directory of the repository.
+//
+// Flags:
+// --header-dir  (optional) directory with headers to check (paths relative to it are included), "src/engine_lib/public" by default.
+// --source-dir  (optional) directory of the source files to take flags from, "src/engine_lib" by default.
+// --build-dir   (optional) path to the directory with compile_commands.json.
+// --compiler    (optional) compiler to use without "--build-dir", "c++" by default.
+// --include     (optional) comma-separated include directories (used without "--build-dir").
+// --jobs        (optional) number of headers to check in parallel, number of CPUs by default.
+// --quiet       only print warnings and errors.
+// --verbose     also print debug messages.
+//
+// Flags that are not specified are read from "NE_<FLAG>" environment variables (for example "NE_BUILD_DIR").
+// Command line arguments always take precedence over environment variables.
+func main() {
+	var header_directory = flag.String("header-dir", filepath.Join("src", "engine_lib", "public"), "(optional) directory with headers to check")
+	var source_directory = flag.String("source-dir", filepath.Join("src", "engine_lib"), "(optional) directory of the source files to take flags from")
+	var build_directory = flag.String("build-dir", "", "(optional) path to the directory with compile_commands.json")
+	var compiler = flag.String("compiler", "c++", "(optional) compiler to use without \"--build-dir\"")
+	var include = flag.String("include", "ext,src/engine_lib/public,src/engine_lib/private",
+		"(optional) comma-separated include directories (used without \"--build-dir\")")
+	var jobs = flag.Int("jobs", runtime.NumCPU(), "(optional) number of headers to check in parallel")
+	var quiet = flag.Bool("quiet", false, "only print warnings and errors")
+	var verbose = flag.Bool("verbose", false, "also print debug messages")
+
+	flag.Parse()
+	common.Apply_environment_overrides()
+	common.Init_log("check_headers.go", *quiet, *verbose, false, "")
+
+	if *jobs < 1 {
+		*jobs = 1
+	}
+
+	absolute_header_directory, err := filepath.Abs(*header_directory)
+	if err != nil {
+		common.Log_fatal("failed to get absolute path of", *header_directory, "error:", err)
+	}
+
+	var command []string
+	if *build_directory != "" {
+		command = get_compile_command(*build_directory, *source_directory)
+	} else {
+		command = []string{*compiler, "-std=c++2b"}
+		for _, directory := range strings.Split(*include, ",") {
+			if directory = strings.TrimSpace(directory); directory != "" {
+				absolute_directory, _ := filepath.Abs(directory)
+				command = append(command, "-I"+absolute_directory)
+			}
+		}
+	}
+	var is_msvc = is_msvc_compiler(command[0])
+	if is_msvc {
+		command = append(command, "/I"+absolute_header_directory, "/Zs", "/TP")
+	} else {
+		command = append(command, "-I"+absolute_header_directory, "-fsyntax-only", "-x", "c++")
+	}
+	common.Log_verbose("using command:", strings.Join(command, " "))
+
+	var headers = find_headers(absolute_header_directory)
+	if len(headers) == 0 {
+		common.Log_fatal("no headers were found in", *header_directory)
+	}
+
+	temp_directory, err := os.MkdirTemp("", "check_headers")
+	if err != nil {
+		common.Log_fatal("failed to create temporary directory, error:", err)
+	}
+	defer os.RemoveAll(temp_directory)
+
+	common.Log_info("checking", len(headers), "header(s) using", *jobs, "job(s)")
+
+	var failed_headers = check_headers(command, temp_directory, headers, *jobs)
+	if len(failed_headers) != 0 {
+		os.RemoveAll(temp_directory)
+		common.Log_fatal(len(failed_headers), "header(s) don't compile on their own:\n  "+strings.Join(failed_headers, "\n  "))
+	}
+
+	common.Log_info("all headers compile on their own")
+}
+
+// An entry of compile_commands.json.
+type compile_command struct {
+	Directory string   `json:"directory"`
+	File      string   `json:"file"`
+	Command   string   `json:"command"`
+	Arguments []string `json:"arguments"`
+}
+
+// Returns the compiler and flags (with absolute include paths, without the source file
+// and output arguments) of a source file from the source directory.
+func get_compile_command(build_directory string, source_directory string) []string {
+	var database_path = filepath.Join(build_directory, "compile_commands.json")
+	content, err := os.ReadFile(database_path)
+	if err != nil {
+		common.Log_fatal("failed to read", database_path, "(configure CMake with -DCMAKE_EXPORT_COMPILE_COMMANDS=ON) error:", err)
+	}
+
+	var commands []compile_command
+	err = json.Unmarshal(content, &commands)
+	if err != nil {
+		common.Log_fatal("failed to parse", database_path, "error:", err)
+	}
+
+	source_directory, _ = filepath.Abs(source_directory)
+	for _, command := range commands {
+		var file = command.File
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(command.Directory, file)
+		}
+		if !strings.HasPrefix(filepath.Clean(file), source_directory+string(os.PathSeparator)) ||
+			!strings.HasSuffix(file, ".cpp") {
+			continue
+		}
+
+		var args = command.Arguments
+		if len(args) == 0 {
+			args = split_command_line(command.Command)
+		}
+		if len(args) == 0 {
+			continue
+		}
+		common.Log_verbose("using flags of", file)
+
+		return filter_compile_arguments(args, command.Directory, command.File)
+	}
+
+	common.Log_fatal("no source files from", source_directory, "were found in", database_path)
+	return nil
+}
+
+// Removes the source file, output and precompiled header arguments and makes include paths absolute.
+func filter_compile_arguments(args []string, directory string, file string) []string {
+	var result = []string{args[0]}
+	for i := 1; i < len(args); i++ {
+		var arg = args[i]
+		switch {
+		case arg == file || filepath.Join(directory, arg) == filepath.Join(directory, file):
+		case arg == "-c" || arg == "/c":
+		case arg == "-o" || arg == "-MF" || arg == "-MT" || arg == "-MQ":
+			i += 1
+		case strings.HasPrefix(arg, "-o") || strings.HasPrefix(arg, "/Fo") || strings.HasPrefix(arg, "-Fo") ||
+			strings.HasPrefix(arg, "/Fd") || strings.HasPrefix(arg, "/Yu") || strings.HasPrefix(arg, "/FI") ||
+			arg == "-MD" && !is_msvc_compiler(args[0]) || arg == "-MMD":
+		case arg == "-include" || arg == "-I" || arg == "-isystem":
+			if i+1 < len(args) {
+				if arg == "-include" {
+					// Precompiled headers would hide missing includes.
+					i += 1
+					continue
+				}
+				result = append(result, arg, make_absolute(args[i+1], directory))
+				i += 1
+			}
+		case strings.HasPrefix(arg, "-I") || strings.HasPrefix(arg, "/I"):
+			result = append(result, arg[:2]+make_absolute(arg[2:], directory))
+		case strings.HasPrefix(arg, "-isystem"):
+			result = append(result, "-isystem"+make_absolute(arg[len("-isystem"):], directory))
+		default:
+			result = append(result, arg)
+		}
+	}
+	return result
+}
+
+func make_absolute(path string, directory string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(directory, path)
+}
+
+// Splits the command line into arguments (supports double quotes and backslash escapes).
+func split_command_line(command string) []string {
+	var args []string
+	var current strings.Builder
+	var in_quotes = false
+	var has_arg = false
+	for i := 0; i < len(command); i++ {
+		var char = command[i]
+		switch {
+		case char == '\\' && i+1 < len(command) && (command[i+1] == '"' || command[i+1] == '\\'):
+			current.WriteByte(command[i+1])
+			has_arg = true
+			i += 1
+		case char == '"':
+			in_quotes = !in_quotes
+			has_arg = true
+		case (char == ' ' || char == '\t') && !in_quotes:
+			if has_arg {
+				args = append(args, current.String())
+				current.Reset()
+				has_arg = false
+			}
+		default:
+			current.WriteByte(char)
+			has_arg = true
+		}
+	}
+	if has_arg {
+		args = append(args, current.String())
+	}
+	return args
+}
+
+func is_msvc_compiler(compiler string) bool {
+	var name = strings.ToLower(filepath.Base(compiler))
+	return name == "cl.exe" || name == "cl" || name == "clang-cl.exe" || name == "clang-cl"
+}
+
+// Returns paths (relative to the header directory, with '/' as separator) of all headers.
+func find_headers(header_directory string) []string {
+	var headers []string
+	var err = filepath.Walk(header_directory, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		var extension = filepath.Ext(path)
+		if info.IsDir() || (extension != ".h" && extension != ".hpp") {
+			return nil
+		}
+
+		relative_path, err := filepath.Rel(header_directory, path)
+		if err != nil {
+			return err
+		}
+		headers = append(headers, filepath.ToSlash(relative_path))
+		return nil
+	})
+	if err != nil {
+		common.Log_fatal("failed to read directory", header_directory, "error:", err)
+	}
+
+	sort.Strings(headers)
+	return headers
+}
+
+// Compiles a translation unit for each header in parallel, returns headers that failed to compile.
+func check_headers(command []string, temp_directory string, headers []string, jobs int) []string {
+	var queue = make(chan int)
+	var mutex sync.Mutex
+	var failed_headers []string
+
+	var wait_group sync.WaitGroup
+	for i := 0; i < jobs; i++ {
+		wait_group.Add(1)
+		go func() {
+			defer wait_group.Done()
+			for index := range queue {
+				var header = headers[index]
+				var source_path = filepath.Join(temp_directory, fmt.Sprintf("header_%d.cpp", index))
+				var err = os.WriteFile(source_path, []byte("#include \""+header+"\"\n"), 0644)
+				if err != nil {
+					common.Log_fatal("failed to write", source_path, "error:", err)
+				}
+
+				var output bytes.Buffer
+				var compiler = exec.Command(command[0], append(append([]string{}, command[1:]...), source_path)...)
+				compiler.Dir = temp_directory
+				compiler.Stdout = &output
+				compiler.Stderr = &output
+				err = compiler.Run()
+
+				mutex.Lock()
+				if err != nil {
+					common.Log_error(header, "does not compile on its own:")
+					var lines = strings.Split(strings.TrimSpace(output.String()), "\n")
+					if len(lines) > compiler_output_line_count {
+						lines = lines[:compiler_output_line_count]
+					}
+					for _, line := range lines {
+						common.Log_error("    " + strings.TrimRight(line, "\r"))
+					}
+					failed_headers = append(failed_headers, header)
+				} else {
+					common.Log_verbose(header, "compiles")
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	for i := range headers {
+		queue <- i
+	}
+	close(queue)
+	wait_group.Wait()
+
+	sort.Strings(failed_headers)
+	return failed_headers
+}
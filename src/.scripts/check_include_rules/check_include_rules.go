@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bufio"
+	"common"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Layering rules that are used if the rules file does not exist.
+var default_include_rules = []include_rule{
+	{
+		Name:  "engine does not depend on the editor",
+		Files: []string{"src/engine_lib/**"},
+		Deny:  []string{"src/engine_editor/**"},
+	},
+}
+
+// Parses `#include` directives of source files and makes sure that they don't break layering rules
+// (for example the engine must not include editor or game headers). Includes are resolved relative to the
+// including file and to include directories, rules are matched against paths relative to the working
+// directory (or against the include as written if it was not resolved, for example "windows.h").
+//
+// Rules file (JSON):
+//
+//	{
+//	    "rules": [
+//	        {
+//	            "name": "render backends don't include each other",
+//	            "files": ["src/engine_lib/private/render/directx/**"],
+//	            "deny": ["src/engine_lib/private/render/vulkan/**", "vulkan/*.h"],
+//	            "allow": ["src/engine_lib/private/render/vulkan/VulkanTypes.h"]
+//	        }
+//	    ]
+//	}
+//
+// "*" matches any characters except '/' and "**" matches any number of directories.
+//
+// Should be started from the root directory of the repository.
+//
+// Flags:
+// --rules      (optional) path to the rules file, "include_rules.json" by default (built-in rules are used if it does not exist).
+// --source-dir (optional) directory with source files to check, "src" by default.
+// --include    (optional) comma-separated include directories.
+// --quiet      only print warnings and errors.
+// --verbose    also print debug messages.
+//
+// Flags that are not specified are read from "NE_<FLAG>" environment variables (for example "NE_RULES").
+// Command line arguments always take precedence over environment variables.
+func main() {
+	var rules_path = flag.String("rules", "include_rules.json", "(optional) path to the rules file")
+	var source_directory = flag.String("source-dir", "src", "(optional) directory with source files to check")
+	var include = flag.String("include", "src/engine_lib/public,src/engine_lib/private,src/engine_editor,ext",
+		"(optional) comma-separated include directories")
+	var quiet = flag.Bool("quiet", false, "only print warnings and errors")
+	var verbose = flag.Bool("verbose", false, "also print debug messages")
+
+	flag.Parse()
+	common.Apply_environment_overrides()
+	common.Init_log("check_include_rules.go", *quiet, *verbose, false, "")
+
+	var rules = read_include_rules(*rules_path)
+
+	var include_directories []string
+	for _, directory := range strings.Split(*include, ",") {
+		if directory = strings.TrimSpace(directory); directory != "" {
+			include_directories = append(include_directories, filepath.ToSlash(filepath.Clean(directory)))
+		}
+	}
+
+	var files = common.Find_source_files(*source_directory, nil)
+	common.Log_info("checking includes of", len(files), "file(s) using", len(rules), "rule(s)")
+
+	var violations []string
+	for _, file := range files {
+		for _, directive := range parse_includes(file) {
+			var target = resolve_include(file, directive, include_directories)
+			for _, rule := range rules {
+				if rule.is_violated_by(file, target) {
+					violations = append(violations, fmt.Sprintf("%s:%d: includes \"%s\" (%s)",
+						file, directive.line, directive.path, rule.Name))
+				}
+			}
+		}
+	}
+
+	if len(violations) != 0 {
+		for _, violation := range violations {
+			common.Log_error(violation)
+		}
+		common.Log_fatal("found", len(violations), "include(s) that break layering rules")
+	}
+
+	common.Log_info("no include rules are broken")
+}
+
+// A layering rule: files that match "files" can't include files that match "deny" (unless they match "allow").
+type include_rule struct {
+	Name  string   `json:"name"`
+	Files []string `json:"files"`
+	Deny  []string `json:"deny"`
+	Allow []string `json:"allow"`
+}
+
+// Tells if the file breaks the rule by including the target (path or include as written).
+func (rule include_rule) is_violated_by(file string, target string) bool {
+	return matches_any(rule.Files, file) && matches_any(rule.Deny, target) && !matches_any(rule.Allow, target)
+}
+
+// Reads rules from the file, returns default rules if the file does not exist.
+func read_include_rules(rules_path string) []include_rule {
+	content, err := os.ReadFile(rules_path)
+	if os.IsNotExist(err) {
+		common.Log_verbose("rules file", rules_path, "does not exist, using default rules")
+		return default_include_rules
+	}
+	if err != nil {
+		common.Log_fatal("failed to read", rules_path, "error:", err)
+	}
+
+	var config struct {
+		Rules []include_rule `json:"rules"`
+	}
+	err = json.Unmarshal(content, &config)
+	if err != nil {
+		common.Log_fatal("failed to parse", rules_path, "error:", err)
+	}
+
+	for i, rule := range config.Rules {
+		if len(rule.Files) == 0 || len(rule.Deny) == 0 {
+			common.Log_fatal("rule", i, "in", rules_path, "needs \"files\" and \"deny\"")
+		}
+		if rule.Name == "" {
+			config.Rules[i].Name = fmt.Sprint("rule ", i)
+		}
+		for _, pattern := range append(append(append([]string{}, rule.Files...), rule.Deny...), rule.Allow...) {
+			if _, err := path.Match(strings.ReplaceAll(pattern, "**", "*"), ""); err != nil {
+				common.Log_fatal("invalid pattern", "\""+pattern+"\"", "in", rules_path, "error:", err)
+			}
+		}
+	}
+
+	return config.Rules
+}
+
+// An `#include` directive.
+type include_directive struct {
+	path      string
+	line      int
+	is_system bool // uses angle brackets
+}
+
+var include_regexp = regexp.MustCompile(`^\s*#\s*include\s*([<"])([^>"]+)[>"]`)
+
+// Returns `#include` directives of the file.
+func parse_includes(file string) []include_directive {
+	source, err := os.Open(file)
+	if err != nil {
+		common.Log_fatal("failed to open", file, "error:", err)
+	}
+	defer source.Close()
+
+	var directives []include_directive
+	var scanner = bufio.NewScanner(source)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	var line = 0
+	for scanner.Scan() {
+		line += 1
+		if match := include_regexp.FindStringSubmatch(scanner.Text()); match != nil {
+			directives = append(directives, include_directive{path: match[2], line: line, is_system: match[1] == "<"})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		common.Log_fatal("failed to read", file, "error:", err)
+	}
+
+	return directives
+}
+
+// Returns path (relative to the working directory) of the included file or the include
+// as written if the file was not found.
+func resolve_include(file string, directive include_directive, include_directories []string) string {
+	var candidates []string
+	if !directive.is_system {
+		candidates = append(candidates, path.Join(path.Dir(file), directive.path))
+	}
+	for _, directory := range include_directories {
+		candidates = append(candidates, path.Join(directory, directive.path))
+	}
+
+	for _, candidate := range candidates {
+		if info, err := os.Stat(filepath.FromSlash(candidate)); err == nil && !info.IsDir() {
+			return candidate
+		}
+	}
+
+	common.Log_verbose(file+":", "include", "\""+directive.path+"\"", "was not resolved")
+	return directive.path
+}
+
+// Tells if the path matches one of the patterns.
+func matches_any(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if match_glob(strings.Split(pattern, "/"), strings.Split(value, "/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// Matches path segments against pattern segments ("**" matches any number of segments).
+func match_glob(pattern []string, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if match_glob(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(segments) == 0 {
+		return false
+	}
+	if matched, _ := path.Match(pattern[0], segments[0]); !matched {
+		return false
+	}
+	return match_glob(pattern[1:], segments[1:])
+}
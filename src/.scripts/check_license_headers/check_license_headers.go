@@ -0,0 +1,168 @@
+package main
+
+import (
+	"common"
+	"flag"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// UTF-8 byte order mark that some source files start with.
+const utf8_bom = "\xef\xbb\xbf"
+
+// Makes sure that every source file (C++ and shaders) starts with the license header,
+// with "--fix" inserts the header into files that don't have it.
+//
+// The header template is a text file where each line becomes a `//` comment and "{year}"
+// matches any year (or range of years, the current year is used when inserting the header).
+// If the template file does not exist the header is created from the copyright line of the LICENSE file:
+//
+//	// Copyright (c) {year} <holder>
+//	// Licensed under The MIT License (MIT), see the LICENSE file.
+//
+// Should be started from the root directory of the repository.
+//
+// Flags:
+// --dirs     (optional) comma-separated directories to check, "src,res/engine/shaders" by default.
+// --template (optional) path to the header template, ".license_header.txt" by default.
+// --exclude  (optional) comma-separated globs of files/directories to not check (patterns with '/' match relative paths).
+// --fix      (optional) insert the header into files that don't have it.
+// --quiet    only print warnings and errors.
+// --verbose  also print debug messages.
+//
+// Flags that are not specified are read from "NE_<FLAG>" environment variables (for example "NE_FIX").
+// Command line arguments always take precedence over environment variables.
+func main() {
+	var directories = flag.String("dirs", "src,res/engine/shaders", "(optional) comma-separated directories to check")
+	var template_path = flag.String("template", ".license_header.txt", "(optional) path to the header template")
+	var exclude = flag.String("exclude", "", "(optional) comma-separated globs of files/directories to not check")
+	var fix = flag.Bool("fix", false, "(optional) insert the header into files that don't have it")
+	var quiet = flag.Bool("quiet", false, "only print warnings and errors")
+	var verbose = flag.Bool("verbose", false, "also print debug messages")
+
+	flag.Parse()
+	common.Apply_environment_overrides()
+	common.Init_log("check_license_headers.go", *quiet, *verbose, false, "")
+
+	var header_lines = read_header_template(*template_path)
+	var header_regexp = get_header_regexp(header_lines)
+
+	var excludes = split_list(*exclude)
+	var files []string
+	for _, directory := range split_list(*directories) {
+		files = append(files, common.Find_source_files(directory, excludes)...)
+	}
+
+	common.Log_info("checking license headers of", len(files), "file(s)")
+
+	var missing_files []string
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			common.Log_fatal("failed to read", file, "error:", err)
+		}
+
+		var text = strings.TrimPrefix(string(content), utf8_bom)
+		if header_regexp.MatchString(text) {
+			continue
+		}
+
+		if !*fix {
+			missing_files = append(missing_files, file)
+			continue
+		}
+
+		var header = format_header(header_lines, strconv.Itoa(time.Now().Year()), get_line_ending(text))
+		var new_content = header + get_line_ending(text) + text
+		if strings.HasPrefix(string(content), utf8_bom) {
+			new_content = utf8_bom + new_content
+		}
+		err = os.WriteFile(file, []byte(new_content), 0644)
+		if err != nil {
+			common.Log_fatal("failed to write", file, "error:", err)
+		}
+		common.Log_info("added license header to", file)
+	}
+
+	if len(missing_files) != 0 {
+		for _, file := range missing_files {
+			common.Log_error(file, "does not start with the license header")
+		}
+		common.Log_fatal(len(missing_files), "file(s) don't have the license header, use \"--fix\" to add it")
+	}
+
+	common.Log_info("all files have the license header")
+}
+
+func split_list(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Returns lines of the header template (without comment markers).
+func read_header_template(template_path string) []string {
+	content, err := os.ReadFile(template_path)
+	if err == nil {
+		var text = strings.TrimSpace(strings.ReplaceAll(string(content), "\r\n", "\n"))
+		if text == "" {
+			common.Log_fatal("header template", template_path, "is empty")
+		}
+		return strings.Split(text, "\n")
+	}
+	if !os.IsNotExist(err) {
+		common.Log_fatal("failed to read", template_path, "error:", err)
+	}
+
+	common.Log_verbose("header template", template_path, "does not exist, using the LICENSE file")
+
+	license, err := os.ReadFile("LICENSE")
+	if err != nil {
+		common.Log_fatal("neither", template_path, "nor LICENSE file exist, error:", err)
+	}
+
+	var license_lines = strings.Split(strings.ReplaceAll(string(license), "\r\n", "\n"), "\n")
+	var copyright_regexp = regexp.MustCompile(`^Copyright \(c\) \d{4}(-\d{4})? (.+)$`)
+	for _, line := range license_lines {
+		if match := copyright_regexp.FindStringSubmatch(strings.TrimSpace(line)); match != nil {
+			return []string{
+				"Copyright (c) {year} " + match[2],
+				"Licensed under " + strings.TrimSpace(license_lines[0]) + ", see the LICENSE file.",
+			}
+		}
+	}
+
+	common.Log_fatal("LICENSE file has no copyright line, create", template_path)
+	return nil
+}
+
+// Returns a regexp that matches text that starts with the header.
+func get_header_regexp(header_lines []string) *regexp.Regexp {
+	var pattern = regexp.QuoteMeta(format_header(header_lines, "{year}", "\n"))
+	pattern = strings.ReplaceAll(pattern, `\{year\}`, `\d{4}(-\d{4})?`)
+	pattern = strings.ReplaceAll(pattern, "\n", `\r?\n`)
+	return regexp.MustCompile(`^` + pattern)
+}
+
+func format_header(header_lines []string, year string, line_ending string) string {
+	var header strings.Builder
+	for _, line := range header_lines {
+		header.WriteString(strings.TrimRight("// "+strings.ReplaceAll(line, "{year}", year), " ") + line_ending)
+	}
+	return header.String()
+}
+
+// Returns "\r\n" if the text uses Windows line endings and "\n" otherwise.
+func get_line_ending(text string) string {
+	if strings.Contains(text, "\r\n") {
+		return "\r\n"
+	}
+	return "\n"
+}
@@ -0,0 +1,410 @@
+package main
+
+import (
+	"common"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// GLSL stages by shader types ("vs", "ps" or "cs").
+var glsl_stages = map[string]string{"vs": "vert", "ps": "frag", "cs": "comp"}
+
+// Shader types by GLSL file extensions.
+var glsl_shader_types = map[string]string{".vert": "vs", ".frag": "ps", ".comp": "cs"}
+
+// Matches entry functions of HLSL shaders ("VertexOut vsDefault(VertexIn vertexIn)"),
+// entry functions should start with "vs", "ps" or "cs" followed by an uppercase letter.
+var hlsl_entry_regexp = regexp.MustCompile(`(?m)^[ \t]*\w+[ \t]+((vs|ps|cs)[A-Z]\w*)[ \t]*\(`)
+
+// Matches "cbuffer name : register(b0) { ... }".
+var hlsl_cbuffer_regexp = regexp.MustCompile(`\bcbuffer\s+(\w+)\s*(?::\s*register\s*\([^)]*\)\s*)?\{([^}]*)\}`)
+
+// Matches "uniform name { ... }" (layout qualifiers are not checked).
+var glsl_uniform_block_regexp = regexp.MustCompile(`\buniform\s+(\w+)\s*\{([^}]*)\}`)
+
+// Matches comments.
+var comment_regexp = regexp.MustCompile(`(?s)//[^\n]*|/\*.*?\*/`)
+
+// Matches a member of a constant buffer: "float4x4 mView", "uint iIndex[4]".
+var member_regexp = regexp.MustCompile(`^(\w+)\s+(\w+)\s*(?:\[\s*(\d+)\s*\])?$`)
+
+// Qualifiers of constant buffer members that don't change the layout (as long as both languages use them).
+var ignored_qualifiers = regexp.MustCompile(`\b(?:column_major|precise|highp|mediump|lowp|layout\s*\(\s*column_major\s*\))\s*`)
+
+// Checks that every HLSL shader has GLSL counterparts (and the other way around) and that
+// constant buffers of HLSL shaders have the same layout as uniform blocks with the same names
+// in GLSL counterparts, so that both graphics backends use the same set of shaders.
+//
+// A counterpart of the HLSL entry function "vs*", "ps*" or "cs*" in "<name>.hlsl" is
+// "<name>.vert", "<name>.frag" or "<name>.comp" (optionally followed by ".glsl") in the same directory,
+// so HLSL files should have at most one entry function per shader type.
+//
+// Layouts are compared using HLSL constant buffer packing and GLSL std140 rules, only scalars, vectors,
+// square matrices and arrays of them are supported.
+//
+// Should be started from the root directory of the repository.
+//
+// Flags:
+// --shaders-dir (optional) directory with shaders, "res/engine/shaders" by default.
+// --quiet       only print warnings and errors.
+// --verbose     also print debug messages (including layouts).
+//
+// Flags that are not specified are read from "NE_<FLAG>" environment variables (for example "NE_SHADERS_DIR").
+// Command line arguments always take precedence over environment variables.
+func main() {
+	var shaders_directory = flag.String("shaders-dir", "res/engine/shaders", "(optional) directory with shaders")
+	var quiet = flag.Bool("quiet", false, "only print warnings and errors")
+	var verbose = flag.Bool("verbose", false, "also print debug messages")
+
+	flag.Parse()
+	common.Apply_environment_overrides()
+	common.Init_log("check_shader_parity.go", *quiet, *verbose, false, "")
+
+	hlsl_files, glsl_files := find_shaders(*shaders_directory)
+	if len(hlsl_files) == 0 && len(glsl_files) == 0 {
+		common.Log_fatal("no shaders found in", *shaders_directory)
+	}
+
+	var problems []string
+	var matched_glsl_files = map[string]bool{}
+	for _, path := range hlsl_files {
+		problems = append(problems, check_hlsl_shader(path, glsl_files, matched_glsl_files)...)
+	}
+
+	// GLSL shaders without HLSL entry functions.
+	var glsl_paths []string
+	for base_path := range glsl_files {
+		glsl_paths = append(glsl_paths, base_path)
+	}
+	sort.Strings(glsl_paths)
+	for _, base_path := range glsl_paths {
+		var path = glsl_files[base_path]
+		if !matched_glsl_files[path] {
+			var shader_type = glsl_shader_types[filepath.Ext(base_path)]
+			problems = append(problems, fmt.Sprintf("%s has no HLSL counterpart (expected a \"%s*\" entry function in %s.hlsl)",
+				path, shader_type, strings.TrimSuffix(base_path, filepath.Ext(base_path))))
+		}
+	}
+
+	for _, problem := range problems {
+		common.Log_error(problem)
+	}
+	if len(problems) != 0 {
+		common.Log_fatal("found", len(problems), "difference(s) between HLSL and GLSL shaders")
+	}
+
+	common.Log_info("HLSL and GLSL shaders match (checked", len(hlsl_files), "HLSL and", len(glsl_files), "GLSL shader(s))")
+}
+
+// Returns HLSL shaders (sorted) and GLSL shaders with a stage in the file extension by paths without
+// the ".glsl" extension ("default.frag" for "default.frag.glsl").
+func find_shaders(directory string) ([]string, map[string]string) {
+	var hlsl_files []string
+	var glsl_files = map[string]string{}
+	var err = filepath.Walk(directory, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if path != directory && strings.HasPrefix(info.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if strings.ToLower(filepath.Ext(path)) == ".hlsl" {
+			hlsl_files = append(hlsl_files, path)
+			return nil
+		}
+
+		var base_path = path
+		if strings.ToLower(filepath.Ext(path)) == ".glsl" {
+			base_path = strings.TrimSuffix(path, filepath.Ext(path))
+		}
+		if glsl_shader_types[strings.ToLower(filepath.Ext(base_path))] != "" {
+			if existing_path, ok := glsl_files[base_path]; ok {
+				common.Log_fatal("both", existing_path, "and", path, "exist, only one of them should be used")
+			}
+			glsl_files[base_path] = path
+		}
+		return nil
+	})
+	if err != nil {
+		common.Log_fatal("failed to read directory", directory, "error:", err)
+	}
+
+	sort.Strings(hlsl_files)
+	return hlsl_files, glsl_files
+}
+
+// Checks GLSL counterparts of the HLSL shader, marks them as matched, returns found problems.
+func check_hlsl_shader(path string, glsl_files map[string]string, matched_glsl_files map[string]bool) []string {
+	var content = read_shader(path)
+
+	var entries = hlsl_entry_regexp.FindAllStringSubmatch(content, -1)
+	if len(entries) == 0 {
+		common.Log_verbose(path, "has no entry functions, skipping it (included file)")
+		return nil
+	}
+
+	var problems []string
+	var base_path = strings.TrimSuffix(path, filepath.Ext(path))
+	var entries_by_type = map[string][]string{}
+	var counterparts []string
+	for _, entry := range entries {
+		var entry_name = entry[1]
+		var shader_type = entry[2]
+		entries_by_type[shader_type] = append(entries_by_type[shader_type], entry_name)
+		if len(entries_by_type[shader_type]) > 1 {
+			problems = append(problems, fmt.Sprintf("%s has multiple \"%s*\" entry functions (%s), only one can have a GLSL counterpart",
+				path, shader_type, strings.Join(entries_by_type[shader_type], ", ")))
+			continue
+		}
+
+		var glsl_path, ok = glsl_files[base_path+"."+glsl_stages[shader_type]]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s: entry function %s has no GLSL counterpart (expected %s.%s or %s.%s.glsl)",
+				path, entry_name, base_path, glsl_stages[shader_type], base_path, glsl_stages[shader_type]))
+			continue
+		}
+		matched_glsl_files[glsl_path] = true
+		counterparts = append(counterparts, glsl_path)
+		common.Log_verbose(path, "("+entry_name+")", "matches", glsl_path)
+	}
+
+	var cbuffers = parse_blocks(path, content, true)
+	var used_cbuffers = map[string]bool{}
+	for _, glsl_path := range counterparts {
+		for _, block := range parse_blocks(glsl_path, read_shader(glsl_path), false) {
+			cbuffer, ok := find_block(cbuffers, block.name)
+			if !ok {
+				problems = append(problems, fmt.Sprintf("%s: uniform block \"%s\" has no constant buffer with the same name in %s",
+					glsl_path, block.name, path))
+				continue
+			}
+			used_cbuffers[block.name] = true
+			problems = append(problems, compare_blocks(path, cbuffer, glsl_path, block)...)
+		}
+	}
+
+	if len(counterparts) != 0 {
+		for _, cbuffer := range cbuffers {
+			if !used_cbuffers[cbuffer.name] {
+				problems = append(problems, fmt.Sprintf("%s: constant buffer \"%s\" is not used in GLSL counterparts (%s)",
+					path, cbuffer.name, strings.Join(counterparts, ", ")))
+			}
+		}
+	}
+
+	return problems
+}
+
+// Returns the content of the shader without comments.
+func read_shader(path string) string {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		common.Log_fatal("failed to read", path, "error:", err)
+	}
+	return comment_regexp.ReplaceAllString(strings.ReplaceAll(string(content), "\r\n", "\n"), "")
+}
+
+// A constant buffer (HLSL) or a uniform block (GLSL).
+type shader_block struct {
+	name    string
+	members []block_member
+	size    int // in bytes
+}
+
+// A member of a constant buffer with its offset.
+type block_member struct {
+	name       string
+	type_name  string // HLSL type name ("float4", "mat4" is converted to "float4x4")
+	array_size int    // 0 if not an array
+	offset     int    // in bytes
+}
+
+func (m block_member) String() string {
+	var text = m.type_name + " " + m.name
+	if m.array_size != 0 {
+		text += fmt.Sprintf("[%d]", m.array_size)
+	}
+	return text + fmt.Sprintf(" (offset %d)", m.offset)
+}
+
+// Returns alignment and size in bytes of a member (vector size, number of matrix columns and array size
+// are 1 for scalars/vectors/non-arrays).
+//
+// HLSL constant buffer packing: matrix columns and array elements start at 16 byte boundaries but the last one
+// is not padded (other members are only moved to the next 16 byte boundary if they would cross it, see parse_blocks).
+// GLSL std140 packing: vec3 and vec4 are aligned to 16 bytes, vec2 to 8 bytes, matrix columns and array
+// elements are padded to 16 bytes.
+func get_member_layout(is_hlsl bool, components int, columns int, array_size int) (int, int) {
+	var count = columns * max_int(array_size, 1)
+	if is_hlsl {
+		if count == 1 {
+			return 4, components * 4
+		}
+		return 16, (count-1)*16 + components*4
+	}
+
+	if columns > 1 || array_size > 0 {
+		return 16, 16 * count
+	}
+	var alignment = components * 4
+	if components == 3 {
+		alignment = 16
+	}
+	return alignment, components * 4
+}
+
+func max_int(a int, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// Parses constant buffers (HLSL) or uniform blocks (GLSL), computes offsets of members.
+func parse_blocks(path string, content string, is_hlsl bool) []shader_block {
+	var block_regexp = glsl_uniform_block_regexp
+	if is_hlsl {
+		block_regexp = hlsl_cbuffer_regexp
+	}
+
+	var blocks []shader_block
+	for _, match := range block_regexp.FindAllStringSubmatch(content, -1) {
+		var block = shader_block{name: match[1]}
+		var offset = 0
+		for _, declaration := range strings.Split(match[2], ";") {
+			declaration = strings.TrimSpace(ignored_qualifiers.ReplaceAllString(declaration, ""))
+			if declaration == "" {
+				continue
+			}
+
+			var member_match = member_regexp.FindStringSubmatch(strings.Join(strings.Fields(declaration), " "))
+			if member_match == nil {
+				common.Log_fatal(path+": unsupported declaration \""+declaration+"\" in", block.name)
+			}
+
+			var member = block_member{name: member_match[2], type_name: normalize_type_name(member_match[1])}
+			components, columns, ok := get_type_dimensions(member.type_name)
+			if !ok {
+				common.Log_fatal(path+": unsupported type", member_match[1], "of", block.name+"."+member.name)
+			}
+			if member_match[3] != "" {
+				member.array_size, _ = strconv.Atoi(member_match[3])
+			}
+
+			alignment, size := get_member_layout(is_hlsl, components, columns, member.array_size)
+			offset = (offset + alignment - 1) / alignment * alignment
+			if is_hlsl && offset/16 != (offset+size-1)/16 {
+				// HLSL members can't cross 16 byte boundaries.
+				offset = (offset + 15) / 16 * 16
+			}
+			member.offset = offset
+			offset += size
+
+			block.members = append(block.members, member)
+		}
+		block.size = offset
+		blocks = append(blocks, block)
+
+		common.Log_verbose(path+":", block.name, fmt.Sprintf("(%d bytes)", block.size))
+		for _, member := range block.members {
+			common.Log_verbose("    " + member.String())
+		}
+	}
+	return blocks
+}
+
+// Converts GLSL type names to HLSL type names ("vec4" to "float4", "mat4" to "float4x4").
+func normalize_type_name(type_name string) string {
+	var prefixes = map[string]string{"vec": "float", "ivec": "int", "uvec": "uint", "bvec": "bool"}
+	for glsl_prefix, hlsl_prefix := range prefixes {
+		if size := strings.TrimPrefix(type_name, glsl_prefix); size != type_name && len(size) == 1 {
+			return hlsl_prefix + size
+		}
+	}
+
+	if size := strings.TrimPrefix(type_name, "mat"); size != type_name {
+		if len(size) == 1 {
+			return "float" + size + "x" + size
+		}
+		return "float" + size
+	}
+
+	return type_name
+}
+
+// Returns the number of components (vector size) and columns of the HLSL type.
+func get_type_dimensions(type_name string) (int, int, bool) {
+	for _, scalar := range []string{"float", "int", "uint", "bool"} {
+		var size = strings.TrimPrefix(type_name, scalar)
+		if size == type_name {
+			continue
+		}
+		if size == "" {
+			return 1, 1, true
+		}
+
+		rows, columns, is_matrix := strings.Cut(size, "x")
+		components, err := strconv.Atoi(rows)
+		if err != nil || components < 1 || components > 4 {
+			return 0, 0, false
+		}
+		if !is_matrix {
+			return components, 1, true
+		}
+
+		// Only square matrices are supported (HLSL and GLSL name non-square matrices differently).
+		if scalar != "float" || columns != rows {
+			return 0, 0, false
+		}
+		return components, components, true
+	}
+	return 0, 0, false
+}
+
+func find_block(blocks []shader_block, name string) (shader_block, bool) {
+	for _, block := range blocks {
+		if block.name == name {
+			return block, true
+		}
+	}
+	return shader_block{}, false
+}
+
+// Compares members and offsets of the HLSL constant buffer and the GLSL uniform block, returns found problems.
+func compare_blocks(hlsl_path string, cbuffer shader_block, glsl_path string, block shader_block) []string {
+	var location = fmt.Sprintf("constant buffer \"%s\" in %s and %s", cbuffer.name, hlsl_path, glsl_path)
+
+	if len(cbuffer.members) != len(block.members) {
+		return []string{fmt.Sprintf("%s have different number of members (%d and %d)",
+			location, len(cbuffer.members), len(block.members))}
+	}
+
+	for i, member := range cbuffer.members {
+		var other = block.members[i]
+		if member.name != other.name || member.type_name != other.type_name || member.array_size != other.array_size {
+			return []string{fmt.Sprintf("%s have different member #%d: \"%s\" and \"%s\"", location, i+1, member, other)}
+		}
+		if member.offset != other.offset {
+			return []string{fmt.Sprintf("%s have different offset of \"%s\": %d and %d (add padding to keep layouts the same)",
+				location, member.name, member.offset, other.offset)}
+		}
+	}
+
+	// The last member of std140 blocks is padded to 16 bytes.
+	if (cbuffer.size+15)/16 != (block.size+15)/16 {
+		return []string{fmt.Sprintf("%s have different size: %d and %d bytes", location, cbuffer.size, block.size)}
+	}
+
+	common.Log_verbose(location, "match")
+	return nil
+}
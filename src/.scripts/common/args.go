@@ -0,0 +1,55 @@
+package common
+
+import (
+	"flag"
+	"os"
+	"strings"
+)
+
+// Replaces arguments in form "@path" with arguments read from the specified file
+// (one argument per line, empty lines and lines that start with '#' are ignored).
+func Expand_response_files(args []string) []string {
+	var expanded_args []string
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "@") {
+			expanded_args = append(expanded_args, arg)
+			continue
+		}
+
+		var path = arg[1:]
+		content, err := os.ReadFile(path)
+		if err != nil {
+			Log_fatal("failed to read response file", path, "error:", err)
+		}
+
+		for _, line := range strings.Split(string(content), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			expanded_args = append(expanded_args, line)
+		}
+	}
+
+	return expanded_args
+}
+
+// Sets flags that were not specified in the command line from "NE_<FLAG>" environment variables
+// (empty variables are ignored).
+func Apply_environment_overrides() {
+	var specified_flags = map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { specified_flags[f.Name] = true })
+
+	flag.VisitAll(func(f *flag.Flag) {
+		var name = "NE_" + strings.ToUpper(strings.ReplaceAll(f.Name, "-", "_"))
+		var value = os.Getenv(name)
+		if specified_flags[f.Name] || value == "" {
+			return
+		}
+
+		var err = flag.Set(f.Name, value)
+		if err != nil {
+			Log_fatal("invalid value of the environment variable", name, "error:", err)
+		}
+	})
+}
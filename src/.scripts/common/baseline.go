@@ -0,0 +1,57 @@
+package common
+
+import (
+	"os"
+	"sort"
+	"strings"
+)
+
+// Reads the baseline of known warnings, returns the number of known warnings by their keys.
+func Read_baseline(path string) map[string]int {
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		Log_warning("baseline file", path, "does not exist, all warnings are new")
+		return map[string]int{}
+	}
+	if err != nil {
+		Log_fatal("failed to read baseline", path, "error:", err)
+	}
+
+	var baseline = map[string]int{}
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			baseline[line] += 1
+		}
+	}
+	return baseline
+}
+
+// Writes keys of warnings to the baseline file (one line per warning) after the "header" comment
+// (that should start with '#').
+func Write_baseline(path string, header string, keys []string) {
+	var lines = append([]string{}, keys...)
+	sort.Strings(lines)
+	lines = append([]string{header}, lines...)
+
+	var err = os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644)
+	if err != nil {
+		Log_fatal("failed to write baseline", path, "error:", err)
+	}
+}
+
+// Returns true for keys of warnings that are not in the baseline (or that occur more times than
+// in the baseline), the baseline is modified. Also returns the number of new warnings.
+func Find_new_warnings(keys []string, baseline map[string]int) ([]bool, int) {
+	var is_new = make([]bool, len(keys))
+	var new_count = 0
+	for i, key := range keys {
+		if baseline[key] > 0 {
+			baseline[key] -= 1
+			continue
+		}
+		is_new[i] = true
+		new_count += 1
+	}
+	return is_new, new_count
+}
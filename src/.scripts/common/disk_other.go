@@ -0,0 +1,16 @@
+//go:build !windows
+
+package common
+
+import "syscall"
+
+// Returns the number of bytes available to the current user on the volume of the specified directory.
+func get_free_disk_space(directory string) (uint64, error) {
+	var stat syscall.Statfs_t
+	var err = syscall.Statfs(directory, &stat)
+	if err != nil {
+		return 0, err
+	}
+
+	return uint64(stat.Bavail) * uint64(stat.Bsize), nil
+}
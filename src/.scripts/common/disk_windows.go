@@ -0,0 +1,25 @@
+package common
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var kernel32 = syscall.NewLazyDLL("kernel32.dll")
+var get_disk_free_space_ex = kernel32.NewProc("GetDiskFreeSpaceExW")
+
+// Returns the number of bytes available to the current user on the volume of the specified directory.
+func get_free_disk_space(directory string) (uint64, error) {
+	path, err := syscall.UTF16PtrFromString(directory)
+	if err != nil {
+		return 0, err
+	}
+
+	var free_bytes uint64
+	result, _, err := get_disk_free_space_ex.Call(uintptr(unsafe.Pointer(path)), uintptr(unsafe.Pointer(&free_bytes)), 0, 0)
+	if result == 0 {
+		return 0, err
+	}
+
+	return free_bytes, nil
+}
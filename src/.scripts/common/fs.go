@@ -0,0 +1,65 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Exits with an error if the volume of the specified directory (or of its closest
+// existing parent directory) has less than "required_bytes" of free space.
+func Check_free_disk_space(directory string, required_bytes int64, description string) {
+	if required_bytes <= 0 {
+		return
+	}
+
+	// The directory might not be created yet.
+	var existing_directory = filepath.Clean(directory)
+	for {
+		if _, err := os.Stat(existing_directory); err == nil {
+			break
+		}
+		var parent = filepath.Dir(existing_directory)
+		if parent == existing_directory {
+			return
+		}
+		existing_directory = parent
+	}
+
+	free_bytes, err := get_free_disk_space(existing_directory)
+	if err != nil {
+		Log_verbose("failed to get free disk space of", existing_directory, "error:", err)
+		return
+	}
+
+	if uint64(required_bytes) > free_bytes {
+		Log_fatal("not enough free disk space in", existing_directory, "for", description+": required",
+			Format_byte_count(required_bytes), "but only", Format_byte_count(int64(free_bytes)), "is available")
+	}
+	Log_verbose(description, "requires", Format_byte_count(required_bytes), "of",
+		Format_byte_count(int64(free_bytes)), "available in", existing_directory)
+}
+
+// Returns human-readable size.
+func Format_byte_count(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+
+	var divisor, exponent = int64(unit), 0
+	for value := bytes / unit; value >= unit; value /= unit {
+		divisor *= unit
+		exponent += 1
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(divisor), "KMGTPE"[exponent])
+}
+
+// Writes the file, exits with an error on failure.
+func Write_file(path string, content []byte) {
+	var err = os.WriteFile(path, content, 0644)
+	if err != nil {
+		Log_fatal("failed to write", path, "error:", err)
+	}
+}
@@ -0,0 +1,116 @@
+// Helpers shared by the scripts in "src/.scripts" and "ext" (logging, command line arguments, paths).
+package common
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Verbosity levels of the console output.
+const (
+	verbosity_quiet   = iota // only warnings and errors
+	verbosity_normal         // warnings, errors, info and success messages
+	verbosity_verbose        // everything including debug messages
+)
+
+var log_verbosity = verbosity_normal
+var log_timestamps = false
+var log_file *os.File
+
+// Used because messages can be logged from multiple goroutines.
+var log_mutex sync.Mutex
+
+// Name of the script that is printed in messages, the name of the executable is used
+// until `Init_log` is called ("go run" names executables after scripts).
+var log_tool_name = strings.TrimSuffix(filepath.Base(os.Args[0]), ".exe") + ".go"
+
+// Configures logging, should be called after arguments are parsed. "tool_name" is the name of the script
+// that is printed in messages (for example "run_tests.go"), "log_file_path" is the path to the file
+// to write all messages to (with timestamps and debug messages), can be empty.
+func Init_log(tool_name string, quiet bool, verbose bool, timestamps bool, log_file_path string) {
+	log_tool_name = tool_name
+
+	if quiet && verbose {
+		Log_fatal("--quiet and --verbose can not be used together")
+	}
+
+	if quiet {
+		log_verbosity = verbosity_quiet
+	} else if verbose {
+		log_verbosity = verbosity_verbose
+	}
+	log_timestamps = timestamps
+
+	if log_file_path == "" {
+		return
+	}
+
+	file, err := os.OpenFile(log_file_path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		Log_fatal("failed to open log file", log_file_path, "error:", err)
+	}
+	log_file = file
+}
+
+// Closes the log file (if it was opened).
+func Close_log() {
+	if log_file != nil {
+		log_file.Close()
+		log_file = nil
+	}
+}
+
+func log_message(level string, min_verbosity int, args ...interface{}) {
+	var timestamp = time.Now().Format("15:04:05.000")
+	var line = level + ": " + log_tool_name + ": " + strings.TrimSuffix(fmt.Sprintln(args...), "\n")
+
+	log_mutex.Lock()
+	defer log_mutex.Unlock()
+
+	if log_verbosity >= min_verbosity {
+		if log_timestamps {
+			fmt.Println("[" + timestamp + "] " + line)
+		} else {
+			fmt.Println(line)
+		}
+	}
+
+	if log_file != nil {
+		fmt.Fprintln(log_file, "["+timestamp+"] "+line)
+	}
+}
+
+func Log_verbose(args ...interface{}) {
+	log_message("DEBUG", verbosity_verbose, args...)
+}
+
+func Log_info(args ...interface{}) {
+	log_message("INFO", verbosity_normal, args...)
+}
+
+func Log_success(args ...interface{}) {
+	log_message("SUCCESS", verbosity_normal, args...)
+}
+
+func Log_warning(args ...interface{}) {
+	log_message("WARNING", verbosity_quiet, args...)
+}
+
+func Log_error(args ...interface{}) {
+	log_message("ERROR", verbosity_quiet, args...)
+}
+
+func Log_fatal(args ...interface{}) {
+	Log_error(args...)
+	Exit_with_error()
+}
+
+// Closes the log file and exits with code 1.
+func Exit_with_error() {
+	Close_log()
+	os.Exit(1)
+}
@@ -0,0 +1,50 @@
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// Error that is returned by `Launch_binary` if the binary did not finish in time.
+type Timeout_error struct {
+	Timeout int64 // in seconds
+}
+
+func (e *Timeout_error) Error() string {
+	return fmt.Sprintf("timed out after %d second(s)", e.Timeout)
+}
+
+// Starts the binary (in its directory) and waits for it to finish, returns its output. The binary
+// is killed if it does not finish in "timeout" seconds (`Timeout_error` is returned). If "environment"
+// is nil the binary uses the environment of the current process.
+func Launch_binary(binary_path string, args []string, environment []string, timeout int64) (string, error) {
+	var output bytes.Buffer
+	var command = exec.Command(binary_path, args...)
+	command.Dir = filepath.Dir(binary_path)
+	command.Env = environment
+	command.Stdout = &output
+	command.Stderr = &output
+
+	var err = command.Start()
+	if err != nil {
+		return "", err
+	}
+
+	var done = make(chan error, 1)
+	go func() {
+		done <- command.Wait()
+	}()
+
+	select {
+	case err = <-done:
+	case <-time.After(time.Duration(timeout) * time.Second):
+		command.Process.Kill()
+		<-done
+		err = &Timeout_error{Timeout: timeout}
+	}
+
+	return output.String(), err
+}
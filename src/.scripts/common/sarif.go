@@ -0,0 +1,93 @@
+package common
+
+import (
+	"encoding/json"
+)
+
+// Warning of a tool that is written to a SARIF report.
+type Sarif_result struct {
+	Rule_id        string
+	Level          string // "error", "warning" or "note"
+	Message        string
+	Locations      []Sarif_location
+	Baseline_state string // optional, "new" or "unchanged"
+}
+
+type Sarif_location struct {
+	File   string // relative path with '/' as separator
+	Line   int
+	Column int // optional
+}
+
+// Minimal subset of SARIF 2.1.0 that is used by code scanning tools.
+type sarif_log struct {
+	Schema  string      `json:"$schema"`
+	Version string      `json:"version"`
+	Runs    []sarif_run `json:"runs"`
+}
+
+type sarif_run struct {
+	Tool    sarif_tool          `json:"tool"`
+	Results []sarif_json_result `json:"results"`
+}
+
+type sarif_tool struct {
+	Driver struct {
+		Name           string `json:"name"`
+		InformationUri string `json:"informationUri"`
+	} `json:"driver"`
+}
+
+type sarif_json_result struct {
+	RuleId        string                `json:"ruleId"`
+	Level         string                `json:"level"`
+	Message       sarif_message         `json:"message"`
+	Locations     []sarif_json_location `json:"locations"`
+	BaselineState string                `json:"baselineState,omitempty"`
+}
+
+type sarif_message struct {
+	Text string `json:"text"`
+}
+
+type sarif_json_location struct {
+	PhysicalLocation struct {
+		ArtifactLocation struct {
+			Uri string `json:"uri"`
+		} `json:"artifactLocation"`
+		Region struct {
+			StartLine   int `json:"startLine"`
+			StartColumn int `json:"startColumn,omitempty"`
+		} `json:"region"`
+	} `json:"physicalLocation"`
+}
+
+// Writes warnings of the tool as a SARIF report (for example to show them in pull requests).
+func Write_sarif(path string, tool_name string, tool_uri string, results []Sarif_result) {
+	var run = sarif_run{Results: []sarif_json_result{}}
+	run.Tool.Driver.Name = tool_name
+	run.Tool.Driver.InformationUri = tool_uri
+
+	for _, item := range results {
+		var result = sarif_json_result{RuleId: item.Rule_id, Level: item.Level, Message: sarif_message{Text: item.Message},
+			Locations: []sarif_json_location{}, BaselineState: item.Baseline_state}
+		for _, item_location := range item.Locations {
+			var location sarif_json_location
+			location.PhysicalLocation.ArtifactLocation.Uri = item_location.File
+			location.PhysicalLocation.Region.StartLine = item_location.Line
+			location.PhysicalLocation.Region.StartColumn = item_location.Column
+			result.Locations = append(result.Locations, location)
+		}
+		run.Results = append(run.Results, result)
+	}
+
+	content, err := json.MarshalIndent(sarif_log{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarif_run{run},
+	}, "", "  ")
+	if err != nil {
+		Log_fatal("failed to serialize SARIF report, error:", err)
+	}
+	Write_file(path, content)
+}
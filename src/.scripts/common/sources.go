@@ -0,0 +1,86 @@
+package common
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Extensions of C/C++ and shader source files.
+var source_extensions = map[string]bool{".h": true, ".hpp": true, ".c": true, ".cpp": true, ".hlsl": true, ".glsl": true}
+
+// Returns paths (relative to the working directory if possible, with '/' as separator) of C/C++/shader
+// source files in the directory. Hidden files/directories and files/directories that match "excludes"
+// (see `Is_excluded`) are skipped.
+func Find_source_files(directory string, excludes []string) []string {
+	if _, err := os.Stat(directory); os.IsNotExist(err) {
+		Log_fatal("directory", directory, "does not exist")
+	}
+
+	var files []string
+	var err = filepath.Walk(directory, func(file_path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if file_path != directory && (strings.HasPrefix(info.Name(), ".") || matches_exclude(file_path, excludes)) {
+			Log_verbose("excluding", Get_relative_path(file_path))
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !info.IsDir() && source_extensions[filepath.Ext(file_path)] {
+			files = append(files, Get_relative_path(filepath.Clean(file_path)))
+		}
+		return nil
+	})
+	if err != nil {
+		Log_fatal("failed to read directory", directory, "error:", err)
+	}
+
+	sort.Strings(files)
+	return files
+}
+
+// Tells if the file or one of its parent directories (up to "directory") matches one of "excludes": globs
+// with '/' are matched against paths relative to the working directory, other globs against names.
+func Is_excluded(file_path string, directory string, excludes []string) bool {
+	directory = filepath.Clean(directory)
+	for current := filepath.Clean(file_path); current != directory && current != filepath.Dir(current); current = filepath.Dir(current) {
+		if matches_exclude(current, excludes) {
+			return true
+		}
+	}
+	return false
+}
+
+// Tells if the file or directory (but not its parent directories) matches one of "excludes".
+func matches_exclude(file_path string, excludes []string) bool {
+	var relative_path = Get_relative_path(file_path)
+	for _, pattern := range excludes {
+		pattern = strings.TrimSpace(pattern)
+		var value = path.Base(relative_path)
+		if strings.Contains(pattern, "/") {
+			value = relative_path
+		}
+		if matched, _ := path.Match(pattern, value); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the path relative to the working directory (if possible) with '/' as separator.
+func Get_relative_path(file_path string) string {
+	if filepath.IsAbs(file_path) {
+		var working_directory, _ = os.Getwd()
+		if relative_path, err := filepath.Rel(working_directory, file_path); err == nil && !strings.HasPrefix(relative_path, "..") {
+			file_path = relative_path
+		}
+	}
+	return filepath.ToSlash(file_path)
+}
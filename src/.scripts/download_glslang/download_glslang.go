@@ -0,0 +1,208 @@
+package main
+
+import (
+	"archive/zip"
+	"common"
+	"flag"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Expects 1 argument:
+// 1. Working directory (the directory where this script is located).
+// Arguments can also be read from a file by specifying "@path/to/file" (one argument per line).
+//
+// Optional flags (specified before the working directory):
+// --quiet      only print warnings and errors.
+// --verbose    also print debug messages.
+// --timestamps prefix console messages with timestamps.
+// --log-file   path to the file to write all messages to (with timestamps and debug messages).
+//
+// Flags that are not specified are read from "NE_<FLAG>" environment variables (for example "NE_VERBOSE"),
+// the working directory is read from "NE_GLSLANG_DIR" if not specified. Command line arguments
+// always take precedence over environment variables.
+func main() {
+	var quiet = flag.Bool("quiet", false, "only print warnings and errors")
+	var verbose = flag.Bool("verbose", false, "also print debug messages")
+	var timestamps = flag.Bool("timestamps", false, "prefix console messages with timestamps")
+	var log_file_path = flag.String("log-file", "", "path to the file to write all messages to")
+
+	flag.CommandLine.Parse(common.Expand_response_files(os.Args[1:]))
+	common.Apply_environment_overrides()
+	common.Init_log("download_glslang.go", *quiet, *verbose, *timestamps, *log_file_path)
+	defer common.Close_log()
+
+	var working_directory = os.Getenv("NE_GLSLANG_DIR")
+	var args = flag.Args()
+	if len(args) != 0 {
+		working_directory = args[0]
+	}
+	if working_directory == "" {
+		common.Log_fatal("not enough arguments.")
+	}
+
+	common.Log_verbose("using working directory:", working_directory)
+	var archive_url = get_archive_url()
+
+	download_glslang_build(working_directory, archive_url)
+	remove_old_glslang_build(working_directory)
+	unzip(filepath.Join(working_directory, get_archive_name(archive_url)), working_directory)
+}
+
+// Returns URL of the glslang build for the current platform.
+func get_archive_url() string {
+	var base_url = "https://github.com/KhronosGroup/glslang/releases/download/main-tot/"
+
+	switch runtime.GOOS {
+	case "windows":
+		return base_url + "glslang-main-windows-x64-Release.zip"
+	case "linux":
+		return base_url + "glslang-main-linux-Release.zip"
+	case "darwin":
+		return base_url + "glslang-main-osx-Release.zip"
+	}
+
+	common.Log_fatal("there are no glslang builds for", runtime.GOOS)
+	return ""
+}
+
+func get_archive_name(archive_url string) string {
+	return archive_url[strings.LastIndex(archive_url, "/"):]
+}
+
+func download_glslang_build(working_directory string, URL string) {
+	var filename = filepath.Join(working_directory, get_archive_name(URL))
+
+	var _, err = os.Stat(filename)
+	if err == nil {
+		// Exists.
+		common.Log_info("found glslang build", filename, " - nothing to do")
+		common.Close_log()
+		os.Exit(0)
+	}
+
+	// Not found. See if there are any .zip files and remove them.
+	items, _ := ioutil.ReadDir(working_directory)
+	for _, item := range items {
+		if item.IsDir() {
+			continue
+		} else {
+			if strings.HasSuffix(item.Name(), ".zip") {
+				os.Remove(filepath.Join(working_directory, item.Name()))
+			}
+		}
+	}
+
+	common.Log_info("downloading file", filename)
+
+	response, err := http.Get(URL)
+	if err != nil {
+		common.Log_fatal(err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != 200 {
+		common.Log_fatal("received non 200 response code, actual result:", response.StatusCode)
+	}
+
+	// Content length is -1 if unknown.
+	common.Check_free_disk_space(working_directory, response.ContentLength, "glslang archive")
+
+	file, err := os.Create(filename)
+	if err != nil {
+		common.Log_fatal("failed to create empty file, error:", err)
+	}
+	defer file.Close()
+
+	_, err = io.Copy(file, response.Body)
+	if err != nil {
+		common.Log_fatal("failed to copy downloaded bytes, error:", err)
+	}
+}
+
+func remove_old_glslang_build(working_directory string) {
+	var dirs_to_check = []string{"bin", "include", "lib"} // glslang archive contents
+
+	for i := 0; i < len(dirs_to_check); i += 1 {
+		var current_path = filepath.Join(working_directory, dirs_to_check[i])
+		var _, err = os.Stat(current_path)
+		if err == nil {
+			// Exists.
+			err = os.RemoveAll(current_path)
+			if err != nil {
+				common.Log_fatal("failed to remove old glslang build, error:", err)
+			}
+		}
+	}
+
+}
+
+func unzip(src string, dest string) {
+	r, err := zip.OpenReader(src)
+	if err != nil {
+		common.Log_fatal("open zip reader, error:", err)
+	}
+	defer func() {
+		if err := r.Close(); err != nil {
+			common.Log_fatal("error:", err)
+		}
+	}()
+
+	var uncompressed_size int64 = 0
+	for _, f := range r.File {
+		uncompressed_size += int64(f.UncompressedSize64)
+	}
+	common.Check_free_disk_space(dest, uncompressed_size, "extracted glslang build")
+
+	os.MkdirAll(dest, 0755)
+
+	// Closure to address file descriptors issue with all the deferred .Close() methods
+	extractAndWriteFile := func(f *zip.File) {
+		rc, err := f.Open()
+		if err != nil {
+			common.Log_fatal("error:", err)
+		}
+		defer func() {
+			if err := rc.Close(); err != nil {
+				common.Log_fatal("error:", err)
+			}
+		}()
+
+		path := filepath.Join(dest, f.Name)
+		common.Log_verbose("extracting", path)
+
+		// Check for ZipSlip (Directory traversal)
+		if !strings.HasPrefix(path, filepath.Clean(dest)+string(os.PathSeparator)) {
+			common.Log_fatal("illegal file path:", path)
+		}
+
+		if f.FileInfo().IsDir() {
+			os.MkdirAll(path, f.Mode())
+		} else {
+			os.MkdirAll(filepath.Dir(path), f.Mode())
+			f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode())
+			if err != nil {
+				common.Log_fatal("error:", err)
+			}
+			defer func() {
+				if err := f.Close(); err != nil {
+					common.Log_fatal("error:", err)
+				}
+			}()
+
+			_, err = io.Copy(f, rc)
+			if err != nil {
+				common.Log_fatal("error:", err)
+			}
+		}
+	}
+
+	for _, f := range r.File {
+		extractAndWriteFile(f)
+	}
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"common"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Runs clang-tidy on all source files from the compilation database (compile_commands.json,
+// generated by CMake with `-DCMAKE_EXPORT_COMPILE_COMMANDS=ON`) that are located in the source directory.
+// Checks are configured using ".clang-tidy" files: clang-tidy uses the closest ".clang-tidy" file
+// of the checked file so checks can be configured per directory by adding ".clang-tidy" files
+// (with `InheritParentConfig: true`) to subdirectories.
+//
+// Should be started from the root directory of the repository (paths in the baseline
+// and in reports are relative to the working directory).
+//
+// Flags:
+// --build-dir       path to the directory with compile_commands.json (required).
+// --source-dir      (optional) only check files in this directory, "src" by default.
+// --exclude         (optional) comma-separated globs of files/directories to not check (patterns with '/' match relative paths).
+// --clang-tidy      (optional) path to clang-tidy, "clang-tidy" from PATH by default.
+// --checks          (optional) checks to use instead of the checks from ".clang-tidy" files.
+// --jobs            (optional) number of files to check in parallel, number of CPUs by default.
+// --baseline        (optional) path to the file with known warnings, only new warnings fail the check.
+// --update-baseline (optional) write all found warnings to the baseline file and exit successfully.
+// --sarif           (optional) path to the SARIF report to write.
+// --quiet           only print warnings and errors.
+// --verbose         also print debug messages.
+//
+// Flags that are not specified are read from "NE_<FLAG>" environment variables (for example "NE_BUILD_DIR").
+// Command line arguments always take precedence over environment variables.
+func main() {
+	var build_directory = flag.String("build-dir", "", "path to the directory with compile_commands.json")
+	var source_directory = flag.String("source-dir", "src", "(optional) only check files in this directory")
+	var exclude = flag.String("exclude", "", "(optional) comma-separated globs of files/directories to not check")
+	var clang_tidy = flag.String("clang-tidy", "clang-tidy", "(optional) path to clang-tidy")
+	var checks = flag.String("checks", "", "(optional) checks to use instead of the checks from \".clang-tidy\" files")
+	var jobs = flag.Int("jobs", runtime.NumCPU(), "(optional) number of files to check in parallel")
+	var baseline_path = flag.String("baseline", "", "(optional) path to the file with known warnings")
+	var update_baseline = flag.Bool("update-baseline", false, "(optional) write all found warnings to the baseline file")
+	var sarif_path = flag.String("sarif", "", "(optional) path to the SARIF report to write")
+	var quiet = flag.Bool("quiet", false, "only print warnings and errors")
+	var verbose = flag.Bool("verbose", false, "also print debug messages")
+
+	flag.Parse()
+	common.Apply_environment_overrides()
+	common.Init_log("run_clang_tidy.go", *quiet, *verbose, false, "")
+
+	if *build_directory == "" {
+		common.Log_fatal("\"--build-dir\" is required")
+	}
+	if *update_baseline && *baseline_path == "" {
+		common.Log_fatal("\"--update-baseline\" requires \"--baseline\"")
+	}
+	if *jobs < 1 {
+		*jobs = 1
+	}
+
+	clang_tidy_path, err := exec.LookPath(*clang_tidy)
+	if err != nil {
+		common.Log_fatal("clang-tidy was not found, specify \"--clang-tidy\", error:", err)
+	}
+
+	var excludes []string
+	if *exclude != "" {
+		excludes = strings.Split(*exclude, ",")
+	}
+	var files = get_files_to_check(*build_directory, *source_directory, excludes)
+	if len(files) == 0 {
+		common.Log_fatal("no files from", *source_directory, "were found in the compilation database")
+	}
+
+	common.Log_info("checking", len(files), "file(s) using", *jobs, "job(s)")
+
+	var start_time = time.Now()
+	var diagnostics = run_clang_tidy(clang_tidy_path, *build_directory, *checks, files, *jobs)
+
+	common.Log_info("checked", len(files), "file(s) in", time.Since(start_time).Round(time.Second))
+
+	if *update_baseline {
+		common.Write_baseline(*baseline_path, "# Known clang-tidy warnings (see run_clang_tidy.go), regenerate using \"--update-baseline\".",
+			get_baseline_keys(diagnostics))
+		common.Log_info("baseline with", len(diagnostics), "warning(s) was written to", *baseline_path)
+		return
+	}
+
+	var baseline = map[string]int{}
+	if *baseline_path != "" {
+		baseline = common.Read_baseline(*baseline_path)
+	}
+	is_new, new_count := common.Find_new_warnings(get_baseline_keys(diagnostics), baseline)
+	for i := range diagnostics {
+		diagnostics[i].is_new = is_new[i]
+	}
+
+	if *sarif_path != "" {
+		write_sarif(*sarif_path, diagnostics)
+		common.Log_info("SARIF report was written to", *sarif_path)
+	}
+
+	for _, diagnostic := range diagnostics {
+		if diagnostic.is_new {
+			common.Log_error(diagnostic.String())
+		} else {
+			common.Log_verbose("(baseline)", diagnostic.String())
+		}
+	}
+
+	if new_count != 0 {
+		common.Log_fatal("clang-tidy found", new_count, "new warning(s) (of", len(diagnostics), "total)")
+	}
+
+	common.Log_info("no new warnings were found,", len(diagnostics), "known warning(s) are in the baseline")
+}
+
+// An entry of compile_commands.json.
+type compile_command struct {
+	Directory string `json:"directory"`
+	File      string `json:"file"`
+}
+
+// Returns absolute paths to the files from the compilation database that are located in the source directory.
+func get_files_to_check(build_directory string, source_directory string, excludes []string) []string {
+	var database_path = filepath.Join(build_directory, "compile_commands.json")
+	content, err := os.ReadFile(database_path)
+	if err != nil {
+		common.Log_fatal("failed to read", database_path, "(configure CMake with -DCMAKE_EXPORT_COMPILE_COMMANDS=ON) error:", err)
+	}
+
+	var commands []compile_command
+	err = json.Unmarshal(content, &commands)
+	if err != nil {
+		common.Log_fatal("failed to parse", database_path, "error:", err)
+	}
+
+	source_directory, err = filepath.Abs(source_directory)
+	if err != nil {
+		common.Log_fatal("failed to get absolute path of", source_directory, "error:", err)
+	}
+
+	var files []string
+	var added_files = map[string]bool{}
+	for _, command := range commands {
+		var path = command.File
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(command.Directory, path)
+		}
+		path = filepath.Clean(path)
+
+		if added_files[path] || !strings.HasPrefix(path, source_directory+string(os.PathSeparator)) {
+			continue
+		}
+		if common.Is_excluded(path, source_directory, excludes) {
+			common.Log_verbose("excluding", path)
+			continue
+		}
+
+		added_files[path] = true
+		files = append(files, path)
+	}
+
+	sort.Strings(files)
+	return files
+}
+
+// A warning or an error reported by clang-tidy.
+type diagnostic struct {
+	file     string // relative to the working directory (if possible), uses '/' as separator
+	line     int
+	column   int
+	severity string // "warning" or "error"
+	message  string
+	check    string
+	is_new   bool // not in the baseline
+}
+
+func (d diagnostic) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s: %s [%s]", d.file, d.line, d.column, d.severity, d.message, d.check)
+}
+
+// Returns a key of the diagnostic in the baseline (without line numbers so that
+// unrelated changes in the file don't make known warnings new).
+func (d diagnostic) baseline_key() string {
+	return d.file + ": [" + d.check + "] " + d.message
+}
+
+// Matches diagnostics in form "path:line:column: warning: message [check]".
+var diagnostic_regexp = regexp.MustCompile(`^(.+):(\d+):(\d+): (warning|error): (.+?)(?: \[([^\]]+)\])?$`)
+
+// Runs clang-tidy on the files in parallel, returns sorted unique diagnostics
+// (warnings in headers are reported once).
+func run_clang_tidy(clang_tidy_path string, build_directory string, checks string, files []string, jobs int) []diagnostic {
+	var queue = make(chan string)
+	var mutex sync.Mutex
+	var unique_diagnostics = map[string]diagnostic{}
+	var failed_files []string
+	var checked_count = 0
+
+	var wait_group sync.WaitGroup
+	for i := 0; i < jobs; i++ {
+		wait_group.Add(1)
+		go func() {
+			defer wait_group.Done()
+			for file := range queue {
+				var args = []string{"-p", build_directory, "--quiet"}
+				if checks != "" {
+					args = append(args, "--checks="+checks)
+				}
+				args = append(args, file)
+
+				var output bytes.Buffer
+				var command = exec.Command(clang_tidy_path, args...)
+				command.Stdout = &output
+				command.Stderr = &output
+				var err = command.Run()
+
+				var file_diagnostics = parse_diagnostics(output.String())
+
+				mutex.Lock()
+				checked_count += 1
+				common.Log_verbose("["+strconv.Itoa(checked_count)+"/"+strconv.Itoa(len(files))+"]", file)
+				for _, item := range file_diagnostics {
+					unique_diagnostics[item.String()] = item
+				}
+				// clang-tidy returns an error if a warning is treated as an error (see "WarningsAsErrors").
+				if err != nil && len(file_diagnostics) == 0 {
+					common.Log_error("failed to run clang-tidy on", file, "error:", err, "output:", strings.TrimSpace(output.String()))
+					failed_files = append(failed_files, file)
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	for _, file := range files {
+		queue <- file
+	}
+	close(queue)
+	wait_group.Wait()
+
+	if len(failed_files) != 0 {
+		common.Log_fatal("failed to run clang-tidy on", len(failed_files), "file(s)")
+	}
+
+	var diagnostics []diagnostic
+	for _, item := range unique_diagnostics {
+		diagnostics = append(diagnostics, item)
+	}
+	sort.Slice(diagnostics, func(i int, j int) bool {
+		if diagnostics[i].file != diagnostics[j].file {
+			return diagnostics[i].file < diagnostics[j].file
+		}
+		if diagnostics[i].line != diagnostics[j].line {
+			return diagnostics[i].line < diagnostics[j].line
+		}
+		return diagnostics[i].String() < diagnostics[j].String()
+	})
+
+	return diagnostics
+}
+
+// Returns diagnostics from the output of clang-tidy.
+func parse_diagnostics(output string) []diagnostic {
+	var working_directory, _ = os.Getwd()
+
+	var diagnostics []diagnostic
+	var scanner = bufio.NewScanner(strings.NewReader(output))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var match = diagnostic_regexp.FindStringSubmatch(strings.TrimRight(scanner.Text(), "\r"))
+		if match == nil {
+			continue
+		}
+
+		var file = filepath.Clean(match[1])
+		if relative_path, err := filepath.Rel(working_directory, file); err == nil && !strings.HasPrefix(relative_path, "..") {
+			file = relative_path
+		}
+		line, _ := strconv.Atoi(match[2])
+		column, _ := strconv.Atoi(match[3])
+		var check = match[6]
+		if check == "" {
+			check = "clang-diagnostic-" + match[4]
+		}
+
+		diagnostics = append(diagnostics, diagnostic{file: filepath.ToSlash(file), line: line, column: column,
+			severity: match[4], message: match[5], check: check})
+	}
+	return diagnostics
+}
+
+// Returns keys of the diagnostics in the baseline.
+func get_baseline_keys(diagnostics []diagnostic) []string {
+	var keys []string
+	for _, item := range diagnostics {
+		keys = append(keys, item.baseline_key())
+	}
+	return keys
+}
+
+// Writes diagnostics as a SARIF report (for example to show them in pull requests).
+func write_sarif(path string, diagnostics []diagnostic) {
+	var results []common.Sarif_result
+	for _, item := range diagnostics {
+		var baseline_state = "unchanged"
+		if item.is_new {
+			baseline_state = "new"
+		}
+		results = append(results, common.Sarif_result{Rule_id: item.check, Level: item.severity, Message: item.message,
+			Locations:      []common.Sarif_location{{File: item.file, Line: item.line, Column: item.column}},
+			Baseline_state: baseline_state})
+	}
+	common.Write_sarif(path, "clang-tidy", "https://clang.llvm.org/extra/clang-tidy/", results)
+}
@@ -0,0 +1,374 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"common"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Name of the test executable (see ENGINE_TESTS_NAME in src/engine_lib/CMakeLists.txt).
+const tests_executable_name = "engine_tests"
+
+// Runs engine tests that were built with coverage instrumentation and writes coverage reports:
+// HTML ("html" directory), lcov ("coverage.lcov") and a summary with coverage per module ("summary.json",
+// a module is a directory in the source directory, for example "engine_lib").
+//
+// Clang builds (`-fprofile-instr-generate -fcoverage-mapping`) are processed using llvm-profdata and llvm-cov,
+// GCC builds (`--coverage`) using gcovr (version 7 or newer), the tool is selected depending on
+// which coverage data the tests produced.
+//
+// Should be started from the root directory of the repository (paths in reports are relative
+// to the working directory).
+//
+// Flags:
+// --build-dir  (optional) build directory (to search the test executable and GCC coverage data in), "build" by default.
+// --binary     (optional) path to the test executable, searched in the build directory by default.
+// --source-dir (optional) only report coverage of files in this directory, "src" by default.
+// --output-dir (optional) directory to write reports to, "coverage" by default.
+// --min        (optional) comma-separated minimum line coverage (in percents) per module, for example "engine_lib=60,total=50".
+// --timeout    (optional) timeout of the tests in seconds, 1800 by default.
+// --quiet      only print warnings and errors.
+// --verbose    also print debug messages.
+//
+// Flags that are not specified are read from "NE_<FLAG>" environment variables (for example "NE_MIN").
+// Command line arguments always take precedence over environment variables.
+func main() {
+	var build_directory = flag.String("build-dir", "build", "(optional) build directory")
+	var binary = flag.String("binary", "", "(optional) path to the test executable")
+	var source_directory = flag.String("source-dir", "src", "(optional) only report coverage of files in this directory")
+	var output_directory = flag.String("output-dir", "coverage", "(optional) directory to write reports to")
+	var min_coverage = flag.String("min", "", "(optional) comma-separated minimum line coverage per module")
+	var timeout = flag.Int64("timeout", 1800, "(optional) timeout of the tests in seconds")
+	var quiet = flag.Bool("quiet", false, "only print warnings and errors")
+	var verbose = flag.Bool("verbose", false, "also print debug messages")
+
+	flag.Parse()
+	common.Apply_environment_overrides()
+	common.Init_log("run_coverage.go", *quiet, *verbose, false, "")
+
+	var thresholds = parse_thresholds(*min_coverage)
+
+	if *binary == "" {
+		*binary = find_test_executable(*build_directory)
+	}
+	binary_path, err := filepath.Abs(*binary)
+	if err != nil {
+		common.Log_fatal("failed to get absolute path of", *binary, "error:", err)
+	}
+
+	os.RemoveAll(*output_directory)
+	var profile_directory = filepath.Join(*output_directory, "profiles")
+	err = os.MkdirAll(profile_directory, os.ModePerm)
+	if err != nil {
+		common.Log_fatal("failed to create directory", profile_directory, "error:", err)
+	}
+	absolute_profile_directory, err := filepath.Abs(profile_directory)
+	if err != nil {
+		common.Log_fatal("failed to get absolute path of", profile_directory, "error:", err)
+	}
+
+	// GCC accumulates counters of previous runs.
+	remove_gcov_data(*build_directory)
+
+	common.Log_info("running", *binary)
+	var start_time = time.Now()
+	output, err := common.Launch_binary(binary_path, []string{},
+		append(os.Environ(), "LLVM_PROFILE_FILE="+filepath.Join(absolute_profile_directory, "%p.profraw")), *timeout)
+	if err != nil {
+		common.Log_warning("tests failed, coverage may be incomplete, error:", err)
+		common.Log_verbose(strings.TrimSpace(output))
+	}
+	common.Log_info("tests finished in", time.Since(start_time).Round(time.Second))
+
+	var lcov_path = filepath.Join(*output_directory, "coverage.lcov")
+	var html_directory = filepath.Join(*output_directory, "html")
+	profiles, _ := filepath.Glob(filepath.Join(profile_directory, "*.profraw"))
+	if len(profiles) != 0 {
+		generate_llvm_reports(binary_path, profiles, *source_directory, *output_directory, lcov_path, html_directory)
+	} else if has_gcov_data(*build_directory) {
+		generate_gcovr_reports(*build_directory, *source_directory, lcov_path, html_directory)
+	} else {
+		common.Log_fatal("tests produced no coverage data, make sure that they were built with coverage instrumentation")
+	}
+	os.RemoveAll(profile_directory)
+
+	var coverage = get_module_coverage(lcov_path, *source_directory)
+	write_summary(filepath.Join(*output_directory, "summary.json"), coverage)
+
+	var modules []string
+	for module := range coverage {
+		modules = append(modules, module)
+	}
+	sort.Strings(modules)
+	for _, module := range modules {
+		common.Log_info(fmt.Sprintf("%s: %.2f%% (%d of %d lines)", module, coverage[module].percent(),
+			coverage[module].covered_lines, coverage[module].total_lines))
+	}
+	common.Log_info("reports were written to", *output_directory)
+
+	var threshold_modules []string
+	for module := range thresholds {
+		threshold_modules = append(threshold_modules, module)
+	}
+	sort.Strings(threshold_modules)
+
+	var failed_count = 0
+	for _, module := range threshold_modules {
+		var threshold = thresholds[module]
+		var result, ok = coverage[module]
+		if !ok {
+			common.Log_error("module", module, "has no coverage data")
+			failed_count += 1
+		} else if result.percent() < threshold {
+			common.Log_error(fmt.Sprintf("coverage of %s is %.2f%% which is below the minimum of %.2f%%", module, result.percent(), threshold))
+			failed_count += 1
+		}
+	}
+	if failed_count != 0 {
+		common.Log_fatal(failed_count, "module(s) don't have enough coverage")
+	}
+}
+
+// Parses "module=percent" pairs.
+func parse_thresholds(value string) map[string]float64 {
+	var thresholds = map[string]float64{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+
+		module, percent, found := strings.Cut(item, "=")
+		threshold, err := strconv.ParseFloat(strings.TrimSpace(percent), 64)
+		if !found || err != nil || threshold < 0 || threshold > 100 {
+			common.Log_fatal("invalid minimum coverage", "\""+item+"\"", "expected \"module=percent\"")
+		}
+		thresholds[strings.TrimSpace(module)] = threshold
+	}
+	return thresholds
+}
+
+// Returns path to the test executable in the build directory.
+func find_test_executable(build_directory string) string {
+	var executable_name = tests_executable_name
+	if runtime.GOOS == "windows" {
+		executable_name += ".exe"
+	}
+
+	var executables []string
+	var err = filepath.Walk(build_directory, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && path != build_directory && strings.HasPrefix(info.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if !info.IsDir() && info.Name() == executable_name {
+			executables = append(executables, path)
+		}
+		return nil
+	})
+	if err != nil {
+		common.Log_fatal("failed to read directory", build_directory, "error:", err)
+	}
+
+	if len(executables) == 0 {
+		common.Log_fatal("no", executable_name, "executable was found in", build_directory, "(build the tests or specify \"--binary\")")
+	}
+	if len(executables) > 1 {
+		common.Log_fatal("found multiple", executable_name, "executables in", build_directory+",", "specify \"--binary\":", strings.Join(executables, ", "))
+	}
+	return executables[0]
+}
+
+// Runs a tool, stops the script if it fails.
+func run_tool(name string, args ...string) []byte {
+	path, err := exec.LookPath(name)
+	if err != nil {
+		common.Log_fatal(name, "was not found, error:", err)
+	}
+
+	common.Log_verbose(path, strings.Join(args, " "))
+	var output bytes.Buffer
+	var errors bytes.Buffer
+	var command = exec.Command(path, args...)
+	command.Stdout = &output
+	command.Stderr = &errors
+	err = command.Run()
+	if err != nil {
+		common.Log_fatal("failed to run", name+",", "error:", err, "output:", strings.TrimSpace(errors.String()))
+	}
+	return output.Bytes()
+}
+
+// Merges raw profiles of a Clang build and generates reports using llvm-cov.
+func generate_llvm_reports(binary_path string, profiles []string, source_directory string, output_directory string,
+	lcov_path string, html_directory string) {
+	common.Log_info("generating reports using llvm-cov from", len(profiles), "profile(s)")
+
+	var profile_path = filepath.Join(output_directory, "coverage.profdata")
+	run_tool("llvm-profdata", append([]string{"merge", "-sparse", "-o", profile_path}, profiles...)...)
+
+	common.Write_file(lcov_path, run_tool("llvm-cov", "export", binary_path, "-instr-profile="+profile_path,
+		"-format=lcov", source_directory))
+	run_tool("llvm-cov", "show", binary_path, "-instr-profile="+profile_path, "-format=html",
+		"-output-dir="+html_directory, "-show-line-counts-or-regions", source_directory)
+}
+
+// Generates reports of a GCC build using gcovr.
+func generate_gcovr_reports(build_directory string, source_directory string, lcov_path string, html_directory string) {
+	common.Log_info("generating reports using gcovr")
+
+	var err = os.MkdirAll(html_directory, os.ModePerm)
+	if err != nil {
+		common.Log_fatal("failed to create directory", html_directory, "error:", err)
+	}
+
+	var filter = regexp_quote(filepath.ToSlash(filepath.Clean(source_directory))) + "/"
+	run_tool("gcovr", "--root", ".", "--object-directory", build_directory, "--filter", filter,
+		"--lcov", lcov_path, "--html-details", filepath.Join(html_directory, "index.html"))
+}
+
+// Escapes characters of the path that have a special meaning in regular expressions (used by gcovr filters).
+func regexp_quote(path string) string {
+	var quoted strings.Builder
+	for _, character := range path {
+		if strings.ContainsRune(`\.+*?()|[]{}^$`, character) {
+			quoted.WriteRune('\\')
+		}
+		quoted.WriteRune(character)
+	}
+	return quoted.String()
+}
+
+// Removes ".gcda" files (coverage counters of GCC builds) from the build directory.
+func remove_gcov_data(build_directory string) {
+	filepath.Walk(build_directory, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() && filepath.Ext(path) == ".gcda" {
+			os.Remove(path)
+		}
+		return nil
+	})
+}
+
+// Tells if the build directory has ".gcda" files.
+func has_gcov_data(build_directory string) bool {
+	var found = false
+	filepath.Walk(build_directory, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() && filepath.Ext(path) == ".gcda" {
+			found = true
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	return found
+}
+
+// Line coverage of a module.
+type line_coverage struct {
+	covered_lines int
+	total_lines   int
+}
+
+func (c line_coverage) percent() float64 {
+	if c.total_lines == 0 {
+		return 100
+	}
+	return float64(c.covered_lines) * 100 / float64(c.total_lines)
+}
+
+// Returns line coverage per module (and "total") from the lcov report.
+func get_module_coverage(lcov_path string, source_directory string) map[string]line_coverage {
+	file, err := os.Open(lcov_path)
+	if err != nil {
+		common.Log_fatal("failed to open", lcov_path, "error:", err)
+	}
+	defer file.Close()
+
+	absolute_source_directory, err := filepath.Abs(source_directory)
+	if err != nil {
+		common.Log_fatal("failed to get absolute path of", source_directory, "error:", err)
+	}
+
+	var coverage = map[string]line_coverage{"total": {}}
+	var module = ""
+	var scanner = bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var line = strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "SF:"):
+			module = get_module(strings.TrimPrefix(line, "SF:"), absolute_source_directory)
+		case strings.HasPrefix(line, "LH:") || strings.HasPrefix(line, "LF:"):
+			if module == "" {
+				continue
+			}
+			count, _ := strconv.Atoi(line[3:])
+			for _, name := range []string{module, "total"} {
+				var result = coverage[name]
+				if strings.HasPrefix(line, "LH:") {
+					result.covered_lines += count
+				} else {
+					result.total_lines += count
+				}
+				coverage[name] = result
+			}
+		case line == "end_of_record":
+			module = ""
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		common.Log_fatal("failed to read", lcov_path, "error:", err)
+	}
+
+	return coverage
+}
+
+// Returns the name of the directory in the source directory that contains the file
+// or an empty string if the file is not in the source directory.
+func get_module(path string, absolute_source_directory string) string {
+	absolute_path, err := filepath.Abs(path)
+	if err != nil {
+		return ""
+	}
+	relative_path, err := filepath.Rel(absolute_source_directory, absolute_path)
+	if err != nil || strings.HasPrefix(relative_path, "..") {
+		return ""
+	}
+	var parts = strings.Split(filepath.ToSlash(relative_path), "/")
+	if len(parts) == 1 {
+		return "."
+	}
+	return parts[0]
+}
+
+// Writes coverage per module as JSON (for example to track it in CI).
+func write_summary(path string, coverage map[string]line_coverage) {
+	type module_summary struct {
+		CoveredLines int     `json:"covered_lines"`
+		TotalLines   int     `json:"total_lines"`
+		Percent      float64 `json:"percent"`
+	}
+
+	var summary = map[string]module_summary{}
+	for module, result := range coverage {
+		summary[module] = module_summary{CoveredLines: result.covered_lines, TotalLines: result.total_lines,
+			Percent: float64(int(result.percent()*100)) / 100}
+	}
+
+	content, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		common.Log_fatal("failed to serialize coverage summary, error:", err)
+	}
+	common.Write_file(path, append(content, '\n'))
+}
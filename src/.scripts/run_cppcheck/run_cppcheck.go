@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"common"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Runs cppcheck on the engine sources and writes XML/SARIF reports.
+//
+// If "--build-dir" is specified files and include directories are taken from compile_commands.json
+// (generated by CMake with `-DCMAKE_EXPORT_COMPILE_COMMANDS=ON`), otherwise all files of the source
+// directory are checked with the engine's include directories.
+//
+// Should be started from the root directory of the repository (paths in reports are relative
+// to the working directory).
+//
+// Flags:
+// --source-dir   (optional) directory to check, "src" by default.
+// --build-dir    (optional) path to the directory with compile_commands.json.
+// --include      (optional) comma-separated include directories (used without "--build-dir").
+// --cppcheck     (optional) path to cppcheck, looked up in PATH and default install locations by default.
+// --suppressions (optional) path to the suppressions file, ".cppcheck-suppressions" is used if it exists.
+// --jobs         (optional) number of files to check in parallel, number of CPUs by default.
+// --xml          (optional) path to the XML report (cppcheck format) to write.
+// --sarif        (optional) path to the SARIF report to write.
+// --quiet        only print warnings and errors.
+// --verbose      also print debug messages.
+//
+// Flags that are not specified are read from "NE_<FLAG>" environment variables (for example "NE_BUILD_DIR").
+// Command line arguments always take precedence over environment variables.
+func main() {
+	var source_directory = flag.String("source-dir", "src", "(optional) directory to check")
+	var build_directory = flag.String("build-dir", "", "(optional) path to the directory with compile_commands.json")
+	var include = flag.String("include", "src/engine_lib/public,src/engine_lib/private",
+		"(optional) comma-separated include directories (used without \"--build-dir\")")
+	var cppcheck = flag.String("cppcheck", "", "(optional) path to cppcheck")
+	var suppressions_path = flag.String("suppressions", "", "(optional) path to the suppressions file")
+	var jobs = flag.Int("jobs", runtime.NumCPU(), "(optional) number of files to check in parallel")
+	var xml_path = flag.String("xml", "", "(optional) path to the XML report to write")
+	var sarif_path = flag.String("sarif", "", "(optional) path to the SARIF report to write")
+	var quiet = flag.Bool("quiet", false, "only print warnings and errors")
+	var verbose = flag.Bool("verbose", false, "also print debug messages")
+
+	flag.Parse()
+	common.Apply_environment_overrides()
+	common.Init_log("run_cppcheck.go", *quiet, *verbose, false, "")
+
+	if *jobs < 1 {
+		*jobs = 1
+	}
+
+	var cppcheck_path = find_cppcheck(*cppcheck)
+	common.Log_verbose("using", cppcheck_path)
+
+	var args = []string{"--enable=warning,style,performance,portability", "--inline-suppr",
+		"--xml", "--xml-version=2", "-j", strconv.Itoa(*jobs), "--quiet"}
+
+	if *suppressions_path == "" {
+		if _, err := os.Stat(".cppcheck-suppressions"); err == nil {
+			*suppressions_path = ".cppcheck-suppressions"
+		}
+	}
+	if *suppressions_path != "" {
+		if _, err := os.Stat(*suppressions_path); err != nil {
+			common.Log_fatal("suppressions file", *suppressions_path, "does not exist")
+		}
+		args = append(args, "--suppressions-list="+*suppressions_path)
+	}
+
+	absolute_source_directory, err := filepath.Abs(*source_directory)
+	if err != nil {
+		common.Log_fatal("failed to get absolute path of", *source_directory, "error:", err)
+	}
+
+	if *build_directory != "" {
+		var database_path = filepath.Join(*build_directory, "compile_commands.json")
+		if _, err := os.Stat(database_path); err != nil {
+			common.Log_fatal(database_path, "does not exist (configure CMake with -DCMAKE_EXPORT_COMPILE_COMMANDS=ON)")
+		}
+		args = append(args, "--project="+database_path, "--file-filter="+absolute_source_directory+"/*")
+	} else {
+		for _, directory := range strings.Split(*include, ",") {
+			if directory = strings.TrimSpace(directory); directory != "" {
+				args = append(args, "-I", directory)
+			}
+		}
+		args = append(args, *source_directory)
+	}
+
+	common.Log_info("running cppcheck on", *source_directory)
+	common.Log_verbose(cppcheck_path, strings.Join(args, " "))
+
+	// Results in XML format are written to stderr.
+	var results bytes.Buffer
+	var output bytes.Buffer
+	var command = exec.Command(cppcheck_path, args...)
+	command.Stdout = &output
+	command.Stderr = &results
+	err = command.Run()
+	if err != nil {
+		common.Log_fatal("failed to run cppcheck, error:", err, "output:", strings.TrimSpace(output.String()+results.String()))
+	}
+
+	var report cppcheck_results
+	err = xml.Unmarshal(results.Bytes(), &report)
+	if err != nil {
+		common.Log_fatal("failed to parse cppcheck results, error:", err)
+	}
+
+	if *xml_path != "" {
+		common.Write_file(*xml_path, results.Bytes())
+		common.Log_info("XML report was written to", *xml_path)
+	}
+	if *sarif_path != "" {
+		write_sarif(*sarif_path, report.Errors)
+		common.Log_info("SARIF report was written to", *sarif_path)
+	}
+
+	var problem_count = 0
+	for _, item := range report.Errors {
+		if item.Severity == "information" {
+			common.Log_verbose(item.String())
+			continue
+		}
+		common.Log_error(item.String())
+		problem_count += 1
+	}
+
+	if problem_count != 0 {
+		common.Log_fatal("cppcheck found", problem_count, "problem(s)")
+	}
+
+	common.Log_info("cppcheck found no problems")
+}
+
+// Returns path to cppcheck: the specified path, cppcheck from PATH or from the default install
+// location (official builds are only distributed as installers so cppcheck is not downloaded).
+func find_cppcheck(path string) string {
+	if path != "" {
+		found_path, err := exec.LookPath(path)
+		if err != nil {
+			common.Log_fatal("cppcheck was not found at", path, "error:", err)
+		}
+		return found_path
+	}
+
+	if found_path, err := exec.LookPath("cppcheck"); err == nil {
+		return found_path
+	}
+
+	if runtime.GOOS == "windows" {
+		for _, variable := range []string{"ProgramFiles", "ProgramFiles(x86)"} {
+			var candidate = filepath.Join(os.Getenv(variable), "Cppcheck", "cppcheck.exe")
+			if _, err := os.Stat(candidate); err == nil {
+				return candidate
+			}
+		}
+	}
+
+	common.Log_fatal("cppcheck was not found, install it (https://cppcheck.sourceforge.io) or specify \"--cppcheck\"")
+	return ""
+}
+
+// Results of cppcheck in XML format (version 2).
+type cppcheck_results struct {
+	Errors []cppcheck_error `xml:"errors>error"`
+}
+
+type cppcheck_error struct {
+	Id        string              `xml:"id,attr"`
+	Severity  string              `xml:"severity,attr"`
+	Message   string              `xml:"msg,attr"`
+	Locations []cppcheck_location `xml:"location"`
+}
+
+type cppcheck_location struct {
+	File   string `xml:"file,attr"`
+	Line   int    `xml:"line,attr"`
+	Column int    `xml:"column,attr"`
+}
+
+func (e cppcheck_error) String() string {
+	if len(e.Locations) == 0 {
+		return fmt.Sprintf("%s: %s [%s]", e.Severity, e.Message, e.Id)
+	}
+	var location = e.Locations[0]
+	return fmt.Sprintf("%s:%d:%d: %s: %s [%s]", common.Get_relative_path(location.File), location.Line, location.Column,
+		e.Severity, e.Message, e.Id)
+}
+
+// Writes cppcheck results as a SARIF report (for example to show them in pull requests).
+func write_sarif(path string, errors []cppcheck_error) {
+	var results []common.Sarif_result
+	for _, item := range errors {
+		// SARIF levels are "error", "warning" and "note".
+		var level = "warning"
+		switch item.Severity {
+		case "error":
+			level = "error"
+		case "style", "performance", "portability", "information":
+			level = "note"
+		}
+
+		var result = common.Sarif_result{Rule_id: item.Id, Level: level, Message: item.Message}
+		for _, location := range item.Locations {
+			result.Locations = append(result.Locations,
+				common.Sarif_location{File: common.Get_relative_path(location.File), Line: location.Line, Column: location.Column})
+		}
+		results = append(results, result)
+	}
+	common.Write_sarif(path, "cppcheck", "https://cppcheck.sourceforge.io", results)
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bytes"
+	"common"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Runs Doxygen with the repository's Doxyfile and fails if Doxygen reports warnings
+// (for example undocumented entities) that are not in the baseline.
+//
+// The Doxyfile is used as-is (including its PREDEFINED macros) except that warnings are collected
+// instead of being treated as errors and no documentation is generated. Macros from "--predefined"
+// are added to the PREDEFINED list and only predefined macros are expanded, so macros that
+// Doxygen can't parse (for example reflection macros) can be defined as empty: `--predefined="RCLASS(...)="`.
+//
+// Should be started from the root directory of the repository (paths in the baseline
+// are relative to the working directory).
+//
+// Flags:
+// --doxyfile        (optional) path to the Doxyfile, "docs/Doxyfile" by default.
+// --doxygen         (optional) path to Doxygen, "doxygen" from PATH by default.
+// --predefined      (optional) comma-separated macros to add to the PREDEFINED list (in form "NAME=value").
+// --baseline        (optional) path to the file with known warnings, only new warnings fail the check.
+// --update-baseline (optional) write all found warnings to the baseline file and exit successfully.
+// --quiet           only print warnings and errors.
+// --verbose         also print debug messages.
+//
+// Flags that are not specified are read from "NE_<FLAG>" environment variables (for example "NE_BASELINE").
+// Command line arguments always take precedence over environment variables.
+func main() {
+	var doxyfile_path = flag.String("doxyfile", "docs/Doxyfile", "(optional) path to the Doxyfile")
+	var doxygen = flag.String("doxygen", "doxygen", "(optional) path to Doxygen")
+	var predefined = flag.String("predefined", "", "(optional) comma-separated macros to add to the PREDEFINED list")
+	var baseline_path = flag.String("baseline", "", "(optional) path to the file with known warnings")
+	var update_baseline = flag.Bool("update-baseline", false, "(optional) write all found warnings to the baseline file")
+	var quiet = flag.Bool("quiet", false, "only print warnings and errors")
+	var verbose = flag.Bool("verbose", false, "also print debug messages")
+
+	flag.Parse()
+	common.Apply_environment_overrides()
+	common.Init_log("run_doxygen.go", *quiet, *verbose, false, "")
+
+	if *update_baseline && *baseline_path == "" {
+		common.Log_fatal("\"--update-baseline\" requires \"--baseline\"")
+	}
+
+	doxygen_path, err := exec.LookPath(*doxygen)
+	if err != nil {
+		common.Log_fatal("Doxygen was not found (https://www.doxygen.nl), specify \"--doxygen\", error:", err)
+	}
+
+	var macros []string
+	for _, macro := range strings.Split(*predefined, ",") {
+		if macro = strings.TrimSpace(macro); macro != "" {
+			macros = append(macros, macro)
+		}
+	}
+
+	common.Log_info("running Doxygen using", *doxyfile_path)
+	var warnings = run_doxygen(doxygen_path, *doxyfile_path, macros)
+
+	if *update_baseline {
+		common.Write_baseline(*baseline_path, "# Known Doxygen warnings (see run_doxygen.go), regenerate using \"--update-baseline\".",
+			get_baseline_keys(warnings))
+		common.Log_info("baseline with", len(warnings), "warning(s) was written to", *baseline_path)
+		return
+	}
+
+	var baseline = map[string]int{}
+	if *baseline_path != "" {
+		baseline = common.Read_baseline(*baseline_path)
+	}
+	is_new, new_count := common.Find_new_warnings(get_baseline_keys(warnings), baseline)
+	for i := range warnings {
+		warnings[i].is_new = is_new[i]
+	}
+
+	for _, warning := range warnings {
+		if warning.is_new {
+			common.Log_error(warning.String())
+		} else {
+			common.Log_verbose("(baseline)", warning.String())
+		}
+	}
+
+	if new_count != 0 {
+		common.Log_fatal("Doxygen reported", new_count, "new warning(s) (of", len(warnings), "total)")
+	}
+
+	common.Log_info("no new warnings were found,", len(warnings), "known warning(s) are in the baseline")
+}
+
+// A warning reported by Doxygen.
+type doxygen_warning struct {
+	file    string // relative to the working directory, empty if the warning is not about a file
+	line    int
+	message string
+	is_new  bool // not in the baseline
+}
+
+func (w doxygen_warning) String() string {
+	if w.file == "" {
+		return "warning: " + w.message
+	}
+	return fmt.Sprintf("%s:%d: warning: %s", w.file, w.line, w.message)
+}
+
+// Returns a key of the warning in the baseline (without line numbers so that
+// unrelated changes in the file don't make known warnings new).
+func (w doxygen_warning) baseline_key() string {
+	if w.file == "" {
+		return w.message
+	}
+	return w.file + ": " + w.message
+}
+
+// Matches warnings in form "path:line: warning: message" (see WARN_FORMAT).
+var warning_regexp = regexp.MustCompile(`^(.+?):(\d+): (?:warning|error): (.+)$`)
+
+// Runs Doxygen (in the directory of the Doxyfile since paths in it are relative), returns
+// sorted warnings.
+func run_doxygen(doxygen_path string, doxyfile_path string, macros []string) []doxygen_warning {
+	doxyfile, err := os.ReadFile(doxyfile_path)
+	if err != nil {
+		common.Log_fatal("failed to read", doxyfile_path, "error:", err)
+	}
+
+	output_directory, err := os.MkdirTemp("", "run_doxygen")
+	if err != nil {
+		common.Log_fatal("failed to create temporary directory, error:", err)
+	}
+	defer os.RemoveAll(output_directory)
+
+	// Later values override values from the Doxyfile.
+	var overrides = []string{
+		"WARN_AS_ERROR = NO",
+		"WARN_FORMAT = \"$file:$line: $text\"",
+		"WARN_LOGFILE =",
+		"WARNINGS = YES",
+		"QUIET = YES",
+		"OUTPUT_DIRECTORY = \"" + filepath.ToSlash(output_directory) + "\"",
+		"GENERATE_HTML = NO",
+		"GENERATE_LATEX = NO",
+		"GENERATE_XML = NO",
+	}
+	if len(macros) != 0 {
+		overrides = append(overrides, "MACRO_EXPANSION = YES", "EXPAND_ONLY_PREDEF = YES",
+			"PREDEFINED += \""+strings.Join(macros, "\" \"")+"\"")
+	}
+
+	// "-" makes Doxygen read the configuration from stdin.
+	var output bytes.Buffer
+	var warnings_output bytes.Buffer
+	var command = exec.Command(doxygen_path, "-")
+	command.Dir = filepath.Dir(doxyfile_path)
+	command.Stdin = strings.NewReader(string(doxyfile) + "\n" + strings.Join(overrides, "\n") + "\n")
+	command.Stdout = &output
+	command.Stderr = &warnings_output
+	err = command.Run()
+	if err != nil {
+		common.Log_fatal("failed to run Doxygen, error:", err, "output:", strings.TrimSpace(output.String()+warnings_output.String()))
+	}
+	common.Log_verbose(strings.TrimSpace(output.String()))
+
+	return parse_warnings(warnings_output.String(), filepath.Dir(doxyfile_path))
+}
+
+// Parses Doxygen warnings (multiline warnings are joined), returns sorted unique warnings.
+func parse_warnings(output string, doxyfile_directory string) []doxygen_warning {
+	var unique_warnings = map[string]doxygen_warning{}
+	var last_key = ""
+	for _, line := range strings.Split(strings.ReplaceAll(output, "\r\n", "\n"), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		var warning doxygen_warning
+		if match := warning_regexp.FindStringSubmatch(line); match != nil {
+			fmt.Sscan(match[2], &warning.line)
+			warning.file = get_relative_path(match[1], doxyfile_directory)
+			warning.message = strings.TrimSpace(match[3])
+		} else if strings.HasPrefix(line, "warning: ") || strings.HasPrefix(line, "error: ") {
+			_, warning.message, _ = strings.Cut(line, ": ")
+		} else if last_key != "" {
+			// Continuation of the previous warning.
+			var previous = unique_warnings[last_key]
+			delete(unique_warnings, last_key)
+			previous.message += " " + strings.TrimSpace(line)
+			last_key = previous.String()
+			unique_warnings[last_key] = previous
+			continue
+		} else {
+			common.Log_verbose(line)
+			continue
+		}
+
+		last_key = warning.String()
+		unique_warnings[last_key] = warning
+	}
+
+	var warnings []doxygen_warning
+	for _, warning := range unique_warnings {
+		warnings = append(warnings, warning)
+	}
+	sort.Slice(warnings, func(i, j int) bool {
+		if warnings[i].file != warnings[j].file {
+			return warnings[i].file < warnings[j].file
+		}
+		if warnings[i].line != warnings[j].line {
+			return warnings[i].line < warnings[j].line
+		}
+		return warnings[i].message < warnings[j].message
+	})
+	return warnings
+}
+
+// Returns the path relative to the working directory (if possible) with '/' as separator,
+// relative paths are relative to the directory of the Doxyfile.
+func get_relative_path(path string, doxyfile_directory string) string {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(doxyfile_directory, path)
+	}
+	var working_directory, _ = os.Getwd()
+	if absolute_path, err := filepath.Abs(path); err == nil {
+		if relative_path, err := filepath.Rel(working_directory, absolute_path); err == nil && !strings.HasPrefix(relative_path, "..") {
+			path = relative_path
+		}
+	}
+	return filepath.ToSlash(filepath.Clean(path))
+}
+
+// Returns keys of the warnings in the baseline.
+func get_baseline_keys(warnings []doxygen_warning) []string {
+	var keys []string
+	for _, item := range warnings {
+		keys = append(keys, item.baseline_key())
+	}
+	return keys
+}
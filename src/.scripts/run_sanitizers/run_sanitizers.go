@@ -0,0 +1,238 @@
+package main
+
+import (
+	"common"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Sanitizers that are configured using "<NAME>_OPTIONS" environment variables
+// (suppressions are read from "<name>.supp" files in the suppressions directory).
+var sanitizers = []string{"asan", "lsan", "ubsan", "tsan"}
+
+// Runs a binary (for example engine_tests or the game) that was built with sanitizers (ASan/LSan/UBSan/TSan),
+// collects sanitizer reports (they are written to log files instead of being mixed with the output
+// of the binary), removes duplicates and fails if there are any reports.
+//
+// Arguments after "--" are passed to the binary, for example:
+//
+//	go run . --binary=build/engine_tests -- "create simple window"
+//
+// Existing "<NAME>_OPTIONS" environment variables are kept (their options take precedence).
+//
+// Flags:
+// --binary           path to the binary to run (required).
+// --timeout          (optional) timeout in seconds, 1800 by default.
+// --log-dir          (optional) directory to write sanitizer logs to, "sanitizer_logs" next to the binary by default.
+// --suppressions-dir (optional) directory with "asan.supp", "lsan.supp", "ubsan.supp" and "tsan.supp", "tests/sanitizers" by default.
+// --quiet            only print warnings and errors.
+// --verbose          also print debug messages (including output of the binary).
+//
+// Flags that are not specified are read from "NE_<FLAG>" environment variables (for example "NE_BINARY").
+// Command line arguments always take precedence over environment variables.
+func main() {
+	var binary = flag.String("binary", "", "path to the binary to run")
+	var timeout = flag.Int64("timeout", 1800, "(optional) timeout in seconds")
+	var log_directory = flag.String("log-dir", "", "(optional) directory to write sanitizer logs to")
+	var suppressions_directory = flag.String("suppressions-dir", "tests/sanitizers", "(optional) directory with suppression files")
+	var quiet = flag.Bool("quiet", false, "only print warnings and errors")
+	var verbose = flag.Bool("verbose", false, "also print debug messages")
+
+	flag.Parse()
+	common.Apply_environment_overrides()
+	common.Init_log("run_sanitizers.go", *quiet, *verbose, false, "")
+
+	if *binary == "" {
+		common.Log_fatal("\"--binary\" is required")
+	}
+	binary_path, err := filepath.Abs(*binary)
+	if err != nil {
+		common.Log_fatal("failed to get absolute path of", *binary, "error:", err)
+	}
+	if _, err := os.Stat(binary_path); err != nil {
+		common.Log_fatal("binary", *binary, "does not exist")
+	}
+
+	if *log_directory == "" {
+		*log_directory = filepath.Join(filepath.Dir(*binary), "sanitizer_logs")
+	}
+
+	// Logs of previous runs should not be reported again.
+	os.RemoveAll(*log_directory)
+	err = os.MkdirAll(*log_directory, os.ModePerm)
+	if err != nil {
+		common.Log_fatal("failed to create directory", *log_directory, "error:", err)
+	}
+	absolute_log_directory, err := filepath.Abs(*log_directory)
+	if err != nil {
+		common.Log_fatal("failed to get absolute path of", *log_directory, "error:", err)
+	}
+
+	var environment = append(os.Environ(), get_sanitizer_environment(absolute_log_directory, *suppressions_directory)...)
+
+	common.Log_info("running", *binary, strings.Join(flag.Args(), " "))
+	var start_time = time.Now()
+	output, run_err := common.Launch_binary(binary_path, flag.Args(), environment, *timeout)
+	common.Log_verbose(strings.TrimSpace(output))
+	common.Log_info("binary finished in", time.Since(start_time).Round(time.Second))
+
+	var reports = read_sanitizer_reports(*log_directory, output)
+	for _, report := range reports {
+		common.Log_error(report.String())
+	}
+	if len(reports) != 0 {
+		print_summary(reports)
+	}
+
+	if len(reports) != 0 {
+		common.Log_fatal("sanitizers found", len(reports), "problem(s)")
+	}
+	if run_err != nil {
+		common.Log_fatal("binary failed:", run_err, "(no sanitizer reports were found)")
+	}
+
+	common.Log_info("sanitizers found no problems")
+}
+
+// Returns "<NAME>_OPTIONS" environment variables that make sanitizers write reports to the log directory.
+func get_sanitizer_environment(log_directory string, suppressions_directory string) []string {
+	var environment []string
+	for _, sanitizer := range sanitizers {
+		// Sanitizers that are linked together share common options so the log path is the same for all of them.
+		var options = []string{"log_path=" + filepath.Join(log_directory, "sanitizer"), "print_stacktrace=1"}
+		if sanitizer == "asan" {
+			options = append(options, "detect_leaks=1")
+		}
+
+		var suppressions_path = filepath.Join(suppressions_directory, sanitizer+".supp")
+		if _, err := os.Stat(suppressions_path); err == nil {
+			absolute_path, err := filepath.Abs(suppressions_path)
+			if err != nil {
+				common.Log_fatal("failed to get absolute path of", suppressions_path, "error:", err)
+			}
+			options = append(options, "suppressions="+absolute_path)
+			common.Log_verbose("using suppressions", suppressions_path)
+		}
+
+		var name = strings.ToUpper(sanitizer) + "_OPTIONS"
+		if existing_options := os.Getenv(name); existing_options != "" {
+			options = append(options, existing_options)
+		}
+		environment = append(environment, name+"="+strings.Join(options, ":"))
+	}
+	return environment
+}
+
+// A report of a sanitizer.
+type sanitizer_report struct {
+	sanitizer string // for example "AddressSanitizer"
+	kind      string // for example "heap-use-after-free"
+	summary   string // location of the problem
+	log_file  string
+	count     int // how many times the problem was reported
+}
+
+func (r sanitizer_report) String() string {
+	var text = fmt.Sprintf("%s: %s", r.sanitizer, r.kind)
+	if r.summary != "" {
+		text += " " + r.summary
+	}
+	if r.count > 1 {
+		text += fmt.Sprintf(" (reported %d times)", r.count)
+	}
+	return text + " (see " + r.log_file + ")"
+}
+
+// Matches "SUMMARY: AddressSanitizer: heap-use-after-free file.cpp:10 in function".
+var summary_regexp = regexp.MustCompile(`^SUMMARY: (\w+): (\S+)(?: (.+))?$`)
+
+// Matches "file.cpp:10:5: runtime error: message" (UBSan without summaries).
+var runtime_error_regexp = regexp.MustCompile(`^(.+?:\d+:\d+): runtime error: (.+)$`)
+
+// Reads sanitizer logs and the output of the binary (some reports are printed before sanitizers
+// read their options), returns unique reports.
+func read_sanitizer_reports(log_directory string, output string) []sanitizer_report {
+	entries, err := os.ReadDir(log_directory)
+	if err != nil {
+		common.Log_fatal("failed to read directory", log_directory, "error:", err)
+	}
+
+	var unique_reports = map[string]*sanitizer_report{}
+	for _, entry := range entries {
+		var log_path = filepath.Join(log_directory, entry.Name())
+		content, err := os.ReadFile(log_path)
+		if err != nil {
+			common.Log_fatal("failed to read", log_path, "error:", err)
+		}
+		parse_sanitizer_reports(unique_reports, string(content), log_path)
+	}
+	parse_sanitizer_reports(unique_reports, output, "output of the binary")
+
+	var reports []sanitizer_report
+	for _, report := range unique_reports {
+		reports = append(reports, *report)
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].String() < reports[j].String()
+	})
+	return reports
+}
+
+// Adds reports found in the text to unique reports.
+func parse_sanitizer_reports(unique_reports map[string]*sanitizer_report, text string, source string) {
+	var found_ubsan_summary = false
+	var runtime_errors []sanitizer_report
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		line = strings.TrimSpace(line)
+		if match := summary_regexp.FindStringSubmatch(line); match != nil {
+			add_report(unique_reports, sanitizer_report{sanitizer: match[1], kind: match[2], summary: match[3], log_file: source})
+			found_ubsan_summary = found_ubsan_summary || match[1] == "UndefinedBehaviorSanitizer"
+		} else if match := runtime_error_regexp.FindStringSubmatch(line); match != nil {
+			runtime_errors = append(runtime_errors, sanitizer_report{
+				sanitizer: "UndefinedBehaviorSanitizer", kind: "runtime-error", summary: match[1] + ": " + match[2], log_file: source})
+		}
+	}
+
+	// UBSan does not always print a summary (for example with "-fsanitize-minimal-runtime").
+	if !found_ubsan_summary {
+		for _, report := range runtime_errors {
+			add_report(unique_reports, report)
+		}
+	}
+}
+
+// Adds the report or increments the counter if the same problem was already reported (for example by another process).
+func add_report(unique_reports map[string]*sanitizer_report, report sanitizer_report) {
+	var key = report.sanitizer + ": " + report.kind + " " + report.summary
+	if existing, ok := unique_reports[key]; ok {
+		existing.count += 1
+		return
+	}
+	report.count = 1
+	unique_reports[key] = &report
+}
+
+// Prints the number of problems per sanitizer and kind.
+func print_summary(reports []sanitizer_report) {
+	var counts = map[string]int{}
+	for _, report := range reports {
+		counts[report.sanitizer+": "+report.kind] += 1
+	}
+
+	var kinds []string
+	for kind := range counts {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+
+	common.Log_error("summary:")
+	for _, kind := range kinds {
+		common.Log_error(fmt.Sprintf("    %s: %d", kind, counts[kind]))
+	}
+}
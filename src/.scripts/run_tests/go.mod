@@ -0,0 +1,7 @@
+module run_tests
+
+go 1.18
+
+require common v0.0.0
+
+replace common => ../common
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"bytes"
+	"common"
+	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Name of the test executables (see ENGINE_TESTS_NAME in src/engine_lib/CMakeLists.txt).
+const tests_executable_name = "engine_tests"
+
+// Runs engine tests (Catch2) and writes a JUnit XML report.
+//
+// Test executables are found in the build directory (or specified using "--tests"), each test case
+// is started in a separate process (in the directory of the executable) so that a test that hangs
+// or crashes does not stop other tests. Known flaky tests (listed in the flaky tests file,
+// one test name per line, '#' starts a comment) are retried if they fail.
+//
+// Flags:
+// --build-dir (optional) directory to search test executables in, "build" by default.
+// --tests     (optional) comma-separated paths to test executables (used instead of searching the build directory).
+// --timeout   (optional) timeout of a test case in seconds, 300 by default.
+// --retries   (optional) how many times to retry failed flaky tests, 2 by default.
+// --flaky     (optional) path to the file with names of flaky tests, "tests/flaky_tests.txt" by default.
+// --junit     (optional) path to the JUnit XML report to write.
+// --quiet     only print warnings and errors.
+// --verbose   also print debug messages (including output of passed tests).
+//
+// Flags that are not specified are read from "NE_<FLAG>" environment variables (for example "NE_BUILD_DIR").
+// Command line arguments always take precedence over environment variables.
+func main() {
+	var build_directory = flag.String("build-dir", "build", "(optional) directory to search test executables in")
+	var tests = flag.String("tests", "", "(optional) comma-separated paths to test executables")
+	var timeout = flag.Int64("timeout", 300, "(optional) timeout of a test case in seconds")
+	var retries = flag.Int("retries", 2, "(optional) how many times to retry failed flaky tests")
+	var flaky_path = flag.String("flaky", "tests/flaky_tests.txt", "(optional) path to the file with names of flaky tests")
+	var junit_path = flag.String("junit", "", "(optional) path to the JUnit XML report to write")
+	var quiet = flag.Bool("quiet", false, "only print warnings and errors")
+	var verbose = flag.Bool("verbose", false, "also print debug messages")
+
+	flag.Parse()
+	common.Apply_environment_overrides()
+	common.Init_log("run_tests.go", *quiet, *verbose, false, "")
+
+	if *timeout < 1 {
+		common.Log_fatal("\"--timeout\" should be positive")
+	}
+	if *retries < 0 {
+		*retries = 0
+	}
+
+	var executables []string
+	for _, path := range strings.Split(*tests, ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			if _, err := os.Stat(path); err != nil {
+				common.Log_fatal("test executable", path, "does not exist")
+			}
+			executables = append(executables, path)
+		}
+	}
+	if len(executables) == 0 {
+		executables = find_test_executables(*build_directory)
+	}
+
+	var flaky_tests = read_flaky_tests(*flaky_path)
+
+	var report junit_report
+	var failed_count = 0
+	for _, executable := range executables {
+		var suite = run_test_executable(executable, *timeout, *retries, flaky_tests)
+		failed_count += suite.Failures + suite.Errors
+		report.add_suite(suite)
+	}
+
+	if *junit_path != "" {
+		write_junit_report(*junit_path, report)
+		common.Log_info("JUnit report was written to", *junit_path)
+	}
+
+	if failed_count != 0 {
+		common.Log_fatal(failed_count, "of", report.Tests, "test(s) failed")
+	}
+
+	common.Log_info("all", report.Tests, "test(s) passed in", time.Duration(report.Time*float64(time.Second)).Round(time.Second))
+}
+
+// Returns paths to test executables in the build directory (for example one per build configuration).
+func find_test_executables(build_directory string) []string {
+	var executable_name = tests_executable_name
+	if runtime.GOOS == "windows" {
+		executable_name += ".exe"
+	}
+
+	var executables []string
+	var err = filepath.Walk(build_directory, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && path != build_directory && strings.HasPrefix(info.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if !info.IsDir() && info.Name() == executable_name {
+			executables = append(executables, path)
+		}
+		return nil
+	})
+	if err != nil {
+		common.Log_fatal("failed to read directory", build_directory, "error:", err)
+	}
+
+	if len(executables) == 0 {
+		common.Log_fatal("no", executable_name, "executables were found in", build_directory, "(build the tests or specify \"--tests\")")
+	}
+
+	sort.Strings(executables)
+	return executables
+}
+
+// Reads names of flaky tests, returns an empty set if the file does not exist.
+func read_flaky_tests(path string) map[string]bool {
+	var flaky_tests = map[string]bool{}
+
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		common.Log_verbose("flaky tests file", path, "does not exist")
+		return flaky_tests
+	}
+	if err != nil {
+		common.Log_fatal("failed to read", path, "error:", err)
+	}
+
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			flaky_tests[line] = true
+		}
+	}
+	return flaky_tests
+}
+
+// Returns names of test cases of the executable.
+func list_test_cases(executable string) []string {
+	var output bytes.Buffer
+	var command = exec.Command(executable, "--list-tests", "--reporter", "xml")
+	command.Dir = filepath.Dir(executable)
+	command.Stdout = &output
+	command.Stderr = &output
+	var err = command.Run()
+	if err != nil {
+		common.Log_fatal("failed to list tests of", executable, "error:", err, "output:", strings.TrimSpace(output.String()))
+	}
+
+	// Names are stored as <TestCase><Name>...</Name></TestCase>.
+	var names []string
+	var decoder = xml.NewDecoder(&output)
+	var path []string
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			common.Log_fatal("failed to parse list of tests of", executable, "error:", err)
+		}
+
+		switch element := token.(type) {
+		case xml.StartElement:
+			path = append(path, element.Name.Local)
+		case xml.EndElement:
+			path = path[:len(path)-1]
+		case xml.CharData:
+			if len(path) >= 2 && path[len(path)-1] == "Name" && path[len(path)-2] == "TestCase" {
+				names = append(names, strings.TrimSpace(string(element)))
+			}
+		}
+	}
+
+	return names
+}
+
+// Returns a Catch2 test spec that only matches the test with the specified name.
+func get_test_spec(name string) string {
+	var spec strings.Builder
+	for _, character := range name {
+		if strings.ContainsRune(`\,[]*"~`, character) {
+			spec.WriteRune('\\')
+		}
+		spec.WriteRune(character)
+	}
+	return spec.String()
+}
+
+// Runs all test cases of the executable.
+func run_test_executable(executable string, timeout int64, retries int, flaky_tests map[string]bool) junit_suite {
+	// Tests are started in the directory of the executable so the path should not be relative.
+	absolute_path, err := filepath.Abs(executable)
+	if err != nil {
+		common.Log_fatal("failed to get absolute path of", executable, "error:", err)
+	}
+
+	var names = list_test_cases(absolute_path)
+	common.Log_info("running", len(names), "test(s) of", executable)
+
+	var suite = junit_suite{Name: executable, Timestamp: time.Now().Format("2006-01-02T15:04:05")}
+	for _, name := range names {
+		var attempt_count = 1
+		if flaky_tests[name] {
+			attempt_count += retries
+		}
+
+		var test_case = junit_case{Name: name, ClassName: filepath.Base(executable)}
+		var start_time = time.Now()
+		var all_output strings.Builder
+		var output string
+		for attempt := 1; attempt <= attempt_count; attempt++ {
+			common.Log_verbose("running", filepath.Base(absolute_path), get_test_spec(name))
+			output, err = common.Launch_binary(absolute_path, []string{get_test_spec(name)}, nil, timeout)
+			if attempt_count > 1 {
+				fmt.Fprintf(&all_output, "attempt %d of %d:\n", attempt, attempt_count)
+			}
+			all_output.WriteString(output)
+			if err == nil {
+				if attempt > 1 {
+					common.Log_warning("flaky test", "\""+name+"\"", "passed on attempt", attempt, "of", attempt_count)
+				}
+				break
+			}
+			common.Log_verbose("test", "\""+name+"\"", "failed on attempt", attempt, "of", attempt_count, "error:", err)
+		}
+		test_case.Time = time.Since(start_time).Seconds()
+		test_case.SystemOut = &junit_text{Text: all_output.String()}
+
+		var timed_out *common.Timeout_error
+		if errors.As(err, &timed_out) {
+			test_case.Error = &junit_failure{Message: err.Error(), Type: "timeout", Text: output}
+			suite.Errors += 1
+			common.Log_error("test", "\""+name+"\"", err)
+			print_output(output)
+		} else if err != nil {
+			test_case.Failure = &junit_failure{Message: err.Error(), Type: "failure", Text: output}
+			suite.Failures += 1
+			common.Log_error("test", "\""+name+"\"", "failed:", err)
+			print_output(output)
+		} else {
+			common.Log_verbose("test", "\""+name+"\"", "passed")
+		}
+
+		suite.Tests += 1
+		suite.Time += test_case.Time
+		suite.Cases = append(suite.Cases, test_case)
+	}
+
+	return suite
+}
+
+// Prints output of a failed test.
+func print_output(output string) {
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		common.Log_error("    " + strings.TrimRight(line, "\r"))
+	}
+}
+
+// JUnit XML report (the format that is supported by CI dashboards and CTest).
+type junit_report struct {
+	XMLName  xml.Name      `xml:"testsuites"`
+	Tests    int           `xml:"tests,attr"`
+	Failures int           `xml:"failures,attr"`
+	Errors   int           `xml:"errors,attr"`
+	Time     float64       `xml:"time,attr"`
+	Suites   []junit_suite `xml:"testsuite"`
+}
+
+type junit_suite struct {
+	Name      string       `xml:"name,attr"`
+	Tests     int          `xml:"tests,attr"`
+	Failures  int          `xml:"failures,attr"`
+	Errors    int          `xml:"errors,attr"`
+	Time      float64      `xml:"time,attr"`
+	Timestamp string       `xml:"timestamp,attr"`
+	Cases     []junit_case `xml:"testcase"`
+}
+
+type junit_case struct {
+	Name      string         `xml:"name,attr"`
+	ClassName string         `xml:"classname,attr"`
+	Time      float64        `xml:"time,attr"`
+	Failure   *junit_failure `xml:"failure,omitempty"`
+	Error     *junit_failure `xml:"error,omitempty"`
+	SystemOut *junit_text    `xml:"system-out,omitempty"`
+}
+
+type junit_failure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",cdata"`
+}
+
+type junit_text struct {
+	Text string `xml:",cdata"`
+}
+
+func (r *junit_report) add_suite(suite junit_suite) {
+	r.Suites = append(r.Suites, suite)
+	r.Tests += suite.Tests
+	r.Failures += suite.Failures
+	r.Errors += suite.Errors
+	r.Time += suite.Time
+}
+
+func write_junit_report(path string, report junit_report) {
+	content, err := xml.MarshalIndent(report, "", "  ")
+	if err != nil {
+		common.Log_fatal("failed to serialize JUnit report, error:", err)
+	}
+
+	err = os.WriteFile(path, []byte(xml.Header+string(content)+"\n"), 0644)
+	if err != nil {
+		common.Log_fatal("failed to write", path, "error:", err)
+	}
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"common"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// Directory with the DXC download script (DXC builds are only downloaded on Windows).
+const dxc_directory = "ext/DirectXShaderCompiler"
+
+// Directory with the glslang download script (glslang is only used to validate shaders so it's not in "ext").
+const glslang_directory = "src/.scripts/download_glslang"
+
+// Shader models that are used by the engine (see HlslShader.h).
+var hlsl_shader_models = map[string]string{"vs": "vs_6_0", "ps": "ps_6_0", "cs": "cs_6_0"}
+
+// Stages of GLSL shaders by file extension.
+var glsl_stages = map[string]string{".vert": "vert", ".frag": "frag", ".comp": "comp"}
+
+// Shader types ("vs", "ps" or "cs") of GLSL stages.
+var glsl_shader_types = map[string]string{"vert": "vs", "frag": "ps", "comp": "cs"}
+
+// Texture filtering macros, one of them is always defined in pixel shaders.
+var texture_filtering_macros = []string{"TEXTURE_FILTERING_POINT", "TEXTURE_FILTERING_LINEAR", "TEXTURE_FILTERING_ANISOTROPIC"}
+
+// Matches entry functions of HLSL shaders ("VertexOut vsDefault(VertexIn vertexIn)"),
+// entry functions should start with "vs", "ps" or "cs" followed by an uppercase letter.
+var hlsl_entry_regexp = regexp.MustCompile(`(?m)^[ \t]*\w+[ \t]+((vs|ps|cs)[A-Z]\w*)[ \t]*\(`)
+
+// Compiles every shader in the shaders directory with every configuration of macros that is used
+// by the engine (see ShaderParameterConfigurations in ShaderParameter.h): HLSL shaders using DXC
+// and GLSL shaders using glslangValidator. Shader errors are otherwise only reported when the engine starts.
+//
+// HLSL entry functions are found by their names ("vs*", "ps*" or "cs*"), GLSL stages by file
+// extensions (".vert", ".frag" and ".comp", optionally followed by ".glsl"), other files are considered
+// to be included files.
+//
+// DXC and glslang are downloaded using scripts from "ext/DirectXShaderCompiler" and "src/.scripts/download_glslang"
+// if not found (DXC builds are only downloaded on Windows, on other platforms "dxc" is searched in PATH).
+//
+// Should be started from the root directory of the repository.
+//
+// Flags:
+// --shaders-dir  (optional) directory with shaders, "res/engine/shaders" by default.
+// --include-dirs (optional) comma-separated additional include directories.
+// --dxc          (optional) path to DXC.
+// --glslang      (optional) path to glslangValidator.
+// --quiet        only print warnings and errors.
+// --verbose      also print debug messages (including commands).
+//
+// Flags that are not specified are read from "NE_<FLAG>" environment variables (for example "NE_DXC").
+// Command line arguments always take precedence over environment variables.
+func main() {
+	var shaders_directory = flag.String("shaders-dir", "res/engine/shaders", "(optional) directory with shaders")
+	var include_directories_flag = flag.String("include-dirs", "", "(optional) comma-separated additional include directories")
+	var dxc_path = flag.String("dxc", "", "(optional) path to DXC")
+	var glslang_path = flag.String("glslang", "", "(optional) path to glslangValidator")
+	var quiet = flag.Bool("quiet", false, "only print warnings and errors")
+	var verbose = flag.Bool("verbose", false, "also print debug messages")
+
+	flag.Parse()
+	common.Apply_environment_overrides()
+	common.Init_log("validate_shaders.go", *quiet, *verbose, false, "")
+
+	var include_directories = []string{*shaders_directory}
+	for _, directory := range strings.Split(*include_directories_flag, ",") {
+		if directory = strings.TrimSpace(directory); directory != "" {
+			include_directories = append(include_directories, directory)
+		}
+	}
+
+	hlsl_files, glsl_files := find_shaders(*shaders_directory)
+	if len(hlsl_files) == 0 && len(glsl_files) == 0 {
+		common.Log_fatal("no shaders found in", *shaders_directory)
+	}
+
+	output_directory, err := os.MkdirTemp("", "validate_shaders")
+	if err != nil {
+		common.Log_fatal("failed to create temporary directory, error:", err)
+	}
+	var output_path = filepath.Join(output_directory, "shader.bin")
+
+	var failed_files []string
+	if len(hlsl_files) != 0 {
+		var dxc = find_dxc(*dxc_path)
+		common.Log_info("compiling", len(hlsl_files), "HLSL shader(s) using", dxc)
+		for _, path := range hlsl_files {
+			if !validate_hlsl_shader(dxc, path, include_directories, output_path) {
+				failed_files = append(failed_files, path)
+			}
+		}
+	}
+	if len(glsl_files) != 0 {
+		var glslang = find_glslang(*glslang_path)
+		common.Log_info("compiling", len(glsl_files), "GLSL shader(s) using", glslang)
+		for _, path := range glsl_files {
+			if !validate_glsl_shader(glslang, path, include_directories, output_path) {
+				failed_files = append(failed_files, path)
+			}
+		}
+	}
+
+	os.RemoveAll(output_directory)
+
+	if len(failed_files) != 0 {
+		common.Log_fatal(len(failed_files), "of", len(hlsl_files)+len(glsl_files), "shader file(s) failed to compile:",
+			strings.Join(failed_files, ", "))
+	}
+
+	common.Log_info("all", len(hlsl_files)+len(glsl_files), "shader file(s) were validated successfully")
+}
+
+// Returns HLSL and GLSL shaders from the directory (sorted).
+func find_shaders(directory string) ([]string, []string) {
+	var hlsl_files []string
+	var glsl_files []string
+	var err = filepath.Walk(directory, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if path != directory && strings.HasPrefix(info.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		var extension = strings.ToLower(filepath.Ext(path))
+		if extension == ".hlsl" {
+			hlsl_files = append(hlsl_files, path)
+		} else if extension == ".glsl" || glsl_stages[extension] != "" {
+			glsl_files = append(glsl_files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		common.Log_fatal("failed to read directory", directory, "error:", err)
+	}
+
+	sort.Strings(hlsl_files)
+	sort.Strings(glsl_files)
+	return hlsl_files, glsl_files
+}
+
+// Returns configurations (sets of macros) that are used by the engine for the specified shader type
+// ("vs", "ps" or "cs").
+func get_shader_configurations(shader_type string) [][]string {
+	if shader_type != "ps" {
+		return [][]string{{}}
+	}
+
+	var configurations [][]string
+	for _, filtering := range texture_filtering_macros {
+		configurations = append(configurations,
+			[]string{filtering},
+			[]string{filtering, "USE_DIFFUSE_TEXTURE"},
+			[]string{filtering, "USE_DIFFUSE_TEXTURE", "USE_NORMAL_TEXTURE"})
+	}
+	return configurations
+}
+
+// Returns DXC to use, downloads it if needed.
+func find_dxc(dxc_path string) string {
+	if dxc_path != "" {
+		return dxc_path
+	}
+
+	if runtime.GOOS != "windows" {
+		path, err := exec.LookPath("dxc")
+		if err != nil {
+			common.Log_fatal("\"dxc\" was not found in PATH (use \"--dxc\" to specify it)")
+		}
+		return path
+	}
+
+	var path = filepath.Join(dxc_directory, "bin", "x64", "dxc.exe")
+	download_tool(path, dxc_directory, "download_dxc.go")
+	return path
+}
+
+// Returns glslangValidator to use, downloads it if needed.
+func find_glslang(glslang_path string) string {
+	if glslang_path != "" {
+		return glslang_path
+	}
+
+	var path = filepath.Join(glslang_directory, "bin", "glslangValidator")
+	if runtime.GOOS == "windows" {
+		path += ".exe"
+	}
+	download_tool(path, glslang_directory, "download_glslang.go")
+	return path
+}
+
+// Runs the download script from the directory if the tool does not exist.
+func download_tool(tool_path string, directory string, script_name string) {
+	if _, err := os.Stat(tool_path); err == nil {
+		return
+	}
+
+	common.Log_info(tool_path, "does not exist, running", filepath.Join(directory, script_name))
+
+	// Download scripts expect an absolute path (extracted files are checked to be inside of it).
+	absolute_directory, err := filepath.Abs(directory)
+	if err != nil {
+		common.Log_fatal("failed to get absolute path of", directory, "error:", err)
+	}
+	var command = exec.Command("go", "run", script_name, absolute_directory)
+	command.Dir = directory
+	command.Stdout = os.Stdout
+	command.Stderr = os.Stderr
+	err = command.Run()
+	if err != nil {
+		common.Log_fatal("failed to run", filepath.Join(directory, script_name), "error:", err)
+	}
+
+	if _, err := os.Stat(tool_path); err != nil {
+		common.Log_fatal("expected file", tool_path, "does not exist after running", filepath.Join(directory, script_name))
+	}
+}
+
+// Compiles all entry functions of the HLSL shader with all configurations, returns false if some failed.
+func validate_hlsl_shader(dxc string, path string, include_directories []string, output_path string) bool {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		common.Log_fatal("failed to read", path, "error:", err)
+	}
+
+	var entries = hlsl_entry_regexp.FindAllStringSubmatch(string(content), -1)
+	if len(entries) == 0 {
+		common.Log_verbose(path, "has no entry functions, skipping it (included file)")
+		return true
+	}
+
+	var succeeded = true
+	for _, entry := range entries {
+		var entry_name = entry[1]
+		var shader_type = entry[2]
+
+		var compilations []shader_compilation
+		for _, configuration := range get_shader_configurations(shader_type) {
+			var args = []string{"-E", entry_name, "-T", hlsl_shader_models[shader_type], "-WX", "-Fo", output_path}
+			for _, macro := range configuration {
+				args = append(args, "-D", macro)
+			}
+			for _, directory := range include_directories {
+				args = append(args, "-I", directory)
+			}
+			args = append(args, path)
+
+			compilations = append(compilations, shader_compilation{configuration: configuration, args: args})
+		}
+
+		if !run_compilations(dxc, path+" ("+entry_name+")", compilations) {
+			succeeded = false
+		}
+	}
+	return succeeded
+}
+
+// Compiles the GLSL shader with all configurations, returns false if some failed.
+func validate_glsl_shader(glslang string, path string, include_directories []string, output_path string) bool {
+	// Both "default.frag" and "default.frag.glsl" are supported.
+	var name = strings.ToLower(filepath.Base(path))
+	var stage = glsl_stages[filepath.Ext(strings.TrimSuffix(name, ".glsl"))]
+	if stage == "" {
+		common.Log_verbose(path, "has no stage in the file extension, skipping it (included file)")
+		return true
+	}
+
+	var compilations []shader_compilation
+	for _, configuration := range get_shader_configurations(glsl_shader_types[stage]) {
+		var args = []string{"-S", stage, "-V", "--target-env", "vulkan1.0", "-o", output_path}
+		for _, macro := range configuration {
+			args = append(args, "-D"+macro)
+		}
+		for _, directory := range include_directories {
+			args = append(args, "-I"+directory)
+		}
+		args = append(args, path)
+
+		compilations = append(compilations, shader_compilation{configuration: configuration, args: args})
+	}
+
+	return run_compilations(glslang, path, compilations)
+}
+
+// A single compilation of a shader.
+type shader_compilation struct {
+	configuration []string // defined macros
+	args          []string
+}
+
+// Runs the compiler with each compilation and reports errors (identical errors of different configurations
+// are reported once), returns false if some compilation failed.
+func run_compilations(compiler string, description string, compilations []shader_compilation) bool {
+	var failed_configurations = map[string][]string{} // configurations by compiler output
+	var outputs []string
+	for _, compilation := range compilations {
+		common.Log_verbose(compiler, strings.Join(compilation.args, " "))
+		output, err := exec.Command(compiler, compilation.args...).CombinedOutput()
+		if err == nil {
+			continue
+		}
+
+		var text = strings.TrimSpace(string(output))
+		if _, ok := err.(*exec.ExitError); !ok {
+			text = err.Error()
+		}
+		if _, ok := failed_configurations[text]; !ok {
+			outputs = append(outputs, text)
+		}
+
+		var configuration = strings.Join(compilation.configuration, " ")
+		if configuration == "" {
+			configuration = "no macros"
+		}
+		failed_configurations[text] = append(failed_configurations[text], configuration)
+	}
+
+	if len(outputs) == 0 {
+		common.Log_verbose(description, "was compiled with", len(compilations), "configuration(s)")
+		return true
+	}
+
+	for _, output := range outputs {
+		common.Log_error(fmt.Sprintf("%s failed to compile with %s:\n%s", description,
+			strings.Join(failed_configurations[output], ", "), output))
+	}
+	return false
+}
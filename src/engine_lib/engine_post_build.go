@@ -1,6 +1,8 @@
 package main
 
 import (
+	"common"
+	"flag"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -11,210 +13,989 @@ import (
 	"strings"
 )
 
-// Expects 4 arguments:
-// 1. Path to the 'resources' directory ('res' directory).
-// 2. Path to the 'external' directory ('ext' directory).
-// 3. Path to the working directory of your IDE.
-// 4. Path to the engine_lib working directory.
-// 5. Path to the build directory (where resulting binary will be located).
-// 6. Is release build (0 or 1).
+// Expects the following arguments:
+// --res        path to the 'resources' directory ('res' directory).
+// --ext        path to the 'external' directory ('ext' directory).
+// --work-dir   path to the working directory of your IDE.
+// --engine-lib path to the engine_lib working directory.
+// --build-dir  path to the build directory (where resulting binary will be located).
+// --release    is release build (0 or 1).
+// --config     (optional) path to the project's post_build.toml config file.
+// --steps      (optional) comma-separated list of steps to run (all steps by default).
+// --skip       (optional) comma-separated list of steps to skip.
+// --force      (optional) run steps even if their inputs and outputs were not changed since the last run.
+// --report     (optional) path to the JSON file to write a report about executed steps to.
+// --quiet      (optional) only print warnings and errors.
+// --verbose    (optional) also print debug messages.
+// --timestamps (optional) prefix console messages with timestamps.
+// --log-file   (optional) path to the file to write all messages to (with timestamps and debug messages).
+// --dry-run    (optional) only print operations that would be performed without modifying any files.
+// --use-junction (optional, Windows only) create directory junctions instead of symlinks to the 'res' directory.
+// --arch       (optional) architecture of the build target (x64, x86 or arm64), copied libraries are checked to match it.
+// --binary     (optional) path to the built executable, if specified its dependencies are checked to exist.
+// --no-redist  (optional) don't add Visual C++ redistributable package (when C++ runtime is linked statically).
+// --copy-res   (optional) copy (and then incrementally sync) the 'res' directory instead of creating symlinks to it.
+// --toolchain  (optional) name and version of the C++ compiler, written to the build information file.
+// --steam      (optional) deploy Steam API even if it's not enabled in the config (the config still needs the [steam] section).
+// --package    (optional) pack release builds into distributable archives even if it's not enabled in the config.
+// --hardlink   (optional) create hardlinks instead of copying files when possible (changes to copies also change the originals).
+// --configurations (optional) comma-separated list of configurations of multi-config generators
+//              (such as "Debug,Release,RelWithDebInfo"), libraries and 'res' are also deployed to
+//              "<work-dir>/<configuration>" directories of other configurations.
+// --jobs       (optional) maximum number of files to copy at the same time.
+// --generated-dir (optional) directory to write generated C++ headers (such as "build_info.h") to.
+// --targets    (optional) path to the TOML file with multiple targets (pairs of working/build directories) to process
+//              in one run instead of "--work-dir", "--build-dir" and "--binary" (see `load_post_build_targets`).
+//
+// For compatibility the same 6 values can also be specified as positional arguments (in the order listed above).
+// Arguments that are not specified in the command line are read from "NE_<ARGUMENT>" environment variables
+// (for example "NE_BUILD_DIR" or "NE_DRY_RUN"), except for "NE_RES_DIR", "NE_EXT_DIR" and "NE_ENGINE_LIB_DIR"
+// (named after hook variables). Command line arguments always take precedence over environment variables.
+// Arguments can also be read from a file by specifying "@path/to/file" (one argument per line).
 
 // Does:
-// - copies license files from 'ext' directory to the build directory,
-// - creates a simlink to the 'res' directory in working directory and build directory.
+// - copies additional libraries specified in the config to the build directory,
+// - copies DXC libraries on Windows,
+// - copies Steam API library (if configured),
+// - copies graphics debugging libraries in debug builds (if configured),
+// - copies license files from 'ext' directory to the build directory and writes third-party notices,
+// - checks the structure of the 'res' directory in debug builds,
+// - checks formats, sizes and colorspaces of textures in the 'res' directory (if configured),
+// - creates a simlink to the 'res' directory in working directory and build directory,
+// - converts textures in the 'res' directory of release builds to GPU-ready formats (if configured),
+// - converts audio files in the 'res' directory of release builds to Ogg/Opus with loudness normalization (if configured),
+// - writes a manifest with hashes of 'res' files in release builds,
+// - writes information about the build (commit, build type, etc.) to the build directory,
+// - strips release binaries on Linux (debug information is moved to the "symbols" directory),
+// - compresses the release executable using UPX (if configured),
+// - signs release binaries (if configured),
+// - packs release builds into distributable archives (if configured),
+// - tracks sizes of release builds and reports growth since the previous build (if configured),
+// - launches the built executable to make sure it starts (if configured).
 func main() {
-	var expected_arg_count = 6
-	var args_count = len(os.Args[1:])
-	if args_count != expected_arg_count {
-		fmt.Println("ERROR: engine_post_build.go: expected", expected_arg_count, "arguments.")
-		os.Exit(1)
-	}
-
-	var res_directory = os.Args[1]
-	var ext_directory = os.Args[2]
-	var working_directory = os.Args[3]
-	var engine_lib_dir = os.Args[4]
-	var build_directory = os.Args[5]
-	var is_release = os.Args[6]
-
-	if is_release == "1" {
-		fmt.Println("INFO: engine_post_build.go: current build mode is RELEASE.")
-	} else if is_release == "0" {
-		fmt.Println("INFO: engine_post_build.go: current build mode is DEBUG.")
+	var res_directory = flag.String("res", "", "path to the 'res' directory")
+	var ext_directory = flag.String("ext", "", "path to the 'ext' directory")
+	var working_directory = flag.String("work-dir", "", "path to the working directory of your IDE")
+	var engine_lib_dir = flag.String("engine-lib", "", "path to the engine_lib working directory")
+	var build_directory = flag.String("build-dir", "", "path to the build directory (where resulting binary will be located)")
+	var is_release = flag.String("release", "", "is release build (0 or 1)")
+	var config_path = flag.String("config", "", "(optional) path to the project's post_build.toml config file")
+	var steps_arg = flag.String("steps", "", "(optional) comma-separated list of steps to run: "+strings.Join(all_steps, ","))
+	var skip_arg = flag.String("skip", "", "(optional) comma-separated list of steps to skip")
+	var force = flag.Bool("force", false, "(optional) run steps even if their inputs and outputs were not changed")
+	var report_path = flag.String("report", "", "(optional) path to the JSON file to write a report about executed steps to")
+	var quiet = flag.Bool("quiet", false, "(optional) only print warnings and errors")
+	var verbose = flag.Bool("verbose", false, "(optional) also print debug messages")
+	var timestamps = flag.Bool("timestamps", false, "(optional) prefix console messages with timestamps")
+	flag.BoolVar(&dry_run, "dry-run", false, "(optional) only print operations that would be performed without modifying any files")
+	flag.BoolVar(&use_junction, "use-junction", false, "(optional, Windows only) create directory junctions instead of symlinks to the 'res' directory")
+	flag.StringVar(&target_arch, "arch", get_default_target_arch(), "(optional) architecture of the build target: x64, x86 or arm64")
+	var binary_path = flag.String("binary", "", "(optional) path to the built executable, used to check for missing libraries")
+	var no_redist = flag.Bool("no-redist", false, "(optional) don't add Visual C++ redistributable package (when C++ runtime is linked statically)")
+	var copy_res = flag.Bool("copy-res", false, "(optional) copy the 'res' directory instead of creating symlinks to it")
+	var toolchain = flag.String("toolchain", "", "(optional) name and version of the C++ compiler, written to the build information file")
+	var steam = flag.Bool("steam", false, "(optional) deploy Steam API (the config needs to have the [steam] section)")
+	var package_arg = flag.Bool("package", false, "(optional) pack release builds into distributable archives (see [package] section of the config)")
+	var configurations = flag.String("configurations", "", "(optional) comma-separated list of configurations of multi-config generators")
+	flag.BoolVar(&use_hardlinks, "hardlink", false, "(optional) create hardlinks instead of copying files when possible")
+	flag.IntVar(&copy_jobs, "jobs", copy_jobs, "(optional) maximum number of files to copy at the same time")
+	var targets_path = flag.String("targets", "", "(optional) path to the TOML file with targets to process (instead of --work-dir/--build-dir/--binary)")
+	var generated_directory = flag.String("generated-dir", "", "(optional) directory to write generated C++ headers (such as \"build_info.h\") to")
+	var log_file_path = flag.String("log-file", "", "(optional) path to the file to write all messages to (for example in the build directory)")
+
+	flag.Usage = print_usage
+	flag.CommandLine.Parse(common.Expand_response_files(os.Args[1:]))
+
+	var expected_positional_arg_count = 6
+	var use_positional_args = flag.NFlag() == 0 && flag.NArg() == expected_positional_arg_count
+
+	// Arguments that were not specified can be set using environment variables.
+	var specified_flags = map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { specified_flags[f.Name] = true })
+	if use_positional_args {
+		for _, name := range []string{"res", "ext", "work-dir", "engine-lib", "build-dir", "release"} {
+			specified_flags[name] = true
+		}
+	}
+	var environment_overrides = apply_environment_overrides(specified_flags)
+
+	common.Init_log("engine_post_build.go", *quiet, *verbose, *timestamps, *log_file_path)
+	defer common.Close_log()
+
+	for _, item := range environment_overrides {
+		common.Log_verbose("using environment variable", item)
+	}
+
+	// Support old positional arguments.
+	if use_positional_args {
+		*res_directory = flag.Arg(0)
+		*ext_directory = flag.Arg(1)
+		*working_directory = flag.Arg(2)
+		*engine_lib_dir = flag.Arg(3)
+		*build_directory = flag.Arg(4)
+		*is_release = flag.Arg(5)
+	} else if flag.NArg() != 0 {
+		common.Log_error("expected either", expected_positional_arg_count,
+			"positional arguments or named arguments, received unexpected arguments:", flag.Args())
+		print_usage()
+		common.Exit_with_error()
+	}
+
+	// Make sure all arguments are specified.
+	var required_args = []string{"res", "ext", "work-dir", "engine-lib", "build-dir", "release"}
+	if *targets_path != "" {
+		// Specified in the targets file.
+		required_args = []string{"res", "ext", "engine-lib", "release"}
+	}
+	var missing_args []string
+	for _, name := range required_args {
+		if flag.Lookup(name).Value.String() == "" {
+			missing_args = append(missing_args, "--"+name)
+		}
+	}
+	if len(missing_args) != 0 {
+		common.Log_error("missing required arguments:", strings.Join(missing_args, ", "))
+		print_usage()
+		common.Exit_with_error()
+	}
+
+	if *is_release == "1" {
+		common.Log_info("current build mode is RELEASE.")
+	} else if *is_release == "0" {
+		common.Log_info("current build mode is DEBUG.")
 	} else {
-		fmt.Println("ERROR: engine_post_build.go: unknown build mode, expected 0 or 1, received", is_release)
-		os.Exit(1)
+		common.Log_fatal("unknown build mode, expected 0 or 1, received", *is_release)
+	}
+
+	var enabled_steps = get_enabled_steps(*steps_arg, *skip_arg)
+	var config = load_post_build_config(*config_path)
+
+	var options = post_build_options{
+		res_directory:       *res_directory,
+		ext_directory:       *ext_directory,
+		engine_lib_dir:      *engine_lib_dir,
+		is_release:          *is_release == "1",
+		force:               *force,
+		copy_res:            *copy_res,
+		no_redist:           *no_redist,
+		steam:               *steam,
+		create_packages:     *package_arg,
+		toolchain:           *toolchain,
+		configurations:      *configurations,
+		generated_directory: *generated_directory,
 	}
 
-	copy_ext_licenses(ext_directory, build_directory)
-	make_simlink_to_res(res_directory, working_directory, build_directory, engine_lib_dir)
+	var targets = []post_build_target{{
+		working_directory: *working_directory,
+		build_directory:   *build_directory,
+		binary_path:       *binary_path,
+	}}
+	if *targets_path != "" {
+		targets = load_post_build_targets(*targets_path)
+	}
+
+	if enabled_steps[step_res] && !*copy_res {
+		check_symlink_support()
+	}
+
+	if *steam && !config.steam_configured {
+		common.Log_fatal("\"--steam\" is specified but the config has no [steam] section")
+	}
+
+	// Steps that don't depend on targets are executed once, their hooks receive values of the first target.
+	var hook_variables = get_hook_variables(&options, targets[0])
+
+	if enabled_steps[step_build_header] && *generated_directory != "" {
+		begin_step_with_hooks(&config, step_build_header, hook_variables)
+		write_build_header(get_build_info(&config, *res_directory, options.is_release, *toolchain), *generated_directory)
+		end_step_with_hooks(&config, hook_variables)
+	}
+
+	if enabled_steps[step_res_check] && !options.is_release && config.res_check_enabled {
+		begin_step_with_hooks(&config, step_res_check, hook_variables)
+		check_res_directory(&config, *res_directory)
+		end_step_with_hooks(&config, hook_variables)
+	}
+
+	if enabled_steps[step_textures] && config.textures_enabled {
+		begin_step_with_hooks(&config, step_textures, hook_variables)
+		check_textures(&config, *res_directory)
+		end_step_with_hooks(&config, hook_variables)
+	}
+
+	// Directories shared between targets only need to be processed once.
+	var processed_directories = map[string]bool{}
+	for _, target := range targets {
+		if len(targets) > 1 {
+			common.Log_info("processing target", target.build_directory)
+		}
+		run_post_build_steps(&options, target, &config, enabled_steps, processed_directories)
+	}
 
-	if runtime.GOOS == "windows" && is_release == "1" {
-		add_redist(build_directory)
+	if *report_path != "" {
+		write_report(*report_path)
 	}
 }
 
-func add_redist(build_directory string) {
-	fmt.Println("INFO: engine_post_build.go: downloading redistributable package to the build directory")
+// Runs enabled steps for a single target.
+func run_post_build_steps(options *post_build_options, target post_build_target, config *post_build_config,
+	enabled_steps map[string]bool, processed_directories map[string]bool) {
+	var stamps = load_post_build_stamps(target.build_directory, options.force)
+	var configuration_directories = get_configuration_directories(options.configurations, target.working_directory, target.build_directory)
 
-	var redist_dir = filepath.Join(build_directory, "redist")
-	var _, err = os.Stat(redist_dir)
-	if os.IsNotExist(err) {
-		err = os.Mkdir(redist_dir, 0755)
-		if err != nil {
-			fmt.Println("ERROR: engine_post_build.go: failed to create directory", redist_dir, "error:", err)
-			os.Exit(1)
+	// Working directory and engine_lib directory might be shared with previously processed targets.
+	var working_directory = take_unprocessed_directory(target.working_directory, processed_directories)
+	var engine_lib_dir = take_unprocessed_directory(options.engine_lib_dir, processed_directories)
+	var target_directories = []string{target.build_directory}
+	for _, directory := range []string{working_directory, engine_lib_dir} {
+		if directory != "" {
+			target_directories = append(target_directories, directory)
+		}
+	}
+
+	var build_directory = target.build_directory
+	var binary_path = target.binary_path
+	var is_release = options.is_release
+
+	// Run hooks from the config before/after each step.
+	var hook_variables = get_hook_variables(options, target)
+	var begin_step = func(name string) {
+		begin_step_with_hooks(config, name, hook_variables)
+	}
+	var end_step = func() {
+		end_step_with_hooks(config, hook_variables)
+	}
+
+	if enabled_steps[step_libs] {
+		begin_step(step_libs)
+		copy_extra_libs(config, target_directories, is_release, stamps, step_libs)
+		for _, directory := range configuration_directories {
+			copy_extra_libs(config, []string{directory.path}, directory.is_release, stamps, step_libs+"_"+directory.name)
+		}
+		end_step()
+	}
+
+	if enabled_steps[step_dxc] && runtime.GOOS == "windows" {
+		begin_step(step_dxc)
+		copy_dxc_libs(options.ext_directory, target_directories, stamps, step_dxc)
+		for _, directory := range configuration_directories {
+			copy_dxc_libs(options.ext_directory, []string{directory.path}, stamps, step_dxc+"_"+directory.name)
+		}
+		end_step()
+	}
+
+	if enabled_steps[step_graphics_debug] && !is_release && (config.pix_library != "" || config.renderdoc_library != "") {
+		begin_step(step_graphics_debug)
+		copy_graphics_debugging_libs(config, target_directories, stamps)
+		end_step()
+	}
+
+	if enabled_steps[step_steam] && (config.steam_enabled || options.steam) {
+		begin_step(step_steam)
+		deploy_steam_api(config, target_directories, build_directory, is_release, stamps)
+		end_step()
+	}
+
+	if enabled_steps[step_licenses] {
+		begin_step(step_licenses)
+		copy_ext_licenses(config, options.ext_directory, build_directory, stamps)
+		end_step()
+	}
+
+	if enabled_steps[step_res] {
+		begin_step(step_res)
+		if options.copy_res {
+			var res_target_directories = append([]string{}, target_directories...)
+			for _, directory := range configuration_directories {
+				res_target_directories = append(res_target_directories, directory.path)
+			}
+			var cook_rules = get_res_cook_rules(config, enabled_steps, is_release)
+			copy_res_directory(options.res_directory, res_target_directories, build_directory, cook_rules)
+		} else {
+			make_simlink_to_res(options.res_directory, working_directory, build_directory, engine_lib_dir)
+			for _, directory := range configuration_directories {
+				create_res_symlink(options.res_directory, directory.path)
+			}
+		}
+		end_step()
+	}
+
+	if enabled_steps[step_cook_textures] && is_release && config.cook_textures_enabled {
+		begin_step(step_cook_textures)
+		convert_textures(config, options.res_directory, build_directory)
+		end_step()
+	}
+
+	if enabled_steps[step_cook_audio] && is_release && config.cook_audio_enabled {
+		begin_step(step_cook_audio)
+		convert_audio(config, options.res_directory, build_directory)
+		end_step()
+	}
+
+	if enabled_steps[step_res_manifest] && is_release {
+		begin_step(step_res_manifest)
+		write_res_manifest(build_directory, stamps)
+		end_step()
+	}
+
+	if enabled_steps[step_build_info] {
+		begin_step(step_build_info)
+		write_build_info(get_build_info(config, options.res_directory, is_release, options.toolchain), build_directory)
+		end_step()
+	}
+
+	if enabled_steps[step_redist] && runtime.GOOS == "windows" && is_release {
+		begin_step(step_redist)
+		if options.no_redist {
+			common.Log_info("skipping redistributable package because \"--no-redist\" is specified")
+			report_step_status(step_status_skipped)
+		} else if binary_path != "" && !is_using_dynamic_crt(binary_path) {
+			common.Log_info(binary_path, "does not use dynamic C++ runtime, skipping redistributable package")
+			report_step_status(step_status_skipped)
+		} else {
+			add_redist(config, build_directory, stamps)
 		}
+		end_step()
+	}
+
+	if enabled_steps[step_directx] && runtime.GOOS == "windows" && is_release && config.directx_runtime {
+		begin_step(step_directx)
+		add_directx_redist(config, build_directory, stamps)
+		end_step()
+	}
+
+	if enabled_steps[step_agility] && runtime.GOOS == "windows" && config.agility_sdk_version != "" {
+		begin_step(step_agility)
+		var agility_target_directories = []string{build_directory}
+		if engine_lib_dir != "" {
+			agility_target_directories = append(agility_target_directories, engine_lib_dir)
+		}
+		deploy_agility_sdk(config, agility_target_directories, is_release, stamps)
+		end_step()
+	}
+
+	if enabled_steps[step_exe_resources] && runtime.GOOS == "windows" && binary_path != "" &&
+		(config.executable_icon != "" || config.executable_version != "") {
+		begin_step(step_exe_resources)
+		embed_executable_resources(config, binary_path, stamps)
+		end_step()
+	}
+
+	if enabled_steps[step_verify] && len(config.verify) != 0 {
+		begin_step(step_verify)
+		verify_runtime_libs(config, build_directory)
+		end_step()
+	}
+
+	if enabled_steps[step_deps] && binary_path != "" {
+		begin_step(step_deps)
+		check_binary_dependencies(config, binary_path)
+		end_step()
+	}
+
+	if enabled_steps[step_strip] && runtime.GOOS == "linux" && is_release {
+		begin_step(step_strip)
+		strip_binaries(binary_path, build_directory, stamps)
+		end_step()
+	}
+
+	if enabled_steps[step_compress] && (runtime.GOOS == "windows" || runtime.GOOS == "linux") && is_release &&
+		binary_path != "" && config.compress_enabled {
+		begin_step(step_compress)
+		compress_binary(config, binary_path, stamps)
+		end_step()
 	}
 
-	download_file("https://aka.ms/vs/17/release/vc_redist.x64.exe", redist_dir)
+	if enabled_steps[step_sign] && is_release && config.signing_enabled {
+		begin_step(step_sign)
+		sign_binaries(config, build_directory, stamps)
+		end_step()
+	}
+
+	if enabled_steps[step_package] && is_release && (config.package_enabled || options.create_packages) {
+		begin_step(step_package)
+		package_build(config, options.ext_directory, build_directory, binary_path, stamps)
+		end_step()
+	}
+
+	if enabled_steps[step_size] && is_release && config.size_enabled {
+		begin_step(step_size)
+		track_build_size(config, options.res_directory, build_directory, binary_path, stamps)
+		end_step()
+	}
+
+	if enabled_steps[step_smoke_test] && binary_path != "" && config.smoke_test_enabled {
+		begin_step(step_smoke_test)
+		run_smoke_test(config, binary_path, stamps)
+		end_step()
+	}
 }
 
-func download_file(URL string, download_directory string) {
-	var filename = filepath.Join(download_directory, URL[strings.LastIndex(URL, "/"):])
+// Arguments that are the same for all targets.
+type post_build_options struct {
+	res_directory       string
+	ext_directory       string
+	engine_lib_dir      string
+	is_release          bool
+	force               bool
+	copy_res            bool
+	no_redist           bool
+	steam               bool
+	create_packages     bool
+	toolchain           string
+	configurations      string
+	generated_directory string
+}
 
-	fmt.Println("INFO: engine_post_build.go: downloading file", filename)
+// A single executable (game, editor, server, tool) to run the post build steps for.
+type post_build_target struct {
+	working_directory string
+	build_directory   string
+	binary_path       string // optional
+}
+
+// Loads targets from a TOML file:
+//
+//	[[targets]]
+//	work_dir = "build"               # relative to the targets file
+//	build_dir = "build/Release"
+//	binary = "build/Release/game"    # optional
+func load_post_build_targets(path string) []post_build_target {
+	root, err := parse_toml_file(path)
+	if err != nil {
+		common.Log_fatal("failed to parse targets file, error:", err)
+	}
+
+	var targets_config = post_build_config{directory: filepath.Dir(path)}
+	var targets []post_build_target
+	for _, table := range config_get_table_array(root, "targets") {
+		var target = post_build_target{
+			working_directory: config_get_string(table, "work_dir", ""),
+			build_directory:   config_get_string(table, "build_dir", ""),
+			binary_path:       config_get_string(table, "binary", ""),
+		}
+		if target.working_directory == "" || target.build_directory == "" {
+			common.Log_fatal("targets file", path, "has a target without \"work_dir\" or \"build_dir\"")
+		}
+
+		target.working_directory = targets_config.resolve_path(target.working_directory)
+		target.build_directory = targets_config.resolve_path(target.build_directory)
+		if target.binary_path != "" {
+			target.binary_path = targets_config.resolve_path(target.binary_path)
+		}
+		targets = append(targets, target)
+	}
+
+	if len(targets) == 0 {
+		common.Log_fatal("targets file", path, "has no targets")
+	}
+	warn_unknown_config_keys("targets file", path, root, "")
+
+	return targets
+}
+
+// Returns the directory and marks it as processed or returns an empty string if it was already processed.
+func take_unprocessed_directory(directory string, processed_directories map[string]bool) string {
+	if directory == "" {
+		return ""
+	}
+
+	absolute_path, err := filepath.Abs(directory)
+	if err != nil {
+		absolute_path = filepath.Clean(directory)
+	}
+
+	if processed_directories[absolute_path] {
+		return ""
+	}
+	processed_directories[absolute_path] = true
+
+	return directory
+}
+
+// Names of the steps that can be used in "--steps" and "--skip".
+const (
+	step_libs           = "libs"
+	step_dxc            = "dxc"
+	step_licenses       = "licenses"
+	step_res            = "res"
+	step_res_check      = "res_check"
+	step_textures       = "textures"
+	step_redist         = "redist"
+	step_agility        = "agility_sdk"
+	step_verify         = "verify"
+	step_deps           = "deps"
+	step_directx        = "directx_redist"
+	step_sign           = "sign"
+	step_exe_resources  = "exe_resources"
+	step_strip          = "strip"
+	step_build_info     = "build_info"
+	step_build_header   = "build_header"
+	step_steam          = "steam"
+	step_smoke_test     = "smoke_test"
+	step_graphics_debug = "graphics_debug"
+	step_res_manifest   = "res_manifest"
+	step_package        = "package"
+	step_compress       = "compress"
+	step_size           = "size"
+	step_cook_textures  = "cook_textures"
+	step_cook_audio     = "cook_audio"
+)
+
+var all_steps = []string{step_libs, step_dxc, step_licenses, step_res_check, step_textures, step_res, step_cook_textures, step_cook_audio, step_redist, step_agility, step_verify, step_deps, step_directx, step_exe_resources, step_strip, step_compress, step_sign, step_build_info, step_build_header, step_steam, step_smoke_test, step_graphics_debug, step_res_manifest, step_package, step_size}
+
+// Returns steps that should be run.
+func get_enabled_steps(steps_arg string, skip_arg string) map[string]bool {
+	var enabled_steps = map[string]bool{}
+
+	if steps_arg == "" {
+		for _, step := range all_steps {
+			enabled_steps[step] = true
+		}
+	} else {
+		for _, step := range parse_step_list(steps_arg) {
+			enabled_steps[step] = true
+		}
+	}
+
+	for _, step := range parse_step_list(skip_arg) {
+		delete(enabled_steps, step)
+	}
+
+	for _, step := range all_steps {
+		if !enabled_steps[step] {
+			common.Log_verbose("skipping step", step)
+			report_skipped_step(step)
+		}
+	}
+
+	return enabled_steps
+}
+
+func parse_step_list(list string) []string {
+	var steps []string
+	for _, step := range strings.Split(list, ",") {
+		step = strings.ToLower(strings.TrimSpace(step))
+		if step == "" {
+			continue
+		}
+		if !contains_string(all_steps, step) {
+			common.Log_fatal("unknown step", step, "expected one of:", strings.Join(all_steps, ", "))
+		}
+		steps = append(steps, step)
+	}
+	return steps
+}
+
+func print_usage() {
+	fmt.Println("Usage: go run . --res=<dir> --ext=<dir> --work-dir=<dir> " +
+		"--engine-lib=<dir> --build-dir=<dir> --release=<0|1> [--config=<file>] [--steps=<list>] [--skip=<list>] [--force] [--report=<file>] " +
+		"[--quiet | --verbose] [--timestamps] [--log-file=<file>] [--dry-run] [--use-junction | --copy-res] [--arch=<arch>] [--binary=<file>] [--no-redist] [--toolchain=<name>] [--generated-dir=<dir>] [--steam] [--package] [--jobs=<count>] [--hardlink] [--configurations=<list>] [--targets=<file>]")
+	fmt.Println("   or: go run . <res> <ext> <work-dir> <engine-lib> <build-dir> <release>")
+	fmt.Println()
+	fmt.Println("Arguments:")
+	flag.PrintDefaults()
+}
+
+func add_redist(config *post_build_config, build_directory string, stamps *post_build_stamps) {
+	var redist_dir = filepath.Join(build_directory, "redist")
+	var redist_file_name = get_url_file_name(config.vc_redist_url)
+
+	// Release builds should not ship a package that might have been replaced.
+	if config.vc_redist_sha256 == "" {
+		common.Log_fatal("checksum of the redistributable package is not pinned, specify \"redist.vc_redist_sha256\" " +
+			"for \"redist.vc_redist_url\" in the config (or use \"--no-redist\")")
+	}
+
+	var inputs = fingerprint_files(nil, config.vc_redist_url, config.vc_redist_sha256)
+	var outputs = []string{filepath.Join(redist_dir, redist_file_name)}
+	if stamps.is_up_to_date(step_redist, inputs, outputs) {
+		common.Log_info("redistributable package is up to date")
+		report_step_status(step_status_up_to_date)
+		return
+	}
+
+	// The package might have been placed into the build directory by a previous build (or manually).
+	var redist_path = filepath.Join(redist_dir, redist_file_name)
+	if _, err := os.Stat(redist_path); err == nil && strings.EqualFold(get_file_sha256(redist_path), config.vc_redist_sha256) {
+		common.Log_info("found redistributable package in the build directory")
+		stamps.update(step_redist, inputs, outputs)
+		return
+	}
+
+	// Packages are cached by their checksums.
+	var cache_key = filepath.Join("vc_redist", strings.ToLower(config.vc_redist_sha256))
+	var cached_path = download_cached(config.vc_redist_url, redist_file_name, cache_key, config.vc_redist_sha256)
+
+	common.Log_info("copying redistributable package to the build directory")
+	common.Check_free_disk_space(redist_dir, get_copies_size([]file_copy{{src: cached_path, dst: redist_path}}), "redistributable package")
+	make_directory(redist_dir)
+	copy(cached_path, redist_path)
+
+	stamps.update(step_redist, inputs, outputs)
+}
+
+// Places DirectX End-User Runtime web installer into the "redist" directory.
+func add_directx_redist(config *post_build_config, build_directory string, stamps *post_build_stamps) {
+	var file_name = get_url_file_name(config.directx_runtime_url)
+	var redist_path = filepath.Join(build_directory, "redist", file_name)
+
+	var inputs = fingerprint_files(nil, config.directx_runtime_url, config.directx_runtime_sha256)
+	var outputs = []string{redist_path}
+	if stamps.is_up_to_date(step_directx, inputs, outputs) {
+		common.Log_info("DirectX End-User Runtime is up to date")
+		report_step_status(step_status_up_to_date)
+		return
+	}
+
+	var cached_path = download_cached(config.directx_runtime_url, file_name, "directx_runtime", config.directx_runtime_sha256)
+
+	common.Log_info("copying DirectX End-User Runtime to the build directory")
+	common.Check_free_disk_space(filepath.Dir(redist_path), get_copies_size([]file_copy{{src: cached_path, dst: redist_path}}),
+		"DirectX End-User Runtime")
+	make_directory(filepath.Dir(redist_path))
+	copy(cached_path, redist_path)
+
+	stamps.update(step_directx, inputs, outputs)
+}
+
+// Returns name of the file that the URL points to.
+func get_url_file_name(URL string) string {
+	return URL[strings.LastIndex(URL, "/")+1:]
+}
+
+// Downloads the file from the specified URL to the specified path.
+func download_file_to(URL string, filename string) {
+	if dry_run {
+		common.Log_info("[dry run] download", URL, "to", filename)
+		return
+	}
+
+	common.Log_info("downloading file", filename)
 
 	response, err := http.Get(URL)
 	if err != nil {
-		fmt.Println("ERROR: engine_post_build.go:", err)
-		os.Exit(1)
+		common.Log_fatal(err)
 	}
 	defer response.Body.Close()
 
 	if response.StatusCode != 200 {
-		fmt.Println("ERROR: engine_post_build.go: received non 200 response code, actual result:", response.StatusCode)
-		os.Exit(1)
+		common.Log_fatal("received non 200 response code, actual result:", response.StatusCode)
 	}
 
+	// Content length is -1 if unknown.
+	common.Check_free_disk_space(filepath.Dir(filename), response.ContentLength, "downloaded file "+filepath.Base(filename))
+
 	file, err := os.Create(filename)
 	if err != nil {
-		fmt.Println("ERROR: engine_post_build.go: failed to create empty file, error:", err)
-		os.Exit(1)
+		common.Log_fatal("failed to create empty file, error:", err)
 	}
 	defer file.Close()
 
-	_, err = io.Copy(file, response.Body)
+	bytes, err := io.Copy(file, response.Body)
 	if err != nil {
-		fmt.Println("ERROR: engine_post_build.go: failed to copy downloaded bytes, error:", err)
-		os.Exit(1)
+		common.Log_fatal("failed to copy downloaded bytes, error:", err)
 	}
+
+	report_file(URL, filename, bytes)
 }
 
 func make_simlink_to_res(res_directory string, working_directory string, build_directory string, engine_lib_dir string) {
 	var err error
 	_, err = os.Stat(res_directory)
 	if os.IsNotExist(err) {
-		fmt.Println("ERROR: engine_post_build.go: res directory", res_directory, "does not exist")
-		os.Exit(1)
+		common.Log_fatal("res directory", res_directory, "does not exist")
 	}
 
 	_, err = os.Stat(working_directory)
-	if os.IsNotExist(err) {
-		fmt.Println("ERROR: engine_post_build.go: working directory", working_directory, "does not exist")
-		os.Exit(1)
+	if working_directory != "" && os.IsNotExist(err) {
+		common.Log_fatal("working directory", working_directory, "does not exist")
 	}
 
 	_, err = os.Stat(build_directory)
 	if os.IsNotExist(err) {
-		fmt.Println("ERROR: engine_post_build.go: build directory", build_directory, "does not exist")
-		os.Exit(1)
+		common.Log_fatal("build directory", build_directory, "does not exist")
 	}
 
-	fmt.Println("engine_post_build.go: using res directory:", res_directory)
-	fmt.Println("engine_post_build.go: using working directory:", working_directory)
-	fmt.Println("engine_post_build.go: using build directory:", build_directory)
+	common.Log_verbose("using res directory:", res_directory)
+	common.Log_verbose("using working directory:", working_directory)
+	common.Log_verbose("using build directory:", build_directory)
 
-	_, err = os.Stat(filepath.Join(working_directory, "res"))
-	if os.IsNotExist(err) {
-		err = os.Symlink(res_directory, filepath.Join(working_directory, "res"))
-		if err != nil {
-			fmt.Println("ERROR: engine_post_build.go: failed to create symlink to 'res' in", working_directory, "error:", err)
-			if runtime.GOOS == "windows" {
-				// Maybe not enough privileges.
-				fmt.Println("ERROR: engine_post_build.go: failed to create symlink to 'res' directory. " +
-					"In order to create symlinks on Windows administrator rights are requires (make sure you are running your " +
-					"IDE with administrator rights).")
+	// Working directory and engine_lib directory are empty if they were processed for another target.
+	for _, directory := range []string{working_directory, engine_lib_dir, build_directory} {
+		if directory != "" {
+			create_res_symlink(res_directory, directory)
+		}
+	}
+
+	common.Log_success("symlinks to 'res' directory were created.")
+}
+
+// Copies the 'res' directory to the specified directories (only changed files are copied). Files that are
+// cooked by "cook_rules" are not copied to the build directory, their cooked files are kept there.
+func copy_res_directory(res_directory string, target_directories []string, build_directory string, cook_rules []res_cook_rule) {
+	if _, err := os.Stat(res_directory); os.IsNotExist(err) {
+		common.Log_fatal("res directory", res_directory, "does not exist")
+	}
+
+	var processed_directories = map[string]bool{}
+	for _, directory := range target_directories {
+		var target = filepath.Clean(filepath.Join(directory, "res"))
+		if processed_directories[target] {
+			continue
+		}
+		processed_directories[target] = true
+
+		if is_link(target) {
+			common.Log_info("replacing link", target, "with a copy of the 'res' directory")
+			remove_link(target)
+		}
+
+		var filter sync_filter
+		if len(cook_rules) > 0 && target == filepath.Clean(filepath.Join(build_directory, "res")) {
+			filter.exclude = func(relative_path string) bool {
+				return is_cooked_source(cook_rules, relative_path)
+			}
+			filter.keep = func(relative_path string) bool {
+				return is_cooked_file(cook_rules, res_directory, relative_path)
 			}
-			os.Exit(1)
 		}
+
+		common.Check_free_disk_space(target, get_directory_sync_size(res_directory, target, filter), "'res' directory")
+		copied_count, removed_count := sync_directory(res_directory, target, filter)
+		common.Log_verbose("synchronized", target, "copied", copied_count, "file(-s), removed", removed_count, "file(-s)")
 	}
 
-	_, err = os.Stat(filepath.Join(engine_lib_dir, "res"))
-	if os.IsNotExist(err) {
-		err = os.Symlink(res_directory, filepath.Join(engine_lib_dir, "res"))
-		if err != nil {
-			fmt.Println("ERROR: engine_post_build.go: failed to create symlink to 'res' in", engine_lib_dir, "error:", err)
-			if runtime.GOOS == "windows" {
-				// Maybe not enough privileges.
-				fmt.Println("ERROR: engine_post_build.go: failed to create symlink to 'res' directory. " +
-					"In order to create symlinks on Windows administrator rights are requires (make sure you are running your " +
-					"IDE with administrator rights).")
+	common.Log_success("'res' directory was copied.")
+}
+
+// Creates a symlink to the 'res' directory in the specified directory. If the directory
+// already has a 'res' link that points to some other directory (for example, if the project was moved)
+// or a copy of the 'res' directory the link is recreated.
+func create_res_symlink(res_directory string, directory string) {
+	var link_path = filepath.Join(directory, "res")
+
+	var _, err = os.Lstat(link_path)
+	if err == nil {
+		if is_link(link_path) {
+			if is_link_to(link_path, res_directory) {
+				return
 			}
-			os.Exit(1)
+			common.Log_warning("symlink", link_path, "does not point to", res_directory, "recreating it")
+			remove_link(link_path)
+		} else {
+			common.Log_warning(link_path, "is not a symlink (probably a copy of the 'res' directory), replacing it with a symlink")
+			remove_all(link_path)
 		}
 	}
 
-	_, err = os.Stat(filepath.Join(build_directory, "res"))
-	if os.IsNotExist(err) {
-		err = os.Symlink(res_directory, filepath.Join(build_directory, "res"))
+	err = create_directory_link(res_directory, link_path)
+	if err != nil {
+		common.Log_fatal("failed to create symlink to 'res' in", directory, "error:", err)
+	}
+}
+
+// Source and destination paths of a file to copy.
+type file_copy struct {
+	src string
+	dst string
+}
+
+// Returns sources of the specified copies.
+func get_copy_sources(copies []file_copy) []string {
+	var sources []string
+	for _, item := range copies {
+		sources = append(sources, item.src)
+	}
+	return sources
+}
+
+// Returns destinations of the specified copies.
+func get_copy_destinations(copies []file_copy) []string {
+	var destinations []string
+	for _, item := range copies {
+		destinations = append(destinations, item.dst)
+	}
+	return destinations
+}
+
+// Copies additional libraries/assets specified in the config to the specified directories,
+// "stamp_name" is used to track changes of inputs/outputs.
+func copy_extra_libs(config *post_build_config, target_directories []string, is_release bool, stamps *post_build_stamps,
+	stamp_name string) {
+	if len(config.libs) == 0 {
+		return
+	}
+
+	var copies = collect_extra_lib_copies(config, target_directories, is_release)
+
+	var inputs = fingerprint_files(get_copy_sources(copies), get_copy_destinations(copies)...)
+	var outputs = get_copy_destinations(copies)
+	if stamps.is_up_to_date(stamp_name, inputs, outputs) {
+		common.Log_info("additional libraries are up to date")
+		report_step_status(step_status_up_to_date)
+		return
+	}
+
+	check_binaries_arch(get_copy_sources(copies))
+	copy_files(copies)
+
+	stamps.update(stamp_name, inputs, outputs)
+
+	common.Log_success("copied", len(copies), "additional file(-s)")
+}
+
+// Returns files to copy for additional libraries/assets specified in the config.
+func collect_extra_lib_copies(config *post_build_config, target_directories []string, is_release bool) []file_copy {
+	var copies []file_copy
+
+	for _, entry := range config.libs {
+		if !is_entry_enabled(entry.platforms, entry.build_modes, is_release) {
+			continue
+		}
+
+		var pattern = config.resolve_path(entry.source)
+		matches, err := filepath.Glob(pattern)
 		if err != nil {
-			fmt.Println("ERROR: engine_post_build.go: failed to create symlink to 'res' in", build_directory, "error:", err)
-			if runtime.GOOS == "windows" {
-				// Maybe not enough privileges.
-				fmt.Println("ERROR: engine_post_build.go: failed to create symlink to 'res' directory. " +
-					"In order to create symlinks on Windows administrator rights are requires (make sure you are running your " +
-					"IDE with administrator rights).")
+			common.Log_fatal("invalid pattern", pattern, "error:", err)
+		}
+		if len(matches) == 0 {
+			common.Log_fatal("no files found for", pattern)
+		}
+
+		var processed_directories = map[string]bool{}
+		for _, target_directory := range target_directories {
+			// Directories may be equal (for example when working and build directories are the same).
+			var destination_directory = filepath.Clean(filepath.Join(target_directory, entry.destination))
+			if processed_directories[destination_directory] {
+				continue
+			}
+			processed_directories[destination_directory] = true
+
+			for _, match := range matches {
+				var destination = filepath.Join(destination_directory, filepath.Base(match))
+				info, err := os.Stat(match)
+				if err != nil {
+					common.Log_fatal(err)
+				}
+
+				if !info.IsDir() {
+					copies = append(copies, file_copy{src: match, dst: destination})
+					continue
+				}
+
+				err = filepath.Walk(match, func(path string, info os.FileInfo, err error) error {
+					if err != nil || info.IsDir() {
+						return err
+					}
+					relative_path, err := filepath.Rel(match, path)
+					if err != nil {
+						return err
+					}
+					copies = append(copies, file_copy{src: path, dst: filepath.Join(destination, relative_path)})
+					return nil
+				})
+				if err != nil {
+					common.Log_fatal("failed to read directory", match, "error:", err)
+				}
 			}
-			os.Exit(1)
 		}
 	}
 
-	fmt.Println("SUCCESS: engine_post_build.go: symlinks to 'res' directory were created.")
+	return copies
 }
 
-func copy_ext_licenses(ext_directory string, build_directory string) {
+func copy_ext_licenses(config *post_build_config, ext_directory string, build_directory string, stamps *post_build_stamps) {
 	var err error
 	_, err = os.Stat(ext_directory)
 	if os.IsNotExist(err) {
-		fmt.Println("ERROR: engine_post_build.go: ext directory", ext_directory, "does not exist")
-		os.Exit(1)
+		common.Log_fatal("ext directory", ext_directory, "does not exist")
 	}
 
 	_, err = os.Stat(build_directory)
 	if os.IsNotExist(err) {
-		fmt.Println("ERROR: engine_post_build.go: build directory", build_directory, "does not exist")
-		os.Exit(1)
+		common.Log_fatal("build directory", build_directory, "does not exist")
 	}
 
-	fmt.Println("engine_post_build.go: using ext directory:", ext_directory)
-	fmt.Println("engine_post_build.go: using build directory:", build_directory)
+	common.Log_verbose("using ext directory:", ext_directory)
+	common.Log_verbose("using build directory:", build_directory)
 
-	build_directory = filepath.Join(build_directory, "ext")
-	_, err = os.Stat(build_directory)
-	if os.IsNotExist(err) {
-		err = os.Mkdir(build_directory, os.ModePerm)
-		if err != nil {
-			fmt.Println("ERROR: engine_post_build.go: failed to create directory",
-				build_directory, "error:", err)
-			os.Exit(1)
-		}
-	} else {
-		err = os.RemoveAll(build_directory)
-		if err != nil {
-			fmt.Println("ERROR: engine_post_build.go: failed to remove old directory",
-				build_directory, "error:", err)
-			os.Exit(1)
+	var license_directory = filepath.Join(build_directory, "ext")
+
+	var copies = find_ext_licenses(ext_directory, license_directory)
+	var dependencies = get_ext_dependencies(ext_directory, copies)
+
+	var versions []string
+	for _, dependency := range dependencies {
+		versions = append(versions, dependency.name+"@"+dependency.version)
+	}
+
+	// Use hashes of license files instead of modification times because updating submodules
+	// touches license files even if they were not changed.
+	var license_hashes []string
+	for _, item := range copies {
+		license_hashes = append(license_hashes, item.dst+"|"+get_file_sha256(item.src))
+	}
+
+	var inputs = fingerprint_files(nil, append(append(license_hashes, versions...),
+		config.get_license_policy_settings()...)...)
+	var outputs = append(get_copy_destinations(copies), get_third_party_notices_paths(build_directory)...)
+	if stamps.is_up_to_date(step_licenses, inputs, outputs) {
+		common.Log_info("license files are up to date")
+		report_step_status(step_status_up_to_date)
+		verify_ext_licenses(config, ext_directory, build_directory)
+		return
+	}
+
+	check_license_policy(config, dependencies)
+
+	common.Check_free_disk_space(license_directory, get_copies_size(copies), "license files")
+
+	var copied_count, removed_count = update_ext_licenses(copies, license_directory)
+	write_third_party_notices(dependencies, build_directory)
+	if !dry_run {
+		verify_ext_licenses(config, ext_directory, build_directory)
+	}
+
+	stamps.update(step_licenses, inputs, outputs)
+
+	common.Log_success("copied", copied_count, "changed license file(-s) of", len(copies), "and removed", removed_count,
+		"outdated license file(-s)")
+}
+
+// Copies license files that don't exist in the license directory or have a different content
+// and removes license files of dependencies that no longer exist.
+// Returns the number of copied and removed files.
+func update_ext_licenses(copies []file_copy, license_directory string) (int, int) {
+	make_directory(license_directory)
+
+	var changed_copies []file_copy
+	var destinations = map[string]bool{}
+	for _, item := range copies {
+		destinations[filepath.Base(item.dst)] = true
+		if _, err := os.Stat(item.dst); err == nil && get_file_sha256(item.dst) == get_file_sha256(item.src) {
+			continue
 		}
-		err = os.Mkdir(build_directory, os.ModePerm)
-		if err != nil {
-			fmt.Println("ERROR: engine_post_build.go: failed to create directory",
-				build_directory, "error:", err)
-			os.Exit(1)
+		changed_copies = append(changed_copies, item)
+	}
+	copy_files(changed_copies)
+
+	var removed_count = 0
+	entries, _ := os.ReadDir(license_directory)
+	for _, entry := range entries {
+		if !destinations[entry.Name()] {
+			remove_all(filepath.Join(license_directory, entry.Name()))
+			removed_count += 1
 		}
 	}
 
-	var copied_licenses_count = 0
+	return len(changed_copies), removed_count
+}
+
+// Looks for license files of all dependencies in the 'ext' directory and returns
+// copies that will place them into the specified directory.
+func find_ext_licenses(ext_directory string, license_directory string) []file_copy {
+	var copies []file_copy
 
 	items, _ := ioutil.ReadDir(ext_directory)
 	for _, item := range items {
@@ -232,11 +1013,10 @@ func copy_ext_licenses(ext_directory string, build_directory string) {
 			}
 
 			if strings.Contains(subitem.Name(), "LICENSE") {
-				fmt.Println("INFO: engine_post_build.go: found", dir_name, "license file")
+				common.Log_info("found", dir_name, "license file")
 				var src = filepath.Join(ext_directory, dir_name, subitem.Name())
-				var dst = filepath.Join(build_directory, dir_name+".txt")
-				copy(src, dst)
-				copied_licenses_count += 1
+				var dst = filepath.Join(license_directory, dir_name+".txt")
+				copies = append(copies, file_copy{src: src, dst: dst})
 				found_license = true
 				break
 			}
@@ -250,55 +1030,21 @@ func copy_ext_licenses(ext_directory string, build_directory string) {
 				}
 
 				if strings.Contains(subitem.Name(), "COPYING") {
-					fmt.Println("INFO: engine_post_build.go: found", dir_name, "license file")
+					common.Log_info("found", dir_name, "license file")
 					var src = filepath.Join(ext_directory, dir_name, subitem.Name())
-					var dst = filepath.Join(build_directory, dir_name+".txt")
-					copy(src, dst)
-					copied_licenses_count += 1
+					var dst = filepath.Join(license_directory, dir_name+".txt")
+					copies = append(copies, file_copy{src: src, dst: dst})
 					found_license = true
 					break
 				}
 			}
 
 			if !found_license {
-				fmt.Println("ERROR: engine_post_build.go: could not find a license "+
+				common.Log_fatal("could not find a license "+
 					"file for dependency", dir_name)
-				os.Exit(1)
 			}
 		}
 	}
 
-	fmt.Println("SUCCESS: engine_post_build.go: copied", copied_licenses_count, "license file(-s)")
-}
-
-func copy(src string, dst string) {
-	sourceFileStat, err := os.Stat(src)
-	if err != nil {
-		fmt.Println("ERROR: engine_post_build.go:", err)
-		os.Exit(1)
-	}
-
-	if !sourceFileStat.Mode().IsRegular() {
-		fmt.Println("ERROR: engine_post_build.go:", src, "is not a file")
-		os.Exit(1)
-	}
-
-	source, err := os.Open(src)
-	if err != nil {
-		fmt.Println("ERROR: engine_post_build.go: failed to open file", src, "error:", err)
-		os.Exit(1)
-	}
-	defer source.Close()
-
-	destination, err := os.Create(dst)
-	if err != nil {
-		fmt.Println("ERROR: engine_post_build.go: failed to create file", dst, "error:", err)
-		os.Exit(1)
-	}
-	defer destination.Close()
-	_, err = io.Copy(destination, source)
-	if err != nil {
-		fmt.Println("ERROR: engine_post_build.go: failed to copy file", src, "to", dst, "error:", err)
-		os.Exit(1)
-	}
+	return copies
 }
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"archive/zip"
+	"common"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Name of the directory (next to the executable) where Agility SDK DLLs are placed.
+const agility_sdk_directory_name = "D3D12"
+
+// Downloads the pinned Microsoft.Direct3D.D3D12 NuGet package and places D3D12Core.dll
+// (and d3d12SDKLayers.dll in debug builds) into the "D3D12" directory next to the executable.
+func deploy_agility_sdk(config *post_build_config, target_directories []string, is_release bool, stamps *post_build_stamps) {
+	var package_url = "https://www.nuget.org/api/v2/package/Microsoft.Direct3D.D3D12/" + config.agility_sdk_version
+
+	var dll_names = []string{"D3D12Core.dll"}
+	if !is_release {
+		dll_names = append(dll_names, "d3d12SDKLayers.dll")
+	}
+
+	var outputs []string
+	for _, directory := range target_directories {
+		for _, dll_name := range dll_names {
+			outputs = append(outputs, filepath.Join(directory, agility_sdk_directory_name, dll_name))
+		}
+	}
+
+	var inputs = fingerprint_files(nil, package_url, config.agility_sdk_sha256, config.agility_sdk_arch)
+	if stamps.is_up_to_date(step_agility, inputs, outputs) {
+		common.Log_info("Agility SDK is up to date")
+		report_step_status(step_status_up_to_date)
+		return
+	}
+
+	common.Log_info("deploying D3D12 Agility SDK", config.agility_sdk_version)
+
+	var package_path = download_cached(package_url, "microsoft.direct3d.d3d12."+config.agility_sdk_version+".nupkg",
+		filepath.Join("agility_sdk", config.agility_sdk_version), config.agility_sdk_sha256)
+	if dry_run {
+		return
+	}
+
+	temp_directory, err := os.MkdirTemp("", "engine_post_build_agility")
+	if err != nil {
+		common.Log_fatal("failed to create temporary directory, error:", err)
+	}
+	defer os.RemoveAll(temp_directory)
+
+	// Extract to the temporary directory first to check the architecture.
+	var extracted_paths []string
+	for _, dll_name := range dll_names {
+		var entry_name = "build/native/bin/" + config.agility_sdk_arch + "/" + dll_name
+		var extracted_path = filepath.Join(temp_directory, dll_name)
+		extract_zip_entry(package_path, entry_name, extracted_path)
+		extracted_paths = append(extracted_paths, extracted_path)
+	}
+	check_binaries_arch(extracted_paths)
+
+	for _, directory := range target_directories {
+		var destination_directory = filepath.Join(directory, agility_sdk_directory_name)
+		make_directory(destination_directory)
+
+		for _, extracted_path := range extracted_paths {
+			copy(extracted_path, filepath.Join(destination_directory, filepath.Base(extracted_path)))
+		}
+	}
+
+	stamps.update(step_agility, inputs, outputs)
+
+	common.Log_success("D3D12 Agility SDK", config.agility_sdk_version, "was deployed")
+}
+
+// Extracts a single file from the zip archive (entry name is case-insensitive).
+func extract_zip_entry(archive_path string, entry_name string, destination string) {
+	if dry_run {
+		common.Log_info("[dry run] extract", entry_name, "from", archive_path, "to", destination)
+		return
+	}
+
+	reader, err := zip.OpenReader(archive_path)
+	if err != nil {
+		common.Log_fatal("failed to open archive", archive_path, "error:", err)
+	}
+	defer reader.Close()
+
+	for _, file := range reader.File {
+		if !strings.EqualFold(file.Name, entry_name) {
+			continue
+		}
+
+		source, err := file.Open()
+		if err != nil {
+			common.Log_fatal("failed to open", entry_name, "in archive", archive_path, "error:", err)
+		}
+		defer source.Close()
+
+		target, err := os.Create(destination)
+		if err != nil {
+			common.Log_fatal("failed to create file", destination, "error:", err)
+		}
+		defer target.Close()
+
+		bytes, err := io.Copy(target, source)
+		if err != nil {
+			common.Log_fatal("failed to extract", entry_name, "to", destination, "error:", err)
+		}
+
+		report_file(archive_path+"/"+entry_name, destination, bytes)
+		return
+	}
+
+	common.Log_fatal("archive", archive_path, "does not contain", entry_name)
+}
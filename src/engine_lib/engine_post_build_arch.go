@@ -0,0 +1,97 @@
+package main
+
+import (
+	"common"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"runtime"
+)
+
+// Architecture of the build target ("x64", "x86" or "arm64").
+var target_arch = ""
+
+// Returns architecture name for Go's GOARCH value.
+func get_default_target_arch() string {
+	switch runtime.GOARCH {
+	case "amd64":
+		return "x64"
+	case "386":
+		return "x86"
+	default:
+		return runtime.GOARCH
+	}
+}
+
+// Returns architecture of the specified PE/ELF/Mach-O binary or an empty string
+// if the file is not a binary (or the architecture is unknown).
+func get_binary_arch(path string) string {
+	if file, err := pe.Open(path); err == nil {
+		defer file.Close()
+		switch file.Machine {
+		case pe.IMAGE_FILE_MACHINE_AMD64:
+			return "x64"
+		case pe.IMAGE_FILE_MACHINE_I386:
+			return "x86"
+		case pe.IMAGE_FILE_MACHINE_ARM64:
+			return "arm64"
+		}
+		return "unknown"
+	}
+
+	if file, err := elf.Open(path); err == nil {
+		defer file.Close()
+		switch file.Machine {
+		case elf.EM_X86_64:
+			return "x64"
+		case elf.EM_386:
+			return "x86"
+		case elf.EM_AARCH64:
+			return "arm64"
+		}
+		return "unknown"
+	}
+
+	if file, err := macho.Open(path); err == nil {
+		defer file.Close()
+		switch file.Cpu {
+		case macho.CpuAmd64:
+			return "x64"
+		case macho.Cpu386:
+			return "x86"
+		case macho.CpuArm64:
+			return "arm64"
+		}
+		return "unknown"
+	}
+
+	return ""
+}
+
+// Exits with an error if some of the specified binaries (non-binary files are ignored)
+// has an architecture that does not match the build target.
+func check_binaries_arch(paths []string) {
+	var mismatch_count = 0
+	var checked_paths = map[string]bool{}
+
+	for _, path := range paths {
+		if checked_paths[path] {
+			continue
+		}
+		checked_paths[path] = true
+
+		var arch = get_binary_arch(path)
+		if arch == "" {
+			continue
+		}
+
+		if arch != target_arch {
+			common.Log_error(path, "has architecture", arch, "but the build target is", target_arch)
+			mismatch_count += 1
+		}
+	}
+
+	if mismatch_count != 0 {
+		common.Log_fatal(mismatch_count, "library(-ies) have unexpected architecture")
+	}
+}
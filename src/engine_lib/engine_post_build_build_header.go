@@ -0,0 +1,84 @@
+package main
+
+import (
+	"common"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Name of the generated C++ header with information about the build.
+const build_header_file_name = "build_info.h"
+
+// Prefix of the line with the build date, this line is ignored when checking
+// if the header needs to be updated.
+const build_header_date_line_prefix = "    constexpr std::string_view sBuildDate"
+
+// Writes "build_info.h" to the specified directory. The file is only written if the commit,
+// branch or version were changed so that the engine is not recompiled on every build (uncommitted
+// changes are not stored in the header for the same reason, see "build_info.json" instead).
+func write_build_header(info build_info, generated_directory string) {
+	var path = filepath.Join(generated_directory, build_header_file_name)
+
+	var content = strings.Join([]string{
+		"#pragma once",
+		"",
+		"// Generated by engine_post_build.go, do not edit.",
+		"",
+		"// Std.",
+		"#include <string_view>",
+		"",
+		"namespace ne {",
+		"    /** Hash of the git commit the engine was built from (\"unknown\" if not available). */",
+		"    constexpr std::string_view sBuildCommit = " + to_cpp_string(info.Commit) + ";",
+		"",
+		"    /** Name of the git branch the engine was built from. */",
+		"    constexpr std::string_view sBuildBranch = " + to_cpp_string(info.Branch) + ";",
+		"",
+		"    /** Version of the engine. */",
+		"    constexpr std::string_view sBuildVersion = " + to_cpp_string(info.EngineVersion) + ";",
+		"",
+		"    /** Date (UTC) when this file was generated. */",
+		build_header_date_line_prefix + " = " + to_cpp_string(info.Timestamp) + ";",
+		"} // namespace ne",
+		"",
+	}, "\n")
+
+	old_content, err := os.ReadFile(path)
+	if err == nil && strip_build_date(string(old_content)) == strip_build_date(content) {
+		common.Log_info(path, "is up to date")
+		report_step_status(step_status_up_to_date)
+		return
+	}
+
+	if dry_run {
+		common.Log_info("[dry run] write", path)
+		return
+	}
+
+	make_directory(generated_directory)
+
+	err = os.WriteFile(path, []byte(content), 0644)
+	if err != nil {
+		common.Log_fatal("failed to write", path, "error:", err)
+	}
+
+	common.Log_info("build information header was written to", path, "(commit", info.Commit+")")
+}
+
+// Removes the line with the build date from the header.
+func strip_build_date(content string) string {
+	var lines []string
+	for _, line := range strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n") {
+		if !strings.HasPrefix(line, build_header_date_line_prefix) {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Returns C++ string literal.
+func to_cpp_string(text string) string {
+	var replacer = strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n")
+	return "\"" + replacer.Replace(text) + "\""
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"common"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Name of the file (in the build directory) with information about the build.
+const build_info_file_name = "build_info.json"
+
+// Information about the build that is displayed in logs and crash reports.
+type build_info struct {
+	Commit        string `json:"commit"`
+	Branch        string `json:"branch"`
+	Dirty         bool   `json:"dirty"`
+	BuildType     string `json:"build_type"`
+	EngineVersion string `json:"engine_version"`
+	Toolchain     string `json:"toolchain"`
+	Platform      string `json:"platform"`
+	Timestamp     string `json:"timestamp"`
+}
+
+// Collects information about the build. "repository_directory" is any directory
+// inside of the git repository.
+func get_build_info(config *post_build_config, repository_directory string, is_release bool, toolchain string) build_info {
+	var info = build_info{
+		Commit:        "unknown",
+		Branch:        "unknown",
+		BuildType:     "debug",
+		EngineVersion: config.engine_version,
+		Toolchain:     toolchain,
+		Platform:      runtime.GOOS + "-" + target_arch,
+		Timestamp:     time.Now().UTC().Format(time.RFC3339),
+	}
+	if is_release {
+		info.BuildType = "release"
+	}
+
+	commit, err := run_git(repository_directory, "rev-parse", "HEAD")
+	if err != nil {
+		report_warning("failed to get git commit hash, error: " + err.Error())
+		return info
+	}
+	info.Commit = commit
+
+	branch, err := run_git(repository_directory, "rev-parse", "--abbrev-ref", "HEAD")
+	if err == nil {
+		info.Branch = branch
+	}
+
+	status, err := run_git(repository_directory, "status", "--porcelain", "--untracked-files=no")
+	if err == nil {
+		info.Dirty = status != ""
+	}
+
+	return info
+}
+
+// Runs git in the specified directory and returns its trimmed output.
+func run_git(directory string, args ...string) (string, error) {
+	output, err := exec.Command("git", append([]string{"-C", directory}, args...)...).Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// Writes information about the build to "build_info.json" in the build directory.
+func write_build_info(info build_info, build_directory string) {
+	var path = filepath.Join(build_directory, build_info_file_name)
+
+	if dry_run {
+		common.Log_info("[dry run] write build information to", path)
+		return
+	}
+
+	content, err := json.MarshalIndent(info, "", "    ")
+	if err != nil {
+		common.Log_fatal("failed to serialize build information, error:", err)
+	}
+
+	err = os.WriteFile(path, content, 0644)
+	if err != nil {
+		common.Log_fatal("failed to write", path, "error:", err)
+	}
+
+	common.Log_info("build information was written to", path, "(commit", info.Commit+")")
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"common"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Returns path to the directory where downloaded files are cached between builds.
+func get_cache_directory() string {
+	user_cache_directory, err := os.UserCacheDir()
+	if err != nil {
+		common.Log_fatal("failed to get user cache directory, error:", err)
+	}
+
+	return filepath.Join(user_cache_directory, "nameless-engine")
+}
+
+// Returns path to the cached file downloaded from the specified URL, downloads the file
+// if it's not cached yet. "cache_key" is a relative directory in the cache (usually includes
+// name and version of the file) and "expected_sha256" (if not empty) is used to check the file.
+func download_cached(URL string, file_name string, cache_key string, expected_sha256 string) string {
+	var cached_path = filepath.Join(get_cache_directory(), cache_key, file_name)
+
+	if _, err := os.Stat(cached_path); err == nil {
+		if expected_sha256 == "" || strings.EqualFold(get_file_sha256(cached_path), expected_sha256) {
+			common.Log_verbose("using cached file", cached_path)
+			return cached_path
+		}
+
+		common.Log_warning("cached file", cached_path, "has unexpected checksum, downloading it again")
+		remove_all(cached_path)
+	}
+
+	if dry_run {
+		common.Log_info("[dry run] download", URL, "to", cached_path)
+		return cached_path
+	}
+
+	make_directory(filepath.Dir(cached_path))
+
+	// Download to a temporary file so that interrupted downloads are not cached.
+	var partial_path = cached_path + ".part"
+	download_file_to(URL, partial_path)
+
+	if expected_sha256 != "" {
+		verify_file_sha256(partial_path, expected_sha256)
+	} else {
+		common.Log_warning("no checksum is specified for", URL, "skipping checksum verification")
+	}
+
+	var err = os.Rename(partial_path, cached_path)
+	if err != nil {
+		common.Log_fatal("failed to rename", partial_path, "to", cached_path, "error:", err)
+	}
+
+	return cached_path
+}
+
+// Returns SHA-256 of the specified file as a hex string.
+func get_file_sha256(path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		common.Log_fatal("failed to open file", path, "error:", err)
+	}
+	defer file.Close()
+
+	var hasher = sha256.New()
+	_, err = io.Copy(hasher, file)
+	if err != nil {
+		common.Log_fatal("failed to read file", path, "error:", err)
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
+// Exits with an error if SHA-256 of the file does not match the expected value.
+func verify_file_sha256(path string, expected_sha256 string) {
+	var actual_sha256 = get_file_sha256(path)
+	if !strings.EqualFold(actual_sha256, expected_sha256) {
+		common.Log_fatal("checksum mismatch for", path, "expected SHA-256", expected_sha256, "actual", actual_sha256)
+	}
+	common.Log_verbose("checksum of", path, "is valid")
+}
@@ -0,0 +1,10 @@
+package main
+
+import "os/exec"
+
+// Tries to create a copy-on-write clone of the file (supported by APFS),
+// returns false if the filesystem does not support it.
+func try_clone_file(src string, dst string) bool {
+	// `cp -c` uses clonefile(2) and fails if cloning is not supported.
+	return exec.Command("/bin/cp", "-c", src, dst).Run() == nil
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// ioctl request that makes the destination file share data with the source file (copy-on-write).
+const ioctl_ficlone = 0x40049409
+
+// Tries to create a copy-on-write clone of the file (supported by Btrfs, XFS and some
+// other filesystems), returns false if the filesystem does not support it.
+func try_clone_file(src string, dst string) bool {
+	source, err := os.Open(src)
+	if err != nil {
+		return false
+	}
+	defer source.Close()
+
+	destination, err := os.Create(dst)
+	if err != nil {
+		return false
+	}
+	defer destination.Close()
+
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, destination.Fd(), ioctl_ficlone, source.Fd())
+	return errno == 0
+}
@@ -0,0 +1,8 @@
+//go:build !linux && !darwin
+
+package main
+
+// Tries to create a copy-on-write clone of the file, returns false if not supported.
+func try_clone_file(src string, dst string) bool {
+	return false
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"common"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Compresses the executable using UPX, then tests the compressed executable (using `upx -t`
+// and by launching it with "smoke_test" settings if configured) and restores the original
+// executable if the test fails.
+func compress_binary(config *post_build_config, binary_path string, stamps *post_build_stamps) {
+	if _, err := os.Stat(binary_path); os.IsNotExist(err) {
+		common.Log_fatal("binary", binary_path, "does not exist")
+	}
+
+	// The executable is modified in place so it's only an output.
+	var inputs = fingerprint_files(nil, append([]string{binary_path}, config.compress_args...)...)
+	if stamps.is_up_to_date(step_compress, inputs, []string{binary_path}) {
+		common.Log_info("compressed executable is up to date")
+		report_step_status(step_status_up_to_date)
+		return
+	}
+
+	upx, err := exec.LookPath(config.compress_upx)
+	if err != nil {
+		common.Log_fatal("\"upx\" was not found, specify \"compress.upx\" in the config, error:", err)
+	}
+
+	if dry_run {
+		common.Log_info("[dry run] compress", binary_path, "using", upx, strings.Join(config.compress_args, " "))
+		return
+	}
+
+	// Happens if the executable was not rebuilt but the settings were changed.
+	if exec.Command(upx, "-q", "-t", binary_path).Run() == nil {
+		common.Log_info(filepath.Base(binary_path), "is already compressed, decompressing it")
+		output, err := exec.Command(upx, "-q", "-d", binary_path).CombinedOutput()
+		if err != nil {
+			common.Log_fatal("failed to decompress", binary_path, "error:", err, "output:", strings.TrimSpace(string(output)))
+		}
+	}
+
+	var original_size int64 = 0
+	if info, err := os.Stat(binary_path); err == nil {
+		original_size = info.Size()
+	}
+
+	var backup_path = binary_path + ".uncompressed"
+	copy(binary_path, backup_path)
+	defer os.Remove(backup_path)
+
+	common.Log_info("compressing", filepath.Base(binary_path))
+
+	var restore = func() {
+		var err = os.Rename(backup_path, binary_path)
+		if err != nil {
+			common.Log_error("failed to restore", binary_path, "from", backup_path, "error:", err)
+		}
+	}
+
+	output, err := exec.Command(upx, append(append([]string{"-q"}, config.compress_args...), binary_path)...).CombinedOutput()
+	if err == nil {
+		output, err = exec.Command(upx, "-q", "-t", binary_path).CombinedOutput()
+	}
+	if err != nil {
+		restore()
+		common.Log_fatal("failed to compress", binary_path, "error:", err, "output:", strings.TrimSpace(string(output)))
+	}
+
+	if config.smoke_test_enabled {
+		output, err := launch_binary(binary_path, config.smoke_test_args, config.smoke_test_timeout)
+		if err != nil {
+			print_smoke_test_output(output)
+			restore()
+			common.Log_fatal("compressed executable failed to run (the original executable was restored):", err)
+		}
+	} else {
+		common.Log_verbose("\"smoke_test\" is not configured, compressed executable was not launched")
+	}
+
+	stamps.update(step_compress, inputs, []string{binary_path})
+
+	if info, err := os.Stat(binary_path); err == nil {
+		common.Log_success("compressed", filepath.Base(binary_path), "from", common.Format_byte_count(original_size), "to",
+			common.Format_byte_count(info.Size()))
+	}
+}
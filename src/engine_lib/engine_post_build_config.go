@@ -0,0 +1,1096 @@
+package main
+
+import (
+	"common"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// Optional project configuration of the post build script (post_build.toml).
+//
+// Example:
+//
+//	# Additional runtime libraries/assets to copy next to the binary.
+//	[[libs]]
+//	source = "ext/foo/bin/foo.dll"   # file, directory or glob, relative to the config file
+//	destination = "."                # optional, relative to the build directory
+//	platforms = ["windows"]          # optional, values of Go's GOOS
+//	build_modes = ["debug"]          # optional, "debug" and/or "release"
+//
+//	# Deploy D3D12 Agility SDK (Windows only). The game executable needs to export
+//	# `D3D12SDKVersion` (matching the package) and `D3D12SDKPath = ".\\D3D12\\"`.
+//	[agility_sdk]
+//	version = "1.610.4"              # version of the Microsoft.Direct3D.D3D12 NuGet package
+//	sha256 = "..."                   # optional, expected SHA-256 of the package
+//	arch = "x64"                     # optional, "x64" (default), "win32" or "arm64"
+//
+//	# Expected versions of runtime libraries in the build directory (checked after copying).
+//	[[verify]]
+//	file = "dxcompiler.dll"          # relative to the build directory
+//	sha256 = "..."                   # optional, expected SHA-256 of the file
+//	version = "1.6.2112.0"           # optional, expected file version (Windows PE files only)
+//	platforms = ["windows"]          # optional
+//
+//	# Visual C++ redistributable package that is placed into the "redist" directory in release builds.
+//	[redist]
+//	vc_redist_url = "https://download.visualstudio.microsoft.com/.../VC_redist.x64.exe"  # optional, a versioned URL
+//	vc_redist_sha256 = "..."         # expected SHA-256 of the file (required if "vc_redist_url" is specified),
+//	                                 # downloaded packages are cached by it
+//	directx_runtime = true           # optional, also add DirectX End-User Runtime web installer
+//	directx_runtime_url = "..."      # optional
+//	directx_runtime_sha256 = "..."   # optional, expected SHA-256 of the file
+//
+//	# Steam API deployment (the step is enabled if this section exists or "--steam" is specified).
+//	[steam]
+//	app_id = 480                     # written to "steam_appid.txt" in debug builds
+//	sdk_path = "ext/steamworks_sdk"  # path to the Steamworks SDK ("sdk" directory), relative to the config file
+//	enabled = false                  # optional, use to only enable the step with "--steam"
+//
+//	# Launch of the built executable after the build (see "--binary"), the step is enabled if this section exists.
+//	# The executable should handle the arguments and exit on its own (the editor is not launched).
+//	[smoke_test]
+//	args = ["--headless", "--selftest"]  # optional, arguments of the executable
+//	timeout = 30                     # optional, seconds to wait for the executable to exit
+//
+//	# Compression of the release executable (see "--binary") using UPX on Windows and Linux (the step is enabled
+//	# if this section exists). The compressed executable is launched with "smoke_test" settings (if configured)
+//	# and restored if it fails.
+//	[compress]
+//	upx = "upx"                      # optional, path to UPX
+//	args = ["--best", "--lzma"]      # optional, "--best" by default
+//
+//	# Size tracking of release builds (the step is enabled if this section exists). Sizes of the executable
+//	# (see "--binary"), shared libraries in the build directory and the 'res' directory are appended to a JSON
+//	# history file and compared with the previous build.
+//	[size]
+//	history = "ci/size_history.json" # optional, relative to the config file, "size_history.json" in the build directory by default
+//	max_entries = 100                # optional, number of builds to keep in the history (0 to keep all)
+//	warn_percent = 5                 # optional, warn if a size grows by more than this percentage
+//	fail_percent = 20                # optional, fail if a size grows by more than this percentage (disabled by default)
+//
+//	# Structure of the 'res' directory that is checked in debug builds (the step is enabled by default).
+//	[res_check]
+//	required = ["engine/shaders", "game/.gitignore"]  # optional, paths relative to 'res', "engine/shaders" by default
+//	forbidden = ["*.psd", "raw/*"]   # optional, globs of files/directories, asset sources (psd, blend, max, ma, mb) by default
+//	max_file_size_mib = 100          # optional, maximum size of a file (0 to disable)
+//	enabled = false                  # optional, use to disable the step
+//
+//	# Validation of image files in the 'res' directory (the step is enabled if this section exists).
+//	[textures]
+//	formats = ["png", "dds", "ktx2"]  # optional, allowed extensions, formats supported by stb_image, "dds" and "ktx2" by default
+//	power_of_two = false             # optional, require power-of-two width and height
+//	max_size = 4096                  # optional, maximum width and height (0 to disable)
+//	colorspace = "srgb"              # optional, "srgb" or "linear", not checked by default
+//
+//	# Settings of image files in a specific directory (settings that are not specified are taken from
+//	# the "textures" section). Block-compressed textures are always checked to have sizes divisible by the block size.
+//	[[textures.rules]]
+//	directory = "game/textures/normal"  # relative to the 'res' directory, the rule with the longest matching directory is used
+//	formats = ["ktx2"]               # optional
+//	power_of_two = true              # optional
+//	max_size = 2048                  # optional
+//	colorspace = "linear"            # optional
+//
+//	# Conversion of source textures in the 'res' directory of release builds to GPU-ready formats (the step
+//	# is enabled if this section exists, requires "--copy-res"). Converted textures replace the sources
+//	# in the build directory (textures that already have the converted extension are kept as is) and are cached
+//	# by hashes of the sources.
+//	[cook_textures]
+//	tool = "compressonator"          # optional, "compressonator" (DDS, downloaded if "path" is empty) or "toktx" (KTX2)
+//	path = "tools/toktx"             # optional, relative to the config file, "toktx" is searched in PATH by default
+//	sha256 = "..."                   # optional, expected SHA-256 of the downloaded Compressonator archive
+//	format = "BC7"                   # optional, Compressonator "-fd" format, "BC7" by default
+//	linear_format = "BC5"            # optional, Compressonator "-fd" format of "linear" textures, "format" by default
+//	encode = "uastc"                 # optional, toktx "--encode" value ("uastc", "etc1s" or "astc"), "uastc" by default
+//	mipmaps = true                   # optional, generate mipmaps
+//	sources = ["*.png", "*.tga"]     # optional, globs of textures to convert (relative to 'res')
+//	exclude = ["editor/*"]           # optional, globs of textures to keep as is
+//	linear = ["*_normal.png"]        # optional, globs of textures with linear (non-color) data, others are sRGB
+//	args = ["-Quality", "0.1"]       # optional, additional arguments of the tool
+//
+//	# Conversion of source audio files in the 'res' directory of release builds to Ogg/Opus or Ogg/Vorbis
+//	# using FFmpeg with loudness normalization (the step is enabled if this section exists, requires "--copy-res").
+//	# Converted files replace the sources in the build directory (files that already have the converted extension
+//	# are kept as is) and are cached by hashes of the sources.
+//	[cook_audio]
+//	path = "tools/ffmpeg"            # optional, relative to the config file, "ffmpeg" is searched in PATH by default
+//	format = "opus"                  # optional, "opus" (.opus) or "ogg" (Vorbis, .ogg), "opus" by default
+//	bitrate = 96                     # optional, in kbit/s, 96 by default
+//	loudness = -16                   # optional, target integrated loudness in LUFS (0 to disable normalization), -16 by default
+//	true_peak = -1.5                 # optional, maximum true peak in dBTP, -1.5 by default
+//	sources = ["*.wav"]              # optional, globs of audio files to convert (relative to 'res')
+//	exclude = ["game/ui/*"]          # optional, globs of audio files to keep as is
+//	args = ["-ac", "1"]              # optional, additional arguments of FFmpeg
+//
+//	# Settings of audio files in a specific directory (settings that are not specified are taken from
+//	# the "cook_audio" section).
+//	[[cook_audio.rules]]
+//	directory = "game/music"         # relative to the 'res' directory, the rule with the longest matching directory is used
+//	bitrate = 160                    # optional
+//	loudness = -18                   # optional
+//
+//	# Graphics debugging libraries copied next to the binary in debug builds.
+//	[graphics_debugging]
+//	pix = "ext/WinPixEventRuntime/bin/x64/WinPixEventRuntime.dll"  # optional, Windows only, relative to the config file
+//	renderdoc = "auto"               # optional, path to renderdoc.dll/librenderdoc.so or "auto" to use installed RenderDoc
+//
+//	# Information about the build written to "build_info.json" in the build directory.
+//	[build_info]
+//	engine_version = "0.1.0"         # optional
+//
+//	# Icon and version information embedded into the executable (see "--binary", Windows only).
+//	[executable]
+//	icon = "res/game/icon.ico"       # optional, relative to the config file
+//	version = "1.0.0.0"              # optional, up to 4 numbers, version information is added only if set
+//	product_name = "My Game"         # optional
+//	company_name = "My Company"      # optional
+//	description = "My Game"          # optional
+//	copyright = "Copyright (C) ..."  # optional
+//
+//	# Code signing of release binaries (the step is enabled if this section exists).
+//	[signing]
+//	tool = "signtool"                # optional, "signtool" (default on Windows) or "osslsigncode"
+//	certificate_env = "NE_SIGNING_CERTIFICATE"  # optional, variable with path to the .pfx certificate
+//	password_env = "NE_SIGNING_PASSWORD"        # optional, variable with password of the certificate
+//	timestamp_url = "http://timestamp.digicert.com"  # optional, empty to disable timestamping
+//	files = ["*.exe", "*.dll"]       # optional, globs relative to the build directory
+//
+//	# Distributable archives of release builds (the step is enabled if this section exists or "--package" is specified).
+//	[package]
+//	name = "my_game"                 # optional, name of the binary (see "--binary") by default
+//	version = "1.0.0"                # optional, "executable.version" or "build_info.engine_version" by default
+//	formats = ["zip", "tar.zst"]     # optional, "zip" (default), "tar.gz" and/or "tar.zst" (requires zstd)
+//	output_directory = "dist"        # optional, relative to the config file, "package" in the build directory by default
+//	exclude = ["*.txt", "data/dev"]  # optional, globs of files/directories to not include
+//	keep = ["symbols/game.pdb"]      # optional, globs of files/directories to include even if they are excluded
+//	checksums = true                 # optional, write "SHA256SUMS" of packages and redistributable packages
+//	checksum_files = false           # optional, also write "<file>.sha256" next to each of these files
+//	sbom = true                      # optional, write CycloneDX SBOM with the engine and all dependencies
+//	verify_launch = false            # optional, launch the packaged executable with "smoke_test" settings before packing
+//	enabled = false                  # optional, use to only enable the step with "--package"
+//
+//	# Additional exclude/keep patterns of packages created on specific platforms.
+//	[[package.rules]]
+//	platforms = ["windows"]          # optional, values of Go's GOOS
+//	exclude = ["*.sh"]               # optional
+//	keep = ["crashpad_handler.pdb"]  # optional
+//
+//	# Windows installer created together with the packages (requires NSIS).
+//	[package.installer]
+//	publisher = "My Company"         # optional, "executable.company_name" by default
+//	license = "LICENSE.txt"          # optional, text of the license page, relative to the config file
+//	desktop_shortcut = true          # optional, a start menu shortcut is always created
+//	template = "installer.nsi"       # optional, custom NSIS script (see `default_installer_template`)
+//
+//	# Freedesktop entry, hicolor icons and "install.sh" added to Linux packages.
+//	[package.linux_desktop]
+//	id = "com.example.MyGame"        # optional, name of the .desktop and icon files, package name by default
+//	display_name = "My Game"         # optional, package name by default
+//	comment = "An example game"      # optional
+//	categories = ["Game"]            # optional
+//	icons = ["res/game/icon_256.png"]  # optional, square PNG icons, relative to the config file
+//	template = "game.desktop"        # optional, custom desktop entry (see `default_desktop_entry_template`)
+//
+//	# Application bundle that is packaged instead of the build directory on macOS. Dylibs are placed
+//	# into "Contents/Frameworks" and other files (such as 'res') into "Contents/Resources".
+//	[package.macos_bundle]
+//	bundle_id = "com.example.mygame"
+//	display_name = "My Game"         # optional, package name by default
+//	icon = "res/game/icon.icns"      # optional, relative to the config file
+//	minimum_system_version = "11.0"  # optional
+//	info_plist = "Info.plist"        # optional, custom template (see `default_info_plist_template`)
+//	signing_identity_env = "NE_MACOS_SIGNING_IDENTITY"  # optional, variable with codesign identity, not signed if empty
+//	entitlements = "game.entitlements"  # optional, relative to the config file
+//	notarize = false                 # optional, requires NE_NOTARY_APPLE_ID, NE_NOTARY_TEAM_ID and NE_NOTARY_PASSWORD
+//
+//	# Steam build scripts (written to "steam" in the package output directory) with packaged files as depot content.
+//	[package.steam_depot]
+//	depot_id = 481
+//	app_id = 480                     # optional, "steam.app_id" by default
+//	branch = "beta"                  # optional, branch to set the build live on (can't be "default")
+//	description = "nightly build"    # optional, package name and version by default
+//	upload = false                   # optional, upload using steamcmd, requires NE_STEAM_USERNAME and NE_STEAM_PASSWORD
+//	steamcmd = "steamcmd"            # optional, name or path of steamcmd
+//
+//	# Commands to run before/after steps. Placeholders {res_dir}, {ext_dir}, {engine_lib_dir}, {work_dir},
+//	# {build_dir}, {binary} and {release} are replaced in the command, the same values are also available
+//	# as environment variables (NE_HOOK_RES_DIR, NE_HOOK_BUILD_DIR, etc.). Steps that run once for all targets
+//	# ("build_header", "res_check" and "textures") use values of the first target.
+//	[[hooks]]
+//	step = "res"                     # name of the step (see "--steps")
+//	when = "after"                   # optional, "before" or "after" (default)
+//	command = ["python", "tools/cook_assets.py", "{build_dir}"]
+//	working_directory = "."          # optional, relative to the config file
+//
+//	# License check of dependencies from the 'ext' directory (licenses are detected from license files).
+//	[licenses]
+//	deny = ["GPL-2.0", "GPL-3.0", "AGPL-3.0"]  # optional, SPDX identifiers of licenses that are not allowed
+//	allow_unknown = false            # optional, allow licenses that were not recognized
+//	overrides = { foo = "MIT" }      # optional, licenses of dependencies (by directory name) that are not recognized
+//	cmake_files = ["src/engine_lib/CMakeLists.txt"]  # optional, also check licenses of 'ext' dependencies used in these files
+//
+//	# Settings of the missing dependency check of the binary (see "--binary").
+//	[deps]
+//	allow = ["steam_api64.dll"]      # additional libraries that are expected to exist on user machines
+type post_build_config struct {
+	// Path to the config file, empty if no config is used.
+	path string
+
+	// Directory of the config file, relative paths from the config are relative to this directory.
+	directory string
+
+	// Additional files to copy to the build directory.
+	libs []config_copy_entry
+
+	// D3D12 Agility SDK settings, empty version if not used.
+	agility_sdk_version string
+	agility_sdk_sha256  string
+	agility_sdk_arch    string
+
+	// Expected hashes/versions of runtime libraries.
+	verify []config_verify_entry
+
+	// Visual C++ redistributable package settings.
+	vc_redist_url    string
+	vc_redist_sha256 string
+
+	// DirectX End-User Runtime settings.
+	directx_runtime        bool
+	directx_runtime_url    string
+	directx_runtime_sha256 string
+
+	// License policy of dependencies.
+	licenses_deny          []string
+	licenses_allow_unknown bool
+	license_overrides      map[string]string
+	licenses_cmake_files   []string
+
+	// Libraries that are allowed to be missing in the build directory.
+	deps_allow []string
+
+	// Steamworks settings, used only if `steam_enabled` is true.
+	steam_configured bool
+	steam_enabled    bool
+	steam_app_id     int64
+	steam_sdk_path   string
+
+	// Executable compression settings, used only if `compress_enabled` is true.
+	compress_enabled bool
+	compress_upx     string
+	compress_args    []string
+
+	// Build size tracking settings, used only if `size_enabled` is true.
+	size_enabled      bool
+	size_history      string
+	size_max_entries  int64
+	size_warn_percent int64
+	size_fail_percent int64
+
+	// 'res' directory checks, used only if `res_check_enabled` is true.
+	res_check_enabled           bool
+	res_check_required          []string
+	res_check_forbidden         []string
+	res_check_max_file_size_mib int64
+
+	// Texture validation settings, used only if `textures_enabled` is true. The first rule
+	// has an empty directory and contains default settings.
+	textures_enabled bool
+	texture_rules    []config_texture_rule
+
+	// Texture conversion settings, used only if `cook_textures_enabled` is true.
+	cook_textures_enabled       bool
+	cook_textures_tool          string
+	cook_textures_path          string
+	cook_textures_sha256        string
+	cook_textures_format        string
+	cook_textures_linear_format string
+	cook_textures_encode        string
+	cook_textures_mipmaps       bool
+	cook_textures_sources       []string
+	cook_textures_excludes      []string
+	cook_textures_linear        []string
+	cook_textures_args          []string
+
+	// Audio conversion settings, used only if `cook_audio_enabled` is true. The first rule
+	// has an empty directory and contains default settings.
+	cook_audio_enabled   bool
+	cook_audio_path      string
+	cook_audio_format    string
+	cook_audio_true_peak float64
+	cook_audio_sources   []string
+	cook_audio_excludes  []string
+	cook_audio_args      []string
+	audio_rules          []config_audio_rule
+
+	// Smoke test settings, used only if `smoke_test_enabled` is true.
+	smoke_test_enabled bool
+	smoke_test_args    []string
+	smoke_test_timeout int64
+
+	// Graphics debugging libraries, empty if not used.
+	pix_library       string
+	renderdoc_library string
+
+	// Version of the engine written to the build information file.
+	engine_version string
+
+	// Icon and version information of the executable, empty if not used.
+	executable_icon         string
+	executable_version      string
+	executable_product_name string
+	executable_company_name string
+	executable_description  string
+	executable_copyright    string
+
+	// Commands to run before/after steps.
+	hooks []config_hook
+
+	// Code signing settings, used only if `signing_enabled` is true.
+	signing_enabled         bool
+	signing_tool            string
+	signing_certificate_env string
+	signing_password_env    string
+	signing_timestamp_url   string
+	signing_files           []string
+
+	// Packaging settings, used only if `package_enabled` is true or "--package" is specified.
+	package_enabled          bool
+	package_name             string
+	package_version          string
+	package_formats          []string
+	package_output_directory string
+	package_excludes         []string
+	package_keeps            []string
+	package_checksums        bool
+	package_checksum_files   bool
+	package_sbom             bool
+	package_verify_launch    bool
+
+	// Windows installer settings, used only if `installer_enabled` is true.
+	installer_enabled          bool
+	installer_publisher        string
+	installer_license          string
+	installer_desktop_shortcut bool
+	installer_template         string
+
+	// Linux desktop integration settings, used only if `linux_desktop_enabled` is true.
+	linux_desktop_enabled      bool
+	linux_desktop_id           string
+	linux_desktop_display_name string
+	linux_desktop_comment      string
+	linux_desktop_categories   []string
+	linux_desktop_icons        []string
+	linux_desktop_template     string
+
+	// macOS application bundle settings, used only if `macos_bundle_enabled` is true.
+	macos_bundle_enabled                bool
+	macos_bundle_id                     string
+	macos_bundle_display_name           string
+	macos_bundle_icon                   string
+	macos_bundle_minimum_system_version string
+	macos_bundle_info_plist             string
+	macos_bundle_signing_identity_env   string
+	macos_bundle_entitlements           string
+	macos_bundle_notarize               bool
+
+	// Steam depot settings, used only if `steam_depot_enabled` is true.
+	steam_depot_enabled     bool
+	steam_depot_app_id      int64
+	steam_depot_id          int64
+	steam_depot_branch      string
+	steam_depot_description string
+	steam_depot_upload      bool
+	steam_depot_steamcmd    string
+}
+
+// Default Visual C++ redistributable package (version 14.38.33135) and its SHA-256. A versioned URL
+// is used (instead of "https://aka.ms/vs/17/release/vc_redist.x64.exe" that always points to the latest
+// package) so that the checksum can be pinned.
+const default_vc_redist_url = "https://download.visualstudio.microsoft.com/download/pr/c7707d68-d6ce-4479-973e-e2a3dc4341fe/" +
+	"1AD7988C17663CC742B01BEF1A6DF2ED1741173009579AD50A94434E54F56073/VC_redist.x64.exe"
+const default_vc_redist_sha256 = "1ad7988c17663cc742b01bef1a6df2ed1741173009579ad50a94434e54f56073"
+
+// DirectX End-User Runtime web installer (for XAudio 2.7, D3DX and other legacy components).
+const default_directx_runtime_url = "https://download.microsoft.com/download/1/7/1/1718CCC4-6315-4D8E-9543-8E28A4E18C4C/dxwebsetup.exe"
+
+type config_copy_entry struct {
+	source      string
+	destination string
+	platforms   []string
+	build_modes []string
+}
+
+type config_hook struct {
+	step              string
+	when              string
+	command           []string
+	working_directory string
+}
+
+type config_texture_rule struct {
+	directory    string // relative to the 'res' directory, uses '/' as separator
+	formats      []string
+	power_of_two bool
+	max_size     int64
+	colorspace   string
+}
+
+type config_audio_rule struct {
+	directory string // relative to the 'res' directory, uses '/' as separator
+	bitrate   int64
+	loudness  float64
+}
+
+type config_verify_entry struct {
+	file      string
+	sha256    string
+	version   string
+	platforms []string
+}
+
+// Loads post build config from the specified path. If the path is empty or the file
+// does not exist returns an empty config.
+func load_post_build_config(path string) post_build_config {
+	var config = post_build_config{
+		vc_redist_url:       default_vc_redist_url,
+		vc_redist_sha256:    default_vc_redist_sha256,
+		directx_runtime_url: default_directx_runtime_url,
+		package_formats:     []string{package_format_zip},
+		package_checksums:   true,
+		package_sbom:        true,
+		licenses_deny:       default_denied_licenses,
+
+		res_check_enabled:           true,
+		res_check_required:          default_res_required_paths,
+		res_check_forbidden:         default_res_forbidden_patterns,
+		res_check_max_file_size_mib: default_res_max_file_size_mib,
+	}
+
+	if path == "" {
+		return config
+	}
+
+	var _, err = os.Stat(path)
+	if os.IsNotExist(err) {
+		common.Log_info("config file", path, "does not exist, using default settings")
+		return config
+	}
+
+	root, err := parse_toml_file(path)
+	if err != nil {
+		common.Log_fatal("failed to parse config file, error:", err)
+	}
+
+	common.Log_info("using config file", path)
+
+	config.path = path
+	config.directory = filepath.Dir(path)
+
+	for _, lib_table := range config_get_table_array(root, "libs") {
+		var entry = config_copy_entry{
+			source:      config_get_string(lib_table, "source", ""),
+			destination: config_get_string(lib_table, "destination", "."),
+			platforms:   config_get_string_array(lib_table, "platforms"),
+			build_modes: config_get_string_array(lib_table, "build_modes"),
+		}
+		if entry.source == "" {
+			common.Log_fatal("config file", path, "has a \"libs\" entry without \"source\"")
+		}
+		config.libs = append(config.libs, entry)
+	}
+
+	for _, verify_table := range config_get_table_array(root, "verify") {
+		var entry = config_verify_entry{
+			file:      config_get_string(verify_table, "file", ""),
+			sha256:    config_get_string(verify_table, "sha256", ""),
+			version:   config_get_string(verify_table, "version", ""),
+			platforms: config_get_string_array(verify_table, "platforms"),
+		}
+		if entry.file == "" || (entry.sha256 == "" && entry.version == "") {
+			common.Log_fatal("config file", path, "has a \"verify\" entry without \"file\" or without \"sha256\"/\"version\"")
+		}
+		config.verify = append(config.verify, entry)
+	}
+
+	for _, hook_table := range config_get_table_array(root, "hooks") {
+		var hook = config_hook{
+			step:              config_get_string(hook_table, "step", ""),
+			when:              config_get_string(hook_table, "when", hook_after),
+			command:           config_get_string_array(hook_table, "command"),
+			working_directory: config_get_string(hook_table, "working_directory", "."),
+		}
+		if !contains_string(all_steps, hook.step) {
+			common.Log_fatal("config file", path, "has a hook for unknown step", "\""+hook.step+"\"")
+		}
+		if hook.when != hook_before && hook.when != hook_after {
+			common.Log_fatal("config file", path, "has a hook with invalid \"when\" value", hook.when,
+				"expected \"before\" or \"after\"")
+		}
+		if len(hook.command) == 0 {
+			common.Log_fatal("config file", path, "has a hook without \"command\"")
+		}
+		config.hooks = append(config.hooks, hook)
+	}
+
+	var redist_table = config_get_table(root, "redist")
+	if redist_table != nil {
+		config.vc_redist_url = config_get_string(redist_table, "vc_redist_url", config.vc_redist_url)
+		config.vc_redist_sha256 = config_get_string(redist_table, "vc_redist_sha256", "")
+		if config.vc_redist_url == default_vc_redist_url && config.vc_redist_sha256 == "" {
+			config.vc_redist_sha256 = default_vc_redist_sha256
+		}
+		config.directx_runtime = config_get_bool(redist_table, "directx_runtime", false)
+		config.directx_runtime_url = config_get_string(redist_table, "directx_runtime_url", config.directx_runtime_url)
+		config.directx_runtime_sha256 = config_get_string(redist_table, "directx_runtime_sha256", "")
+	}
+
+	var licenses_table = config_get_table(root, "licenses")
+	if licenses_table != nil {
+		if deny := config_get_string_array(licenses_table, "deny"); deny != nil {
+			config.licenses_deny = deny
+		}
+		config.licenses_allow_unknown = config_get_bool(licenses_table, "allow_unknown", false)
+		config.licenses_cmake_files = config_get_string_array(licenses_table, "cmake_files")
+
+		var overrides_table = config_get_table(licenses_table, "overrides")
+		config.license_overrides = map[string]string{}
+		for name := range overrides_table {
+			config.license_overrides[name] = config_get_string(overrides_table, name, "")
+		}
+	}
+
+	var deps_table = config_get_table(root, "deps")
+	if deps_table != nil {
+		// Library names are compared in lower case.
+		for _, name := range config_get_string_array(deps_table, "allow") {
+			config.deps_allow = append(config.deps_allow, strings.ToLower(name))
+		}
+	}
+
+	var steam_table = config_get_table(root, "steam")
+	if steam_table != nil {
+		config.steam_configured = true
+		config.steam_enabled = config_get_bool(steam_table, "enabled", true)
+		config.steam_app_id = config_get_int(steam_table, "app_id", 0)
+		config.steam_sdk_path = config_get_string(steam_table, "sdk_path", "")
+		if config.steam_app_id <= 0 || config.steam_sdk_path == "" {
+			common.Log_fatal("config file", path, "has \"steam\" section without \"app_id\" or \"sdk_path\"")
+		}
+	}
+
+	var compress_table = config_get_table(root, "compress")
+	if compress_table != nil {
+		config.compress_enabled = true
+		config.compress_upx = config_get_string(compress_table, "upx", "upx")
+		config.compress_args = config_get_string_array(compress_table, "args")
+		if config.compress_args == nil {
+			config.compress_args = []string{"--best"}
+		}
+	}
+
+	var size_table = config_get_table(root, "size")
+	if size_table != nil {
+		config.size_enabled = true
+		config.size_history = config_get_string(size_table, "history", "")
+		config.size_max_entries = config_get_int(size_table, "max_entries", 100)
+		config.size_warn_percent = config_get_int(size_table, "warn_percent", 5)
+		config.size_fail_percent = config_get_int(size_table, "fail_percent", 0)
+		if config.size_max_entries < 0 || config.size_warn_percent < 0 || config.size_fail_percent < 0 {
+			common.Log_fatal("config file", path, "has negative values in \"size\" section")
+		}
+	}
+
+	var res_check_table = config_get_table(root, "res_check")
+	if res_check_table != nil {
+		config.res_check_enabled = config_get_bool(res_check_table, "enabled", true)
+		if required := config_get_string_array(res_check_table, "required"); required != nil {
+			config.res_check_required = required
+		}
+		if forbidden := config_get_string_array(res_check_table, "forbidden"); forbidden != nil {
+			config.res_check_forbidden = forbidden
+		}
+		config.res_check_max_file_size_mib = config_get_int(res_check_table, "max_file_size_mib", default_res_max_file_size_mib)
+		if config.res_check_max_file_size_mib < 0 {
+			common.Log_fatal("config file", path, "has invalid \"res_check.max_file_size_mib\", expected a non-negative number")
+		}
+	}
+
+	var textures_table = config_get_table(root, "textures")
+	if textures_table != nil {
+		config.textures_enabled = true
+		var default_rule = config_texture_rule{formats: default_texture_formats}
+		default_rule = load_texture_rule(path, textures_table, default_rule)
+		config.texture_rules = []config_texture_rule{default_rule}
+		for _, rule_table := range config_get_table_array(textures_table, "rules") {
+			var rule = load_texture_rule(path, rule_table, default_rule)
+			rule.directory = clean_rule_directory(config_get_string(rule_table, "directory", ""))
+			if rule.directory == "" || rule.directory == "." {
+				common.Log_fatal("config file", path, "has \"textures.rules\" entry without \"directory\"")
+			}
+			config.texture_rules = append(config.texture_rules, rule)
+		}
+	}
+
+	var cook_textures_table = config_get_table(root, "cook_textures")
+	if cook_textures_table != nil {
+		config.cook_textures_enabled = true
+		config.cook_textures_tool = config_get_string(cook_textures_table, "tool", texture_tool_compressonator)
+		config.cook_textures_path = config_get_string(cook_textures_table, "path", "")
+		config.cook_textures_sha256 = config_get_string(cook_textures_table, "sha256", "")
+		config.cook_textures_format = config_get_string(cook_textures_table, "format", "BC7")
+		config.cook_textures_linear_format = config_get_string(cook_textures_table, "linear_format", config.cook_textures_format)
+		config.cook_textures_encode = config_get_string(cook_textures_table, "encode", "uastc")
+		config.cook_textures_mipmaps = config_get_bool(cook_textures_table, "mipmaps", true)
+		config.cook_textures_sources = config_get_string_array(cook_textures_table, "sources")
+		if config.cook_textures_sources == nil {
+			config.cook_textures_sources = default_cook_textures_sources
+		}
+		config.cook_textures_excludes = config_get_string_array(cook_textures_table, "exclude")
+		config.cook_textures_linear = config_get_string_array(cook_textures_table, "linear")
+		config.cook_textures_args = config_get_string_array(cook_textures_table, "args")
+		if config.cook_textures_tool != texture_tool_compressonator && config.cook_textures_tool != texture_tool_toktx {
+			common.Log_fatal("config file", path, "has unknown texture conversion tool", config.cook_textures_tool,
+				"expected \""+texture_tool_compressonator+"\" or \""+texture_tool_toktx+"\"")
+		}
+	}
+
+	var cook_audio_table = config_get_table(root, "cook_audio")
+	if cook_audio_table != nil {
+		config.cook_audio_enabled = true
+		config.cook_audio_path = config_get_string(cook_audio_table, "path", "")
+		config.cook_audio_format = config_get_string(cook_audio_table, "format", audio_format_opus)
+		config.cook_audio_true_peak = config_get_float(cook_audio_table, "true_peak", -1.5)
+		config.cook_audio_sources = config_get_string_array(cook_audio_table, "sources")
+		if config.cook_audio_sources == nil {
+			config.cook_audio_sources = default_cook_audio_sources
+		}
+		config.cook_audio_excludes = config_get_string_array(cook_audio_table, "exclude")
+		config.cook_audio_args = config_get_string_array(cook_audio_table, "args")
+		if config.cook_audio_format != audio_format_opus && config.cook_audio_format != audio_format_ogg {
+			common.Log_fatal("config file", path, "has unknown audio format", config.cook_audio_format,
+				"expected \""+audio_format_opus+"\" or \""+audio_format_ogg+"\"")
+		}
+		if config.cook_audio_true_peak < -9 || config.cook_audio_true_peak > 0 {
+			common.Log_fatal("config file", path, "has invalid \"cook_audio.true_peak\", expected a number from -9 to 0")
+		}
+
+		var default_rule = load_audio_rule(path, cook_audio_table, config_audio_rule{bitrate: 96, loudness: -16})
+		config.audio_rules = []config_audio_rule{default_rule}
+		for _, rule_table := range config_get_table_array(cook_audio_table, "rules") {
+			var rule = load_audio_rule(path, rule_table, default_rule)
+			rule.directory = clean_rule_directory(config_get_string(rule_table, "directory", ""))
+			if rule.directory == "" || rule.directory == "." {
+				common.Log_fatal("config file", path, "has \"cook_audio.rules\" entry without \"directory\"")
+			}
+			config.audio_rules = append(config.audio_rules, rule)
+		}
+	}
+
+	var smoke_test_table = config_get_table(root, "smoke_test")
+	if smoke_test_table != nil {
+		config.smoke_test_enabled = true
+		config.smoke_test_args = config_get_string_array(smoke_test_table, "args")
+		config.smoke_test_timeout = config_get_int(smoke_test_table, "timeout", 30)
+		if config.smoke_test_timeout <= 0 {
+			common.Log_fatal("config file", path, "has invalid \"smoke_test.timeout\", expected a positive number")
+		}
+	}
+
+	var graphics_debugging_table = config_get_table(root, "graphics_debugging")
+	if graphics_debugging_table != nil {
+		config.pix_library = config_get_string(graphics_debugging_table, "pix", "")
+		config.renderdoc_library = config_get_string(graphics_debugging_table, "renderdoc", "")
+	}
+
+	var build_info_table = config_get_table(root, "build_info")
+	if build_info_table != nil {
+		config.engine_version = config_get_string(build_info_table, "engine_version", "")
+	}
+
+	var executable_table = config_get_table(root, "executable")
+	if executable_table != nil {
+		config.executable_icon = config_get_string(executable_table, "icon", "")
+		config.executable_version = config_get_string(executable_table, "version", "")
+		config.executable_product_name = config_get_string(executable_table, "product_name", "")
+		config.executable_company_name = config_get_string(executable_table, "company_name", "")
+		config.executable_description = config_get_string(executable_table, "description", "")
+		config.executable_copyright = config_get_string(executable_table, "copyright", "")
+	}
+
+	var signing_table = config_get_table(root, "signing")
+	if signing_table != nil {
+		var default_tool = "osslsigncode"
+		if runtime.GOOS == "windows" {
+			default_tool = "signtool"
+		}
+
+		config.signing_enabled = true
+		config.signing_tool = config_get_string(signing_table, "tool", default_tool)
+		config.signing_certificate_env = config_get_string(signing_table, "certificate_env", "NE_SIGNING_CERTIFICATE")
+		config.signing_password_env = config_get_string(signing_table, "password_env", "NE_SIGNING_PASSWORD")
+		config.signing_timestamp_url = config_get_string(signing_table, "timestamp_url", "http://timestamp.digicert.com")
+		config.signing_files = config_get_string_array(signing_table, "files")
+		if config.signing_files == nil {
+			config.signing_files = []string{"*.exe", "*.dll"}
+		}
+		if config.signing_tool != "signtool" && config.signing_tool != "osslsigncode" {
+			common.Log_fatal("config file", path, "has unknown signing tool", config.signing_tool,
+				"expected \"signtool\" or \"osslsigncode\"")
+		}
+	}
+
+	var package_table = config_get_table(root, "package")
+	if package_table != nil {
+		config.package_enabled = config_get_bool(package_table, "enabled", true)
+		config.package_name = config_get_string(package_table, "name", "")
+		config.package_version = config_get_string(package_table, "version", "")
+		config.package_output_directory = config_get_string(package_table, "output_directory", "")
+		config.package_excludes = config_get_string_array(package_table, "exclude")
+		config.package_keeps = config_get_string_array(package_table, "keep")
+		for _, rule_table := range config_get_table_array(package_table, "rules") {
+			var excludes = config_get_string_array(rule_table, "exclude")
+			var keeps = config_get_string_array(rule_table, "keep")
+			if is_entry_enabled(config_get_string_array(rule_table, "platforms"), nil, true) {
+				config.package_excludes = append(config.package_excludes, excludes...)
+				config.package_keeps = append(config.package_keeps, keeps...)
+			}
+		}
+		config.package_checksums = config_get_bool(package_table, "checksums", true)
+		config.package_checksum_files = config_get_bool(package_table, "checksum_files", false)
+		config.package_sbom = config_get_bool(package_table, "sbom", true)
+		config.package_verify_launch = config_get_bool(package_table, "verify_launch", false)
+		if config.package_verify_launch && !config.smoke_test_enabled {
+			common.Log_fatal("config file", path, "has \"package.verify_launch\" enabled but no \"smoke_test\" section")
+		}
+		if formats := config_get_string_array(package_table, "formats"); formats != nil {
+			config.package_formats = formats
+		}
+
+		var installer_table = config_get_table(package_table, "installer")
+		if installer_table != nil {
+			config.installer_enabled = true
+			config.installer_publisher = config_get_string(installer_table, "publisher", "")
+			config.installer_license = config_get_string(installer_table, "license", "")
+			config.installer_desktop_shortcut = config_get_bool(installer_table, "desktop_shortcut", false)
+			config.installer_template = config_get_string(installer_table, "template", "")
+		}
+
+		var linux_desktop_table = config_get_table(package_table, "linux_desktop")
+		if linux_desktop_table != nil {
+			config.linux_desktop_enabled = true
+			config.linux_desktop_id = config_get_string(linux_desktop_table, "id", "")
+			config.linux_desktop_display_name = config_get_string(linux_desktop_table, "display_name", "")
+			config.linux_desktop_comment = config_get_string(linux_desktop_table, "comment", "")
+			config.linux_desktop_categories = config_get_string_array(linux_desktop_table, "categories")
+			config.linux_desktop_icons = config_get_string_array(linux_desktop_table, "icons")
+			config.linux_desktop_template = config_get_string(linux_desktop_table, "template", "")
+			if config.linux_desktop_categories == nil {
+				config.linux_desktop_categories = []string{"Game"}
+			}
+		}
+
+		var macos_bundle_table = config_get_table(package_table, "macos_bundle")
+		if macos_bundle_table != nil {
+			config.macos_bundle_enabled = true
+			config.macos_bundle_id = config_get_string(macos_bundle_table, "bundle_id", "")
+			config.macos_bundle_display_name = config_get_string(macos_bundle_table, "display_name", "")
+			config.macos_bundle_icon = config_get_string(macos_bundle_table, "icon", "")
+			config.macos_bundle_minimum_system_version = config_get_string(macos_bundle_table, "minimum_system_version", "11.0")
+			config.macos_bundle_info_plist = config_get_string(macos_bundle_table, "info_plist", "")
+			config.macos_bundle_signing_identity_env = config_get_string(macos_bundle_table, "signing_identity_env",
+				"NE_MACOS_SIGNING_IDENTITY")
+			config.macos_bundle_entitlements = config_get_string(macos_bundle_table, "entitlements", "")
+			config.macos_bundle_notarize = config_get_bool(macos_bundle_table, "notarize", false)
+			if config.macos_bundle_id == "" {
+				common.Log_fatal("config file", path, "has \"package.macos_bundle\" section without \"bundle_id\"")
+			}
+		}
+
+		var steam_depot_table = config_get_table(package_table, "steam_depot")
+		if steam_depot_table != nil {
+			config.steam_depot_enabled = true
+			config.steam_depot_app_id = config_get_int(steam_depot_table, "app_id", 0)
+			config.steam_depot_id = config_get_int(steam_depot_table, "depot_id", 0)
+			config.steam_depot_branch = config_get_string(steam_depot_table, "branch", "")
+			config.steam_depot_description = config_get_string(steam_depot_table, "description", "")
+			config.steam_depot_upload = config_get_bool(steam_depot_table, "upload", false)
+			config.steam_depot_steamcmd = config_get_string(steam_depot_table, "steamcmd", "steamcmd")
+			if config.steam_depot_id <= 0 || config.get_steam_depot_app_id() <= 0 {
+				common.Log_fatal("config file", path, "has \"package.steam_depot\" section without \"depot_id\" or app ID",
+					"(\"app_id\" or \"steam.app_id\")")
+			}
+			if config.steam_depot_branch == "default" {
+				common.Log_fatal("config file", path, "has \"package.steam_depot.branch\" set to \"default\"",
+					"but Steam does not allow to set builds live on the default branch automatically")
+			}
+		}
+
+		for _, format := range config.package_formats {
+			if format != package_format_zip && format != package_format_tar_gz && format != package_format_tar_zst {
+				common.Log_fatal("config file", path, "has unknown package format", "\""+format+"\"",
+					"expected \"zip\", \"tar.gz\" or \"tar.zst\"")
+			}
+		}
+	}
+
+	var agility_table = config_get_table(root, "agility_sdk")
+	if agility_table != nil {
+		config.agility_sdk_version = config_get_string(agility_table, "version", "")
+		config.agility_sdk_sha256 = config_get_string(agility_table, "sha256", "")
+		config.agility_sdk_arch = config_get_string(agility_table, "arch", "x64")
+		if config.agility_sdk_version == "" {
+			common.Log_fatal("config file", path, "has \"agility_sdk\" section without \"version\"")
+		}
+	}
+
+	warn_unknown_config_keys("config file", path, root, "")
+
+	return config
+}
+
+// Returns absolute path for a path from the config.
+func (config *post_build_config) resolve_path(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(config.directory, path)
+}
+
+// Reads texture settings from the table, settings that are not specified are taken from the default rule.
+func load_texture_rule(path string, table map[string]interface{}, default_rule config_texture_rule) config_texture_rule {
+	var rule = config_texture_rule{
+		power_of_two: config_get_bool(table, "power_of_two", default_rule.power_of_two),
+		max_size:     config_get_int(table, "max_size", default_rule.max_size),
+		colorspace:   config_get_string(table, "colorspace", default_rule.colorspace),
+	}
+
+	var formats = config_get_string_array(table, "formats")
+	if formats == nil {
+		formats = default_rule.formats
+	}
+	for _, format := range formats {
+		rule.formats = append(rule.formats, get_texture_format("."+format))
+		if !contains_string(texture_extensions, get_texture_format("."+format)) {
+			common.Log_fatal("config file", path, "has unknown texture format", "\""+format+"\"",
+				"expected one of:", strings.Join(texture_extensions, ", "))
+		}
+	}
+	if rule.max_size < 0 {
+		common.Log_fatal("config file", path, "has invalid texture \"max_size\", expected a non-negative number")
+	}
+	if rule.colorspace != "" && rule.colorspace != texture_colorspace_srgb && rule.colorspace != texture_colorspace_linear {
+		common.Log_fatal("config file", path, "has unknown texture colorspace", "\""+rule.colorspace+"\"",
+			"expected \""+texture_colorspace_srgb+"\" or \""+texture_colorspace_linear+"\"")
+	}
+
+	return rule
+}
+
+func load_audio_rule(path string, table map[string]interface{}, default_rule config_audio_rule) config_audio_rule {
+	var rule = config_audio_rule{
+		bitrate:  config_get_int(table, "bitrate", default_rule.bitrate),
+		loudness: config_get_float(table, "loudness", default_rule.loudness),
+	}
+
+	if rule.bitrate <= 0 {
+		common.Log_fatal("config file", path, "has invalid audio \"bitrate\", expected a positive number")
+	}
+	if rule.loudness != 0 && (rule.loudness < -70 || rule.loudness > -5) {
+		common.Log_fatal("config file", path, "has invalid audio \"loudness\", expected a number from -70 to -5 (or 0 to disable)")
+	}
+
+	return rule
+}
+
+// Tells if an entry with the specified platform/build mode filters should be used in the current build.
+func is_entry_enabled(platforms []string, build_modes []string, is_release bool) bool {
+	if len(platforms) != 0 && !contains_string(platforms, runtime.GOOS) {
+		return false
+	}
+
+	var build_mode = "debug"
+	if is_release {
+		build_mode = "release"
+	}
+	if len(build_modes) != 0 && !contains_string(build_modes, build_mode) {
+		return false
+	}
+
+	return true
+}
+
+func contains_string(values []string, value string) bool {
+	for _, item := range values {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// Config keys that were read by "config_get_*" functions (table pointer and key).
+type config_used_key struct {
+	table uintptr
+	key   string
+}
+
+var config_used_keys = map[config_used_key]bool{}
+
+// Returns the value of the key and remembers that the key is known (see "warn_unknown_config_keys").
+func config_lookup(table map[string]interface{}, key string) (interface{}, bool) {
+	config_used_keys[config_used_key{table: reflect.ValueOf(table).Pointer(), key: key}] = true
+	var value, exists = table[key]
+	return value, exists
+}
+
+// Logs a warning for each key of the table (and nested tables) that was not read by
+// "config_get_*" functions (most likely a misspelled key or a key of a different version).
+func warn_unknown_config_keys(file_kind string, path string, table map[string]interface{}, prefix string) {
+	var keys []string
+	for key := range table {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if !config_used_keys[config_used_key{table: reflect.ValueOf(table).Pointer(), key: key}] {
+			common.Log_warning(file_kind, path, "has unknown key", "\""+prefix+key+"\"", "(ignored)")
+			continue
+		}
+
+		switch value := table[key].(type) {
+		case map[string]interface{}:
+			warn_unknown_config_keys(file_kind, path, value, prefix+key+".")
+		case []interface{}:
+			for _, item := range value {
+				if item_table, ok := item.(map[string]interface{}); ok {
+					warn_unknown_config_keys(file_kind, path, item_table, prefix+key+".")
+				}
+			}
+		}
+	}
+}
+
+func config_get_string(table map[string]interface{}, key string, default_value string) string {
+	var value, exists = config_lookup(table, key)
+	if !exists {
+		return default_value
+	}
+
+	text, ok := value.(string)
+	if !ok {
+		common.Log_fatal("expected config key", key, "to be a string")
+	}
+
+	return text
+}
+
+func config_get_bool(table map[string]interface{}, key string, default_value bool) bool {
+	var value, exists = config_lookup(table, key)
+	if !exists {
+		return default_value
+	}
+
+	result, ok := value.(bool)
+	if !ok {
+		common.Log_fatal("expected config key", key, "to be a boolean")
+	}
+
+	return result
+}
+
+func config_get_int(table map[string]interface{}, key string, default_value int64) int64 {
+	var value, exists = config_lookup(table, key)
+	if !exists {
+		return default_value
+	}
+
+	result, ok := value.(int64)
+	if !ok {
+		common.Log_fatal("expected config key", key, "to be an integer")
+	}
+
+	return result
+}
+
+// Returns a float, integers are converted to floats.
+func config_get_float(table map[string]interface{}, key string, default_value float64) float64 {
+	var value, exists = config_lookup(table, key)
+	if !exists {
+		return default_value
+	}
+
+	switch result := value.(type) {
+	case float64:
+		return result
+	case int64:
+		return float64(result)
+	}
+	common.Log_fatal("expected config key", key, "to be a number")
+	return 0
+}
+
+func config_get_string_array(table map[string]interface{}, key string) []string {
+	var value, exists = config_lookup(table, key)
+	if !exists {
+		return nil
+	}
+
+	array, ok := value.([]interface{})
+	if !ok {
+		common.Log_fatal("expected config key", key, "to be an array of strings")
+	}
+
+	var result []string
+	for _, item := range array {
+		text, ok := item.(string)
+		if !ok {
+			common.Log_fatal("expected config key", key, "to be an array of strings")
+		}
+		result = append(result, text)
+	}
+
+	return result
+}
+
+// Returns a table or nil if the key does not exist.
+func config_get_table(table map[string]interface{}, key string) map[string]interface{} {
+	var value, exists = config_lookup(table, key)
+	if !exists {
+		return nil
+	}
+
+	result, ok := value.(map[string]interface{})
+	if !ok {
+		common.Log_fatal("expected config key", key, "to be a table")
+	}
+
+	return result
+}
+
+func config_get_table_array(table map[string]interface{}, key string) []map[string]interface{} {
+	var value, exists = config_lookup(table, key)
+	if !exists {
+		return nil
+	}
+
+	array, ok := value.([]interface{})
+	if !ok {
+		common.Log_fatal("expected config key", key, "to be an array of tables")
+	}
+
+	var result []map[string]interface{}
+	for _, item := range array {
+		item_table, ok := item.(map[string]interface{})
+		if !ok {
+			common.Log_fatal("expected config key", key, "to be an array of tables")
+		}
+		result = append(result, item_table)
+	}
+
+	return result
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"common"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Files of the 'res' directory that a cooking step replaces with cooked files in the build directory.
+type res_cook_rule struct {
+	sources   []string // globs relative to the 'res' directory
+	excludes  []string // globs relative to the 'res' directory
+	extension string   // extension of cooked files
+}
+
+// Source file of the 'res' directory and the path to its cooked file in the build directory.
+type res_cook_file struct {
+	source string
+	target string
+}
+
+// Returns rules of cooking steps that will be executed.
+func get_res_cook_rules(config *post_build_config, enabled_steps map[string]bool, is_release bool) []res_cook_rule {
+	var rules []res_cook_rule
+	if !is_release {
+		return rules
+	}
+
+	if enabled_steps[step_cook_textures] && config.cook_textures_enabled {
+		rules = append(rules, get_texture_cook_rule(config))
+	}
+	if enabled_steps[step_cook_audio] && config.cook_audio_enabled {
+		rules = append(rules, get_audio_cook_rule(config))
+	}
+
+	return rules
+}
+
+// Returns true if the file (path relative to the 'res' directory with '/' separators) is cooked by the rule.
+// Files that already have the extension of cooked files are not cooked.
+func (rule res_cook_rule) is_source(relative_path string) bool {
+	if strings.EqualFold(path.Ext(relative_path), rule.extension) {
+		return false
+	}
+	return matches_res_pattern(relative_path, rule.sources) && !matches_res_pattern(relative_path, rule.excludes)
+}
+
+// Returns true if the file (path relative to the 'res' directory with '/' separators) is cooked
+// by some rule.
+func is_cooked_source(rules []res_cook_rule, relative_path string) bool {
+	for _, rule := range rules {
+		if rule.is_source(relative_path) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns true if the file (path relative to the 'res' directory with '/' separators) is a cooked file
+// of some source file that exists in the 'res' directory.
+func is_cooked_file(rules []res_cook_rule, res_directory string, relative_path string) bool {
+	var directory = path.Dir(relative_path)
+	var name = strings.TrimSuffix(path.Base(relative_path), path.Ext(relative_path))
+
+	entries, err := os.ReadDir(filepath.Join(res_directory, filepath.FromSlash(directory)))
+	if err != nil {
+		return false
+	}
+
+	for _, rule := range rules {
+		if !strings.EqualFold(path.Ext(relative_path), rule.extension) {
+			continue
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name())) != name {
+				continue
+			}
+			if rule.is_source(path.Join(directory, entry.Name())) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Returns source files in the 'res' directory that are cooked by the rule and paths to their cooked files
+// in the 'res' directory of the build directory. Returns false if 'res' in the build directory is a link
+// to the source directory (cooked files would be written next to the sources).
+func find_files_to_cook(res_directory string, build_directory string, rule res_cook_rule) ([]res_cook_file, bool) {
+	var target_directory = filepath.Join(build_directory, "res")
+	if is_link(target_directory) {
+		report_warning("'res' directory in " + build_directory + " is a link to the source directory, " +
+			"files are not cooked (use \"--copy-res\")")
+		report_step_status(step_status_skipped)
+		return nil, false
+	}
+
+	var files []res_cook_file
+	var sources_by_target = map[string]string{}
+	var err = filepath.Walk(res_directory, func(file_path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		relative_path, err := filepath.Rel(res_directory, file_path)
+		if err != nil {
+			return err
+		}
+		if !rule.is_source(filepath.ToSlash(relative_path)) {
+			return nil
+		}
+
+		var target_path = strings.TrimSuffix(relative_path, filepath.Ext(relative_path)) + rule.extension
+		if other, ok := sources_by_target[strings.ToLower(target_path)]; ok {
+			common.Log_fatal("files", other, "and", file_path, "would be cooked to the same file", target_path)
+		}
+		if _, err := os.Stat(filepath.Join(res_directory, target_path)); err == nil {
+			common.Log_fatal("file", file_path, "would be cooked to", target_path, "that already exists in", res_directory)
+		}
+		sources_by_target[strings.ToLower(target_path)] = file_path
+
+		files = append(files, res_cook_file{source: file_path, target: filepath.Join(target_directory, target_path)})
+		return nil
+	})
+	if err != nil {
+		common.Log_fatal("failed to read 'res' directory", res_directory, "error:", err)
+	}
+
+	return files, true
+}
+
+// Writes the cooked file to "file.target". The cooked file is taken from the cache (the key should include
+// the hash of the source and cooking settings) or created using "cook" (that receives the path to write
+// the cooked file to). Returns true if the cooked file was cached.
+func cook_file(file res_cook_file, cache_name string, key string, cook func(target string)) bool {
+	var extension = filepath.Ext(file.target)
+	var cached_path = filepath.Join(get_cache_directory(), cache_name, key[:2], key+extension)
+
+	var is_cached = false
+	if _, err := os.Stat(cached_path); err == nil {
+		common.Log_verbose("using cached", cached_path, "for", file.source)
+		is_cached = true
+	} else if dry_run {
+		common.Log_info("[dry run] cook", file.source, "to", file.target)
+		return false
+	} else {
+		common.Log_info("cooking", file.source)
+		make_directory(filepath.Dir(cached_path))
+
+		// Write to a temporary file so that failed conversions are not cached.
+		var partial_path = strings.TrimSuffix(cached_path, extension) + ".part" + extension
+		cook(partial_path)
+		if _, err := os.Stat(partial_path); err != nil {
+			common.Log_fatal("cooked file", partial_path, "was not created")
+		}
+		err = os.Rename(partial_path, cached_path)
+		if err != nil {
+			common.Log_fatal("failed to rename", partial_path, "to", cached_path, "error:", err)
+		}
+	}
+
+	make_directory(filepath.Dir(file.target))
+	copy(cached_path, file.target)
+	return is_cached
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"common"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Formats that audio can be converted to.
+const (
+	audio_format_opus = "opus"
+	audio_format_ogg  = "ogg"
+)
+
+// Source audio files that are converted if not specified in the config.
+var default_cook_audio_sources = []string{"*.wav"}
+
+// Loudness range (in LU) used for loudness normalization (FFmpeg default).
+const audio_loudness_range = 11
+
+// Loudness of the source audio file measured by the first pass of FFmpeg "loudnorm" filter.
+type audio_loudness struct {
+	InputI       string `json:"input_i"`
+	InputTP      string `json:"input_tp"`
+	InputLRA     string `json:"input_lra"`
+	InputThresh  string `json:"input_thresh"`
+	TargetOffset string `json:"target_offset"`
+}
+
+// Converts source audio files of the 'res' directory to Ogg/Opus or Ogg/Vorbis (normalizing loudness
+// if configured) and writes them to the 'res' directory of the build directory (the 'res' step does not copy
+// source audio files there). Converted files are cached by hashes of their sources and conversion settings.
+func convert_audio(config *post_build_config, res_directory string, build_directory string) {
+	var cook_rule = get_audio_cook_rule(config)
+	files, ok := find_files_to_cook(res_directory, build_directory, cook_rule)
+	if !ok {
+		return
+	}
+	if len(files) == 0 {
+		common.Log_info("no audio files to convert in", res_directory)
+		return
+	}
+
+	var ffmpeg = find_ffmpeg(config)
+
+	var cached_count = 0
+	var source_size int64 = 0
+	var converted_size int64 = 0
+	for _, file := range files {
+		var relative_path, _ = filepath.Rel(res_directory, file.source)
+		var rule = find_audio_rule(config.audio_rules, filepath.ToSlash(relative_path))
+		if info, err := os.Stat(file.source); err == nil {
+			source_size += info.Size()
+		}
+
+		var key = get_cooked_audio_key(config, file.source, rule)
+		var is_cached = cook_file(file, "audio", key, func(target string) {
+			run_ffmpeg(config, ffmpeg, file.source, target, rule)
+		})
+		if is_cached {
+			cached_count += 1
+		}
+
+		if info, err := os.Stat(file.target); err == nil {
+			converted_size += info.Size()
+		}
+	}
+
+	common.Log_success("converted", len(files), "audio file(s) to", cook_rule.extension, fmt.Sprintf("(%d from cache)", cached_count))
+	if !dry_run {
+		common.Log_info("audio size:", common.Format_byte_count(source_size), "->", common.Format_byte_count(converted_size))
+	}
+}
+
+// Returns audio files that are converted and the extension of converted files.
+func get_audio_cook_rule(config *post_build_config) res_cook_rule {
+	return res_cook_rule{sources: config.cook_audio_sources, excludes: config.cook_audio_excludes, extension: "." + config.cook_audio_format}
+}
+
+// Returns the rule with the longest directory that contains the file (path relative to the 'res' directory),
+// the first rule (with default settings) if there is no such rule.
+func find_audio_rule(rules []config_audio_rule, relative_path string) config_audio_rule {
+	var result = rules[0]
+	for _, rule := range rules[1:] {
+		if strings.HasPrefix(relative_path, rule.directory+"/") && len(rule.directory) >= len(result.directory) {
+			result = rule
+		}
+	}
+	return result
+}
+
+// Returns SHA-256 of the source audio file combined with conversion settings.
+func get_cooked_audio_key(config *post_build_config, source string, rule config_audio_rule) string {
+	var hasher = sha256.New()
+	fmt.Fprintln(hasher, get_file_sha256(source))
+	fmt.Fprintln(hasher, config.cook_audio_path, config.cook_audio_format, config.cook_audio_true_peak)
+	fmt.Fprintln(hasher, rule.bitrate, rule.loudness)
+	fmt.Fprintln(hasher, strings.Join(config.cook_audio_args, "\x00"))
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
+// Returns path to FFmpeg from the config or PATH.
+func find_ffmpeg(config *post_build_config) string {
+	if config.cook_audio_path != "" {
+		return config.resolve_path(config.cook_audio_path)
+	}
+
+	path, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		common.Log_fatal("\"ffmpeg\" was not found in PATH (install FFmpeg or set \"cook_audio.path\")")
+	}
+	return path
+}
+
+// Converts the source audio file using FFmpeg, loudness is normalized in two passes (measure, then apply
+// the measured values) because single-pass normalization changes the gain dynamically.
+func run_ffmpeg(config *post_build_config, ffmpeg string, source string, target string, rule config_audio_rule) {
+	var args = []string{"-hide_banner", "-nostdin", "-y", "-i", source, "-vn", "-map_metadata", "-1"}
+
+	if rule.loudness != 0 {
+		if loudness, ok := measure_loudness(config, ffmpeg, source, rule); ok {
+			var filter = get_loudnorm_filter(config, rule) + fmt.Sprintf(
+				":measured_I=%s:measured_TP=%s:measured_LRA=%s:measured_thresh=%s:offset=%s:linear=true",
+				loudness.InputI, loudness.InputTP, loudness.InputLRA, loudness.InputThresh, loudness.TargetOffset)
+			args = append(args, "-af", filter)
+		}
+	}
+
+	var codec = "libopus"
+	if config.cook_audio_format == audio_format_ogg {
+		codec = "libvorbis"
+	}
+	// "loudnorm" upsamples to 192 kHz and Opus supports only 48 kHz.
+	args = append(args, "-c:a", codec, "-b:a", fmt.Sprintf("%dk", rule.bitrate), "-ar", "48000")
+	args = append(args, config.cook_audio_args...)
+	args = append(args, target)
+
+	common.Log_verbose("running:", ffmpeg, strings.Join(args, " "))
+	output, err := exec.Command(ffmpeg, args...).CombinedOutput()
+	if err != nil {
+		common.Log_fatal("failed to convert", source, "error:", err, "output:", strings.TrimSpace(string(output)))
+	}
+}
+
+// Runs the first pass of FFmpeg "loudnorm" filter to measure loudness of the source audio file.
+// Returns false if the file is silent (its loudness can't be normalized).
+func measure_loudness(config *post_build_config, ffmpeg string, source string, rule config_audio_rule) (audio_loudness, bool) {
+	var args = []string{"-hide_banner", "-nostdin", "-i", source, "-vn",
+		"-af", get_loudnorm_filter(config, rule) + ":print_format=json", "-f", "null", "-"}
+
+	common.Log_verbose("running:", ffmpeg, strings.Join(args, " "))
+	output, err := exec.Command(ffmpeg, args...).CombinedOutput()
+	if err != nil {
+		common.Log_fatal("failed to measure loudness of", source, "error:", err, "output:", strings.TrimSpace(string(output)))
+	}
+
+	// Measured values are printed as the last JSON object in the output.
+	var text = string(output)
+	var begin = strings.LastIndex(text, "{")
+	var end = strings.LastIndex(text, "}")
+	var loudness audio_loudness
+	if begin == -1 || end < begin {
+		common.Log_fatal("failed to find measured loudness of", source, "in FFmpeg output:", strings.TrimSpace(text))
+	}
+	err = json.Unmarshal([]byte(text[begin:end+1]), &loudness)
+	if err != nil || loudness.InputI == "" {
+		common.Log_fatal("failed to parse measured loudness of", source, "error:", err, "output:", text[begin:end+1])
+	}
+
+	// Silent files have infinite loudness.
+	if strings.Contains(loudness.InputI, "inf") {
+		report_warning("audio file " + source + " is silent, its loudness is not normalized")
+		return loudness, false
+	}
+
+	return loudness, true
+}
+
+// Returns FFmpeg "loudnorm" filter with target loudness.
+func get_loudnorm_filter(config *post_build_config, rule config_audio_rule) string {
+	return fmt.Sprintf("loudnorm=I=%g:TP=%g:LRA=%d", rule.loudness, config.cook_audio_true_peak, audio_loudness_range)
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"common"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Tools that can be used to convert textures.
+const (
+	texture_tool_compressonator = "compressonator"
+	texture_tool_toktx          = "toktx"
+)
+
+// Compressonator CLI that is downloaded if no path is specified in the config.
+const compressonator_version = "4.5.52"
+const compressonator_base_url = "https://github.com/GPUOpen-Tools/compressonator/releases/download/V" + compressonator_version + "/"
+
+// Source textures that are converted if not specified in the config.
+var default_cook_textures_sources = []string{"*.png", "*.tga"}
+
+// Converts source textures of the 'res' directory to GPU-ready formats (DDS using Compressonator or KTX2
+// using toktx) and writes them to the 'res' directory of the build directory (the 'res' step does not copy
+// source textures there). Converted textures are cached by hashes of their sources and conversion settings.
+func convert_textures(config *post_build_config, res_directory string, build_directory string) {
+	var rule = get_texture_cook_rule(config)
+	files, ok := find_files_to_cook(res_directory, build_directory, rule)
+	if !ok {
+		return
+	}
+	if len(files) == 0 {
+		common.Log_info("no textures to convert in", res_directory)
+		return
+	}
+
+	var tool = find_texture_tool(config)
+
+	var cached_count = 0
+	for _, file := range files {
+		var relative_path, _ = filepath.Rel(res_directory, file.source)
+		var is_linear = matches_res_pattern(filepath.ToSlash(relative_path), config.cook_textures_linear)
+		var key = get_cooked_texture_key(config, file.source, is_linear)
+		var is_cached = cook_file(file, "textures", key, func(target string) {
+			run_texture_tool(config, tool, file.source, target, is_linear)
+		})
+		if is_cached {
+			cached_count += 1
+		}
+	}
+
+	common.Log_success("converted", len(files), "texture(s) to", rule.extension, fmt.Sprintf("(%d from cache)", cached_count))
+}
+
+// Returns textures that are converted and the extension of converted textures.
+func get_texture_cook_rule(config *post_build_config) res_cook_rule {
+	var extension = ".dds"
+	if config.cook_textures_tool == texture_tool_toktx {
+		extension = ".ktx2"
+	}
+	return res_cook_rule{sources: config.cook_textures_sources, excludes: config.cook_textures_excludes, extension: extension}
+}
+
+// Returns SHA-256 of the source texture combined with conversion settings.
+func get_cooked_texture_key(config *post_build_config, source string, is_linear bool) string {
+	var hasher = sha256.New()
+	fmt.Fprintln(hasher, get_file_sha256(source))
+	fmt.Fprintln(hasher, config.cook_textures_tool, config.cook_textures_path, compressonator_version)
+	fmt.Fprintln(hasher, config.cook_textures_format, config.cook_textures_linear_format, config.cook_textures_encode, config.cook_textures_mipmaps, is_linear)
+	fmt.Fprintln(hasher, strings.Join(config.cook_textures_args, "\x00"))
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
+// Runs the conversion tool to convert the source texture.
+func run_texture_tool(config *post_build_config, tool string, source string, target string, is_linear bool) {
+	var args []string
+	if config.cook_textures_tool == texture_tool_toktx {
+		args = []string{"--t2", "--encode", config.cook_textures_encode}
+		if config.cook_textures_mipmaps {
+			args = append(args, "--genmipmap")
+		}
+		if is_linear {
+			args = append(args, "--assign_oetf", "linear")
+		} else {
+			args = append(args, "--assign_oetf", "srgb")
+		}
+		args = append(append(args, config.cook_textures_args...), target, source)
+	} else {
+		var format = config.cook_textures_format
+		if is_linear {
+			format = config.cook_textures_linear_format
+		}
+		args = []string{"-fd", format}
+		if !config.cook_textures_mipmaps {
+			args = append(args, "-nomipmap")
+		}
+		args = append(append(args, config.cook_textures_args...), source, target)
+	}
+
+	common.Log_verbose(tool, strings.Join(args, " "))
+	output, err := exec.Command(tool, args...).CombinedOutput()
+	if err != nil {
+		common.Log_fatal("failed to convert", source, "error:", err, "output:", strings.TrimSpace(string(output)))
+	}
+}
+
+// Returns path to the conversion tool, downloads Compressonator if no path is specified.
+func find_texture_tool(config *post_build_config) string {
+	if config.cook_textures_path != "" {
+		return config.resolve_path(config.cook_textures_path)
+	}
+
+	if config.cook_textures_tool == texture_tool_toktx {
+		path, err := exec.LookPath("toktx")
+		if err != nil {
+			common.Log_fatal("\"toktx\" was not found in PATH (install KTX-Software or set \"cook_textures.path\")")
+		}
+		return path
+	}
+
+	var archive_name string
+	switch runtime.GOOS {
+	case "windows":
+		archive_name = "compressonatorcli-" + compressonator_version + "-win64.zip"
+	case "linux":
+		archive_name = "compressonatorcli-" + compressonator_version + "-Linux.tar.gz"
+	default:
+		common.Log_fatal("there are no Compressonator builds for", runtime.GOOS, "(set \"cook_textures.path\")")
+	}
+
+	var cache_key = filepath.Join("compressonator", compressonator_version)
+	var archive_path = download_cached(compressonator_base_url+archive_name, archive_name, cache_key,
+		config.cook_textures_sha256)
+
+	var tool_directory = filepath.Join(get_cache_directory(), cache_key, "cli")
+	if _, err := os.Stat(tool_directory); os.IsNotExist(err) && !dry_run {
+		// Extract to a temporary directory so that interrupted extractions are not used.
+		var partial_directory = tool_directory + ".part"
+		remove_all(partial_directory)
+		extract_archive(archive_path, partial_directory)
+		err = os.Rename(partial_directory, tool_directory)
+		if err != nil {
+			common.Log_fatal("failed to rename", partial_directory, "to", tool_directory, "error:", err)
+		}
+	}
+
+	var executable_name = "compressonatorcli"
+	if runtime.GOOS == "windows" {
+		executable_name += ".exe"
+	}
+	var tool = ""
+	filepath.Walk(tool_directory, func(file_path string, info os.FileInfo, err error) error {
+		if err == nil && tool == "" && !info.IsDir() && info.Name() == executable_name {
+			tool = file_path
+		}
+		return nil
+	})
+	if tool == "" && !dry_run {
+		common.Log_fatal("archive", archive_path, "does not contain", executable_name)
+	}
+	return tool
+}
+
+// Extracts all files from the zip or tar.gz archive to the directory.
+func extract_archive(archive_path string, destination string) {
+	make_directory(destination)
+
+	// Make sure the archive does not write outside of the destination directory.
+	var get_target = func(name string) string {
+		var clean_name = path.Clean("/" + filepath.ToSlash(name))[1:]
+		if clean_name == "" {
+			common.Log_fatal("archive", archive_path, "has invalid entry", name)
+		}
+		var target = filepath.Join(destination, filepath.FromSlash(clean_name))
+		make_directory(filepath.Dir(target))
+		return target
+	}
+
+	var write_file = func(name string, mode os.FileMode, source io.Reader) {
+		var target = get_target(name)
+
+		file, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode|0600)
+		if err != nil {
+			common.Log_fatal("failed to create file", target, "error:", err)
+		}
+		defer file.Close()
+		_, err = io.Copy(file, source)
+		if err != nil {
+			common.Log_fatal("failed to extract", name, "from", archive_path, "error:", err)
+		}
+	}
+
+	if strings.HasSuffix(archive_path, ".zip") {
+		reader, err := zip.OpenReader(archive_path)
+		if err != nil {
+			common.Log_fatal("failed to open archive", archive_path, "error:", err)
+		}
+		defer reader.Close()
+
+		for _, file := range reader.File {
+			if file.FileInfo().IsDir() {
+				continue
+			}
+			source, err := file.Open()
+			if err != nil {
+				common.Log_fatal("failed to open", file.Name, "in archive", archive_path, "error:", err)
+			}
+			write_file(file.Name, file.Mode(), source)
+			source.Close()
+		}
+		return
+	}
+
+	file, err := os.Open(archive_path)
+	if err != nil {
+		common.Log_fatal("failed to open archive", archive_path, "error:", err)
+	}
+	defer file.Close()
+	gzip_reader, err := gzip.NewReader(file)
+	if err != nil {
+		common.Log_fatal("failed to read archive", archive_path, "error:", err)
+	}
+	var tar_reader = tar.NewReader(gzip_reader)
+	for {
+		header, err := tar_reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			common.Log_fatal("failed to read archive", archive_path, "error:", err)
+		}
+		switch header.Typeflag {
+		case tar.TypeReg:
+			write_file(header.Name, header.FileInfo().Mode(), tar_reader)
+		case tar.TypeSymlink:
+			// Shared libraries are usually linked to their versioned names.
+			if filepath.IsAbs(header.Linkname) || strings.Contains(header.Linkname, "..") {
+				common.Log_fatal("archive", archive_path, "has a link", header.Name, "that points outside of the archive")
+			}
+			err = os.Symlink(header.Linkname, get_target(header.Name))
+			if err != nil {
+				common.Log_fatal("failed to create symlink", header.Name, "error:", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bufio"
+	"common"
+	"debug/pe"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Libraries that are either part of Windows or installed by the redistributable packages.
+var windows_system_libraries = []string{
+	"kernel32.dll", "user32.dll", "gdi32.dll", "advapi32.dll", "shell32.dll", "ole32.dll", "oleaut32.dll",
+	"comdlg32.dll", "comctl32.dll", "shlwapi.dll", "ws2_32.dll", "winmm.dll", "imm32.dll", "version.dll",
+	"setupapi.dll", "dbghelp.dll", "bcrypt.dll", "crypt32.dll", "ntdll.dll", "rpcrt4.dll", "dwmapi.dll",
+	"uxtheme.dll", "hid.dll", "xinput1_4.dll", "opengl32.dll", "dinput8.dll", "d3d11.dll", "d3d12.dll",
+	"dxgi.dll", "d3dcompiler_47.dll", "propsys.dll", "shcore.dll", "userenv.dll", "secur32.dll",
+	// Installed by vc_redist.
+	"vcruntime140.dll", "vcruntime140_1.dll", "msvcp140.dll", "msvcp140_1.dll", "msvcp140_2.dll",
+	"concrt140.dll", "vcomp140.dll",
+}
+
+// Checks that all libraries the binary depends on either exist in its directory
+// or are system libraries and exits with an error otherwise.
+func check_binary_dependencies(config *post_build_config, binary_path string) {
+	if _, err := os.Stat(binary_path); os.IsNotExist(err) {
+		common.Log_fatal("binary", binary_path, "does not exist")
+	}
+
+	var missing []string
+	if runtime.GOOS == "windows" {
+		missing = find_missing_pe_dependencies(config, binary_path)
+	} else {
+		missing = find_missing_ldd_dependencies(config, binary_path)
+	}
+
+	if len(missing) != 0 {
+		for _, library := range missing {
+			common.Log_error("library", library, "required by", binary_path, "was not found")
+		}
+		common.Log_fatal(len(missing), "required library(-ies) are missing, make sure they are copied to the build directory "+
+			"(or add them to \"deps.allow\" in the config if they are expected to exist on user machines)")
+	}
+
+	common.Log_success("all libraries required by", filepath.Base(binary_path), "were found")
+}
+
+// Tells if the library is allowed to be missing from the build directory.
+func is_allowed_missing_library(config *post_build_config, name string) bool {
+	var lower_name = strings.ToLower(name)
+	if strings.HasPrefix(lower_name, "api-ms-win-") || strings.HasPrefix(lower_name, "ext-ms-") {
+		return true
+	}
+	return contains_string(config.deps_allow, lower_name)
+}
+
+// Looks at the PE imports of the binary (and of libraries from its directory it depends on).
+func find_missing_pe_dependencies(config *post_build_config, binary_path string) []string {
+	var binary_directory = filepath.Dir(binary_path)
+	var system_directory = filepath.Join(os.Getenv("SystemRoot"), "System32")
+
+	var missing []string
+	var visited = map[string]bool{}
+	var to_check = []string{binary_path}
+
+	for len(to_check) != 0 {
+		var path = to_check[0]
+		to_check = to_check[1:]
+
+		file, err := pe.Open(path)
+		if err != nil {
+			common.Log_fatal("failed to read", path, "error:", err)
+		}
+		imports, err := file.ImportedLibraries()
+		file.Close()
+		if err != nil {
+			common.Log_fatal("failed to read imports of", path, "error:", err)
+		}
+
+		for _, library := range imports {
+			var key = strings.ToLower(library)
+			if visited[key] {
+				continue
+			}
+			visited[key] = true
+
+			var local_path = filepath.Join(binary_directory, library)
+			if _, err := os.Stat(local_path); err == nil {
+				common.Log_verbose("found", library, "in the build directory")
+				to_check = append(to_check, local_path)
+				continue
+			}
+
+			// Import names are case-insensitive on Windows.
+			if contains_string(windows_system_libraries, strings.ToLower(library)) || is_allowed_missing_library(config, library) {
+				continue
+			}
+
+			if _, err := os.Stat(filepath.Join(system_directory, library)); err == nil {
+				common.Log_verbose(library, "is a system library")
+				continue
+			}
+
+			missing = append(missing, library)
+		}
+	}
+
+	return missing
+}
+
+// Uses `ldd` to find libraries that can't be resolved.
+func find_missing_ldd_dependencies(config *post_build_config, binary_path string) []string {
+	var command = exec.Command("ldd", binary_path)
+	// Libraries next to the binary are found through its rpath or LD_LIBRARY_PATH.
+	command.Env = append(os.Environ(), "LD_LIBRARY_PATH="+filepath.Dir(binary_path)+
+		string(os.PathListSeparator)+os.Getenv("LD_LIBRARY_PATH"))
+
+	output, err := command.Output()
+	if err != nil {
+		common.Log_fatal("failed to run ldd on", binary_path, "error:", err)
+	}
+
+	var missing []string
+	var scanner = bufio.NewScanner(strings.NewReader(string(output)))
+	for scanner.Scan() {
+		var line = strings.TrimSpace(scanner.Text())
+		if !strings.HasSuffix(line, "not found") {
+			continue
+		}
+
+		var library = strings.TrimSpace(strings.SplitN(line, "=>", 2)[0])
+		if is_allowed_missing_library(config, library) {
+			continue
+		}
+		missing = append(missing, library)
+	}
+
+	return missing
+}
+
+// Tells if the PE binary imports DLLs of the Visual C++ runtime.
+func is_using_dynamic_crt(binary_path string) bool {
+	file, err := pe.Open(binary_path)
+	if err != nil {
+		common.Log_fatal("failed to read", binary_path, "error:", err)
+	}
+	defer file.Close()
+
+	imports, err := file.ImportedLibraries()
+	if err != nil {
+		common.Log_fatal("failed to read imports of", binary_path, "error:", err)
+	}
+
+	for _, library := range imports {
+		var name = strings.ToLower(library)
+		if strings.HasPrefix(name, "vcruntime") || strings.HasPrefix(name, "msvcp") {
+			return true
+		}
+	}
+
+	return false
+}
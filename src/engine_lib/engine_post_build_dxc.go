@@ -0,0 +1,56 @@
+package main
+
+import (
+	"common"
+	"os"
+	"path/filepath"
+)
+
+// DXC libraries that are loaded by the engine at runtime (Windows only).
+var dxc_library_names = []string{"dxcompiler.dll", "dxil.dll"}
+
+// Copies DXC libraries (downloaded to the 'ext' directory, see "ext/DirectXShaderCompiler/download_dxc.go")
+// for the architecture of the build target to the specified directories.
+func copy_dxc_libs(ext_directory string, target_directories []string, stamps *post_build_stamps, stamp_name string) {
+	var dxc_directory = filepath.Join(ext_directory, "DirectXShaderCompiler", "bin", target_arch)
+
+	var copies []file_copy
+	var processed_directories = map[string]bool{}
+	for _, target_directory := range target_directories {
+		// Directories may be equal (for example when working and build directories are the same).
+		var destination_directory = filepath.Clean(target_directory)
+		if processed_directories[destination_directory] {
+			continue
+		}
+		processed_directories[destination_directory] = true
+
+		for _, library_name := range dxc_library_names {
+			copies = append(copies, file_copy{
+				src: filepath.Join(dxc_directory, library_name),
+				dst: filepath.Join(destination_directory, library_name),
+			})
+		}
+	}
+
+	var inputs = fingerprint_files(get_copy_sources(copies), get_copy_destinations(copies)...)
+	var outputs = get_copy_destinations(copies)
+	if stamps.is_up_to_date(stamp_name, inputs, outputs) {
+		common.Log_info("DXC libraries are up to date")
+		report_step_status(step_status_up_to_date)
+		return
+	}
+
+	for _, library_name := range dxc_library_names {
+		var path = filepath.Join(dxc_directory, library_name)
+		if _, err := os.Stat(path); err != nil {
+			common.Log_fatal("expected file", path, "does not exist (DXC is downloaded before engine_lib is built)")
+		}
+	}
+
+	check_binaries_arch(get_copy_sources(copies))
+	copy_files(copies)
+
+	stamps.update(stamp_name, inputs, outputs)
+
+	common.Log_success("copied DXC libraries to", len(processed_directories), "directory(-ies)")
+}
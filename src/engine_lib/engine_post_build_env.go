@@ -0,0 +1,59 @@
+package main
+
+import (
+	"common"
+	"flag"
+	"os"
+	"strings"
+)
+
+// Arguments that are passed to hooks (hook variable -> argument). Environment variables that override
+// these arguments are named after hook variables ("NE_<VARIABLE>", for example "NE_RES_DIR" for "res").
+var hook_variable_arguments = map[string]string{
+	"res_dir":        "res",
+	"ext_dir":        "ext",
+	"engine_lib_dir": "engine-lib",
+	"work_dir":       "work-dir",
+	"build_dir":      "build-dir",
+	"binary":         "binary",
+	"release":        "release",
+}
+
+// Returns name of the environment variable that overrides the specified argument,
+// for example "NE_BUILD_DIR" for "build-dir".
+func get_environment_override_name(flag_name string) string {
+	for variable, argument := range hook_variable_arguments {
+		if argument == flag_name {
+			return "NE_" + strings.ToUpper(variable)
+		}
+	}
+	return "NE_" + strings.ToUpper(strings.ReplaceAll(flag_name, "-", "_"))
+}
+
+// Sets arguments that were not specified in the command line from "NE_*" environment variables
+// (empty variables are ignored). Arguments from the command line always take precedence over
+// environment variables and environment variables take precedence over default values.
+// Returns applied overrides in form "NAME=value".
+func apply_environment_overrides(specified_flags map[string]bool) []string {
+	var applied []string
+
+	flag.VisitAll(func(f *flag.Flag) {
+		if specified_flags[f.Name] {
+			return
+		}
+
+		var name = get_environment_override_name(f.Name)
+		var value = os.Getenv(name)
+		if value == "" {
+			return
+		}
+
+		var err = flag.Set(f.Name, value)
+		if err != nil {
+			common.Log_fatal("invalid value of the environment variable", name, "error:", err)
+		}
+		applied = append(applied, name+"="+value)
+	})
+
+	return applied
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHookVariablesMatchEnvironmentOverrides(t *testing.T) {
+	var variables = get_hook_variables(&post_build_options{}, post_build_target{})
+
+	for variable := range variables {
+		var argument, found = hook_variable_arguments[variable]
+		if !found {
+			t.Errorf("hook variable %q has no argument in `hook_variable_arguments`", variable)
+			continue
+		}
+
+		var expected = "NE_" + strings.ToUpper(variable)
+		if name := get_environment_override_name(argument); name != expected {
+			t.Errorf("argument %q is overridden by %q, expected %q", argument, name, expected)
+		}
+		if name := get_hook_environment_name(variable); name == expected {
+			t.Errorf("hooks receive %q that overrides argument %q of post build scripts started by hooks", name, argument)
+		}
+	}
+
+	for variable := range hook_variable_arguments {
+		if _, found := variables[variable]; !found {
+			t.Errorf("`hook_variable_arguments` has %q that is not passed to hooks", variable)
+		}
+	}
+}
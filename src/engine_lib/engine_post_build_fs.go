@@ -0,0 +1,473 @@
+package main
+
+import (
+	"common"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// Helpers for all operations that modify files. When "--dry-run" is specified these
+// only print the operation that would be performed.
+
+var dry_run = false
+
+// Whether to create NTFS junctions instead of symlinks to directories on Windows.
+var use_junction = false
+
+// Whether to create hardlinks instead of copying files (when source and destination are on the same volume).
+var use_hardlinks = false
+
+// Maximum number of files that are copied at the same time (see "--jobs").
+var copy_jobs = get_default_copy_jobs()
+
+// Windows error code returned when the process lacks SeCreateSymbolicLinkPrivilege.
+const windows_error_privilege_not_held = syscall.Errno(1314)
+
+// Returns the default number of parallel copies, too many parallel operations
+// slow down copying on spinning disks.
+func get_default_copy_jobs() int {
+	var max_jobs = 4
+	if runtime.NumCPU() < max_jobs {
+		return runtime.NumCPU()
+	}
+	return max_jobs
+}
+
+// Copies files using a bounded pool of workers (destination directories are created if needed).
+func copy_files(copies []file_copy) {
+	// Create directories first so that workers only copy files.
+	var created_directories = map[string]bool{}
+	for _, item := range copies {
+		var directory = filepath.Dir(item.dst)
+		if !created_directories[directory] {
+			make_directory(directory)
+			created_directories[directory] = true
+		}
+	}
+
+	var job_count = copy_jobs
+	if job_count < 1 || dry_run {
+		job_count = 1
+	}
+	if job_count > len(copies) {
+		job_count = len(copies)
+	}
+
+	var queue = make(chan file_copy)
+	var wait_group sync.WaitGroup
+	for i := 0; i < job_count; i++ {
+		wait_group.Add(1)
+		go func() {
+			defer wait_group.Done()
+			for item := range queue {
+				copy(item.src, item.dst)
+			}
+		}()
+	}
+
+	for _, item := range copies {
+		queue <- item
+	}
+	close(queue)
+	wait_group.Wait()
+}
+
+// Copies a file. Uses copy-on-write clones if the filesystem supports them
+// and hardlinks if "--hardlink" is specified (falls back to copying bytes).
+func copy(src string, dst string) {
+	if dry_run {
+		common.Log_info("[dry run] copy", src, "to", dst)
+		return
+	}
+
+	sourceFileStat, err := os.Stat(src)
+	if err != nil {
+		common.Log_fatal(err)
+	}
+
+	if !sourceFileStat.Mode().IsRegular() {
+		common.Log_fatal(src, "is not a file")
+	}
+
+	// Never write to the destination if it's a hardlink to the source (this would modify the source).
+	if destination_stat, err := os.Stat(dst); err == nil && os.SameFile(sourceFileStat, destination_stat) {
+		if use_hardlinks {
+			common.Log_verbose(dst, "is already a hardlink to", src)
+			report_file(src, dst, sourceFileStat.Size())
+			return
+		}
+		remove_all(dst)
+	}
+
+	if use_hardlinks {
+		remove_all(dst)
+		err = os.Link(src, dst)
+		if err == nil {
+			report_file(src, dst, sourceFileStat.Size())
+			return
+		}
+		common.Log_verbose("failed to create hardlink", dst, "error:", err, "- copying the file instead")
+	}
+
+	if try_clone_file(src, dst) {
+		keep_file_mode(dst, sourceFileStat)
+		report_file(src, dst, sourceFileStat.Size())
+		return
+	}
+
+	source, err := os.Open(src)
+	if err != nil {
+		common.Log_fatal("failed to open file", src, "error:", err)
+	}
+	defer source.Close()
+
+	destination, err := os.Create(dst)
+	if err != nil {
+		common.Log_fatal("failed to create file", dst, "error:", err)
+	}
+	defer destination.Close()
+	bytes, err := io.Copy(destination, source)
+	if err != nil {
+		common.Log_fatal("failed to copy file", src, "to", dst, "error:", err)
+	}
+	keep_file_mode(dst, sourceFileStat)
+
+	report_file(src, dst, bytes)
+}
+
+// Sets permissions of the copied file to permissions of the source file (so that copied
+// executables stay executable).
+func keep_file_mode(dst string, source_info os.FileInfo) {
+	var err = os.Chmod(dst, source_info.Mode().Perm())
+	if err != nil {
+		common.Log_fatal("failed to set permissions of", dst, "error:", err)
+	}
+}
+
+// Creates a directory (and all missing parent directories).
+func make_directory(path string) {
+	if dry_run {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			common.Log_info("[dry run] create directory", path)
+		}
+		return
+	}
+
+	var err = os.MkdirAll(path, os.ModePerm)
+	if err != nil {
+		common.Log_fatal("failed to create directory", path, "error:", err)
+	}
+}
+
+// Removes a file or a directory with all of its contents (if exists).
+func remove_all(path string) {
+	if _, err := os.Lstat(path); os.IsNotExist(err) {
+		return
+	}
+
+	if dry_run {
+		common.Log_info("[dry run] remove", path)
+		return
+	}
+
+	var err = os.RemoveAll(path)
+	if err != nil {
+		common.Log_fatal("failed to remove", path, "error:", err)
+	}
+}
+
+// Creates a symlink at "link_path" that points to "target".
+func create_symlink(target string, link_path string) error {
+	if dry_run {
+		common.Log_info("[dry run] create symlink", link_path, "->", target)
+		return nil
+	}
+
+	// Only the link path is converted, the target is stored in the link as is.
+	return os.Symlink(target, link_path)
+}
+
+// Creates a link at "link_path" to the "target" directory. On Windows creates
+// an NTFS junction if "--use-junction" is specified or if there are no privileges
+// to create symlinks (junctions don't require administrator rights or Developer Mode).
+func create_directory_link(target string, link_path string) error {
+	if runtime.GOOS == "windows" && use_junction {
+		return create_junction(target, link_path)
+	}
+
+	var err = create_symlink(target, link_path)
+	if err == nil || runtime.GOOS != "windows" {
+		return err
+	}
+
+	var errno syscall.Errno
+	if !errors.As(err, &errno) || errno != windows_error_privilege_not_held {
+		return err
+	}
+
+	common.Log_warning("not enough privileges to create a symlink at", link_path, "creating a directory junction instead")
+	return create_junction(target, link_path)
+}
+
+// Creates an NTFS junction at "link_path" that points to the "target" directory (Windows only).
+func create_junction(target string, link_path string) error {
+	absolute_target, err := filepath.Abs(target)
+	if err != nil {
+		return err
+	}
+
+	if dry_run {
+		common.Log_info("[dry run] create junction", link_path, "->", absolute_target)
+		return nil
+	}
+
+	output, err := exec.Command("cmd", "/c", "mklink", "/J", link_path, absolute_target).CombinedOutput()
+	if err != nil {
+		return errors.New(string(output) + err.Error())
+	}
+
+	return nil
+}
+
+// Checks whether the process can create symlinks before any step is executed. On Windows
+// switches to directory junctions if symlinks can't be created, on other platforms exits with an error.
+func check_symlink_support() {
+	if runtime.GOOS == "windows" && use_junction {
+		return
+	}
+
+	probe_directory, err := os.MkdirTemp("", "engine_post_build_symlink_probe")
+	if err != nil {
+		common.Log_warning("failed to create a temporary directory to check symlink support, error:", err)
+		return
+	}
+	defer os.RemoveAll(probe_directory)
+
+	err = os.Symlink(probe_directory, filepath.Join(probe_directory, "link"))
+	if err == nil {
+		common.Log_verbose("symlinks are supported")
+		return
+	}
+
+	if runtime.GOOS != "windows" {
+		common.Log_fatal("this process is not able to create symlinks (required for the 'res' directory), error:", err)
+	}
+
+	var errno syscall.Errno
+	if errors.As(err, &errno) && errno == windows_error_privilege_not_held {
+		if is_windows_developer_mode_enabled() {
+			common.Log_warning("Developer Mode is enabled but this process is not allowed to create symlinks, " +
+				"make sure that your Windows version supports unprivileged symlinks")
+		} else {
+			common.Log_warning("this process is not allowed to create symlinks. To fix this enable Developer Mode " +
+				"(Settings > Privacy & security > For developers) or run your IDE with administrator rights")
+		}
+	} else {
+		common.Log_warning("failed to create a test symlink, error:", err)
+	}
+
+	common.Log_info("using directory junctions instead of symlinks")
+	use_junction = true
+}
+
+// Reads Windows Developer Mode setting from the registry.
+func is_windows_developer_mode_enabled() bool {
+	output, err := exec.Command("reg", "query",
+		"HKLM\\SOFTWARE\\Microsoft\\Windows\\CurrentVersion\\AppModelUnlock",
+		"/v", "AllowDevelopmentWithoutDevLicense").Output()
+	if err != nil {
+		// Key does not exist.
+		return false
+	}
+
+	return strings.Contains(string(output), "0x1")
+}
+
+// Tells if the specified path is a symlink or a junction.
+func is_link(path string) bool {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return false
+	}
+	// Junctions are reported as irregular files on Windows.
+	return info.Mode()&os.ModeSymlink != 0 || info.Mode()&os.ModeIrregular != 0
+}
+
+// Tells if the specified link (symlink or junction) resolves to the specified directory.
+func is_link_to(link_path string, directory string) bool {
+	resolved_link, err := filepath.EvalSymlinks(link_path)
+	if err != nil {
+		// Broken link.
+		return false
+	}
+
+	resolved_directory, err := filepath.EvalSymlinks(directory)
+	if err != nil {
+		return false
+	}
+
+	if runtime.GOOS == "windows" {
+		return strings.EqualFold(filepath.Clean(resolved_link), filepath.Clean(resolved_directory))
+	}
+	return filepath.Clean(resolved_link) == filepath.Clean(resolved_directory)
+}
+
+// Removes a symlink or a junction without touching the directory it points to.
+func remove_link(path string) {
+	if dry_run {
+		common.Log_info("[dry run] remove link", path)
+		return
+	}
+
+	var err = os.Remove(path)
+	if err != nil {
+		common.Log_fatal("failed to remove link", path, "error:", err)
+	}
+}
+
+// Files that `sync_directory` handles differently, functions receive paths relative to the source directory
+// (with '/' separators) and can be nil.
+type sync_filter struct {
+	exclude func(relative_path string) bool // files that are not copied (and are removed from "dst")
+	keep    func(relative_path string) bool // files in "dst" that are not removed even if they don't exist in "src"
+}
+
+// Makes the "dst" directory an exact copy of the "src" directory (except for files of the filter): copies new
+// and modified (by size or modification time) files and removes files that don't exist in "src".
+// Returns the number of copied and removed files.
+func sync_directory(src string, dst string, filter sync_filter) (int, int) {
+	var copied_count = 0
+	var removed_count = 0
+
+	make_directory(dst)
+
+	// Copy new and modified files.
+	var err = filepath.Walk(src, func(path string, src_info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relative_path, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		var target = filepath.Join(dst, relative_path)
+
+		if src_info.IsDir() {
+			make_directory(target)
+			return nil
+		}
+		if filter.exclude != nil && filter.exclude(filepath.ToSlash(relative_path)) {
+			return nil
+		}
+
+		dst_info, err := os.Stat(target)
+		if err == nil && dst_info.Size() == src_info.Size() && dst_info.ModTime().Equal(src_info.ModTime()) {
+			return nil
+		}
+
+		copy(path, target)
+		copied_count += 1
+
+		if !dry_run {
+			// Keep modification time to detect changes next time.
+			return os.Chtimes(target, src_info.ModTime(), src_info.ModTime())
+		}
+		return nil
+	})
+	if err != nil {
+		common.Log_fatal("failed to copy directory", src, "to", dst, "error:", err)
+	}
+
+	if _, err := os.Stat(dst); os.IsNotExist(err) {
+		// Dry run.
+		return copied_count, removed_count
+	}
+
+	// Remove files that no longer exist in the source directory.
+	var paths_to_remove []string
+	err = filepath.Walk(dst, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relative_path, err := filepath.Rel(dst, path)
+		if err != nil {
+			return err
+		}
+
+		var slash_path = filepath.ToSlash(relative_path)
+		if !info.IsDir() && filter.exclude != nil && filter.exclude(slash_path) {
+			paths_to_remove = append(paths_to_remove, path)
+			return nil
+		}
+
+		if _, err := os.Stat(filepath.Join(src, relative_path)); os.IsNotExist(err) {
+			if filter.keep != nil && !info.IsDir() && filter.keep(slash_path) {
+				return nil
+			}
+			paths_to_remove = append(paths_to_remove, path)
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		common.Log_fatal("failed to read directory", dst, "error:", err)
+	}
+
+	for _, path := range paths_to_remove {
+		remove_all(path)
+		removed_count += 1
+	}
+
+	return copied_count, removed_count
+}
+
+// Returns the total size of files that `sync_directory` would copy from "src" to "dst".
+func get_directory_sync_size(src string, dst string, filter sync_filter) int64 {
+	var total_bytes int64 = 0
+
+	filepath.Walk(src, func(path string, src_info os.FileInfo, err error) error {
+		if err != nil || src_info.IsDir() {
+			return nil
+		}
+
+		relative_path, err := filepath.Rel(src, path)
+		if err != nil {
+			return nil
+		}
+		if filter.exclude != nil && filter.exclude(filepath.ToSlash(relative_path)) {
+			return nil
+		}
+
+		dst_info, err := os.Stat(filepath.Join(dst, relative_path))
+		if err == nil && dst_info.Size() == src_info.Size() && dst_info.ModTime().Equal(src_info.ModTime()) {
+			return nil
+		}
+
+		total_bytes += src_info.Size()
+		return nil
+	})
+
+	return total_bytes
+}
+
+// Returns the total size of source files.
+func get_copies_size(copies []file_copy) int64 {
+	var total_bytes int64 = 0
+	for _, item := range copies {
+		if info, err := os.Stat(item.src); err == nil {
+			total_bytes += info.Size()
+		}
+	}
+	return total_bytes
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Paths longer than MAX_PATH (260 characters) should work on Windows without extended-length paths.
+func TestSyncDirectoryLongPaths(t *testing.T) {
+	var root = t.TempDir()
+	var src = filepath.Join(root, "src")
+	var dst = filepath.Join(root, "dst")
+
+	var relative_path = filepath.Join(strings.Repeat("a", 100), strings.Repeat("b", 100), strings.Repeat("c", 100), "file.txt")
+	var source_file = filepath.Join(src, relative_path)
+	if len(source_file) <= 260 {
+		t.Fatalf("test path %q is not longer than 260 characters", source_file)
+	}
+
+	var err = os.MkdirAll(filepath.Dir(source_file), os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(source_file, []byte("content"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	copied_count, _ := sync_directory(src, dst, sync_filter{})
+	if copied_count != 1 {
+		t.Fatalf("expected 1 copied file, got %d", copied_count)
+	}
+	content, err := os.ReadFile(filepath.Join(dst, relative_path))
+	if err != nil || string(content) != "content" {
+		t.Fatalf("file was not copied: %v", err)
+	}
+
+	// Removed source files are removed from the copy.
+	remove_all(filepath.Join(src, strings.Repeat("a", 100)))
+	_, removed_count := sync_directory(src, dst, sync_filter{})
+	if removed_count != 1 {
+		t.Fatalf("expected 1 removed directory, got %d", removed_count)
+	}
+	if _, err := os.Stat(filepath.Join(dst, relative_path)); !os.IsNotExist(err) {
+		t.Fatalf("file was not removed: %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"common"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// Copies graphics debugging libraries (WinPixEventRuntime, RenderDoc in-app API) from the config
+// next to debug binaries. The directory of the executable is the first place where the
+// libraries are searched for so no additional search paths are needed.
+func copy_graphics_debugging_libs(config *post_build_config, target_directories []string, stamps *post_build_stamps) {
+	var libraries []string
+
+	if config.pix_library != "" {
+		if runtime.GOOS == "windows" {
+			libraries = append(libraries, config.resolve_path(config.pix_library))
+		} else {
+			common.Log_verbose("PIX is only available on Windows, skipping", config.pix_library)
+		}
+	}
+
+	if config.renderdoc_library != "" {
+		var path = config.renderdoc_library
+		if path == "auto" {
+			path = find_renderdoc_library()
+		} else {
+			path = config.resolve_path(path)
+		}
+		if path != "" {
+			libraries = append(libraries, path)
+		}
+	}
+
+	var copies []file_copy
+	for _, library := range libraries {
+		if _, err := os.Stat(library); os.IsNotExist(err) {
+			common.Log_fatal("graphics debugging library", library, "does not exist")
+		}
+		for _, directory := range target_directories {
+			copies = append(copies, file_copy{src: library, dst: filepath.Join(directory, filepath.Base(library))})
+		}
+	}
+	if len(copies) == 0 {
+		return
+	}
+
+	var inputs = fingerprint_files(get_copy_sources(copies), get_copy_destinations(copies)...)
+	var outputs = get_copy_destinations(copies)
+	if stamps.is_up_to_date(step_graphics_debug, inputs, outputs) {
+		common.Log_info("graphics debugging libraries are up to date")
+		report_step_status(step_status_up_to_date)
+		return
+	}
+
+	check_binaries_arch(libraries)
+	copy_files(copies)
+
+	stamps.update(step_graphics_debug, inputs, outputs)
+
+	common.Log_success("copied", len(libraries), "graphics debugging library(-ies)")
+}
+
+// Returns path to the RenderDoc library from the default installation directory or an empty
+// string (with a warning) if RenderDoc is not installed.
+func find_renderdoc_library() string {
+	var candidates []string
+	switch runtime.GOOS {
+	case "windows":
+		candidates = []string{filepath.Join(os.Getenv("ProgramFiles"), "RenderDoc", "renderdoc.dll")}
+	case "linux":
+		candidates = []string{"/usr/lib/librenderdoc.so", "/usr/lib64/librenderdoc.so", "/usr/local/lib/librenderdoc.so",
+			"/usr/lib/x86_64-linux-gnu/librenderdoc.so"}
+	}
+
+	for _, path := range candidates {
+		if _, err := os.Stat(path); err == nil {
+			common.Log_verbose("found RenderDoc library", path)
+			return path
+		}
+	}
+
+	report_warning("RenderDoc is not installed, its library will not be copied")
+	return ""
+}
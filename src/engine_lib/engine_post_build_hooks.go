@@ -0,0 +1,96 @@
+package main
+
+import (
+	"common"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// When a hook is executed relative to its step.
+const (
+	hook_before = "before"
+	hook_after  = "after"
+)
+
+// Returns values of placeholders in hook commands for the target.
+func get_hook_variables(options *post_build_options, target post_build_target) map[string]string {
+	var variables = map[string]string{
+		"res_dir":        options.res_directory,
+		"ext_dir":        options.ext_directory,
+		"engine_lib_dir": options.engine_lib_dir,
+		"work_dir":       target.working_directory,
+		"build_dir":      target.build_directory,
+		"binary":         target.binary_path,
+		"release":        "0",
+	}
+	if options.is_release {
+		variables["release"] = "1"
+	}
+	return variables
+}
+
+// Runs hooks that should be executed before the step and starts the step in the report.
+func begin_step_with_hooks(config *post_build_config, name string, variables map[string]string) {
+	run_hooks(config, hook_before, name, variables)
+	report_begin_step(name)
+}
+
+// Ends the current step in the report and runs hooks that should be executed after it.
+func end_step_with_hooks(config *post_build_config, variables map[string]string) {
+	var name = current_report.get_current_step().Name
+	report_end_step()
+	run_hooks(config, hook_after, name, variables)
+}
+
+// Runs hooks from the config that should be executed before/after the specified step.
+// Placeholders such as "{build_dir}" in the command are replaced with values from "variables",
+// values are also passed to the command as "NE_HOOK_<NAME>" environment variables (not as "NE_<NAME>"
+// so that a post build script started by the hook does not read them as its arguments).
+func run_hooks(config *post_build_config, when string, step string, variables map[string]string) {
+	for _, hook := range config.hooks {
+		if hook.when != when || hook.step != step {
+			continue
+		}
+
+		var args []string
+		for _, arg := range hook.command {
+			args = append(args, replace_hook_variables(arg, variables))
+		}
+
+		var working_directory = config.resolve_path(hook.working_directory)
+		if dry_run {
+			common.Log_info("[dry run] run hook", when, step+":", strings.Join(args, " "))
+			continue
+		}
+
+		common.Log_info("running hook", when, step+":", strings.Join(args, " "))
+
+		var command = exec.Command(args[0], args[1:]...)
+		command.Dir = working_directory
+		command.Stdout = os.Stdout
+		command.Stderr = os.Stderr
+		command.Env = os.Environ()
+		for name, value := range variables {
+			command.Env = append(command.Env, get_hook_environment_name(name)+"="+value)
+		}
+
+		var err = command.Run()
+		if err != nil {
+			common.Log_fatal("hook", when, step, "failed:", err)
+		}
+	}
+}
+
+// Returns name of the environment variable that passes the hook variable to hooks, for example
+// "NE_HOOK_BUILD_DIR" for "build_dir".
+func get_hook_environment_name(variable string) string {
+	return "NE_HOOK_" + strings.ToUpper(variable)
+}
+
+func replace_hook_variables(text string, variables map[string]string) string {
+	for name, value := range variables {
+		text = strings.ReplaceAll(text, "{"+name+"}", value)
+	}
+	return text
+}
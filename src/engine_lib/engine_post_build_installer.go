@@ -0,0 +1,211 @@
+package main
+
+import (
+	"common"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Default NSIS script of the installer, values in braces are replaced
+// (custom templates can be specified using "package.installer.template").
+const default_installer_template = `; Generated by engine_post_build.go, do not edit.
+Unicode true
+!include "MUI2.nsh"
+
+Name "{name}"
+OutFile "{output_file}"
+InstallDir "{install_directory}"
+InstallDirRegKey HKLM "Software\{name}" "InstallDir"
+RequestExecutionLevel admin
+
+!insertmacro MUI_PAGE_WELCOME
+{license_page}
+!insertmacro MUI_PAGE_DIRECTORY
+!insertmacro MUI_PAGE_INSTFILES
+!insertmacro MUI_PAGE_FINISH
+!insertmacro MUI_UNPAGE_CONFIRM
+!insertmacro MUI_UNPAGE_INSTFILES
+!insertmacro MUI_LANGUAGE "English"
+
+Section "Install"
+{install_files}
+{redist}
+  WriteRegStr HKLM "Software\{name}" "InstallDir" "$INSTDIR"
+  WriteRegStr HKLM "Software\Microsoft\Windows\CurrentVersion\Uninstall\{name}" "DisplayName" "{name}"
+  WriteRegStr HKLM "Software\Microsoft\Windows\CurrentVersion\Uninstall\{name}" "DisplayVersion" "{version}"
+  WriteRegStr HKLM "Software\Microsoft\Windows\CurrentVersion\Uninstall\{name}" "Publisher" "{publisher}"
+  WriteRegStr HKLM "Software\Microsoft\Windows\CurrentVersion\Uninstall\{name}" "UninstallString" "$\"$INSTDIR\uninstall.exe$\""
+  WriteUninstaller "$INSTDIR\uninstall.exe"
+{shortcuts}
+SectionEnd
+
+Section "Uninstall"
+{uninstall_files}
+  Delete "$INSTDIR\uninstall.exe"
+  RMDir "$INSTDIR"
+{uninstall_shortcuts}
+  DeleteRegKey HKLM "Software\Microsoft\Windows\CurrentVersion\Uninstall\{name}"
+  DeleteRegKey HKLM "Software\{name}"
+SectionEnd
+`
+
+// Silent installation arguments of known redistributable packages (by file name prefix).
+var installer_redist_args = map[string]string{
+	"vc_redist":  "/install /quiet /norestart",
+	"dxwebsetup": "/Q",
+}
+
+// Returns path to the installer that is created by `build_installer`.
+func get_installer_path(output_directory string, base_name string) string {
+	return filepath.Join(output_directory, base_name+"-"+get_package_platform()+"-setup.exe")
+}
+
+// Generates an NSIS script that installs packaged files (runs redistributable packages,
+// creates shortcuts and an uninstaller) and compiles it using "makensis".
+func build_installer(config *post_build_config, output_directory string, files []package_file, binary_path string) {
+	var makensis = find_makensis()
+	var name = get_package_name(config, binary_path)
+	var version = get_package_version(config)
+	var base_name = get_package_base_name(config, binary_path)
+	var installer_path = get_installer_path(output_directory, base_name)
+	var script_path = filepath.Join(output_directory, base_name+"-setup.nsi")
+
+	var template = default_installer_template
+	if config.installer_template != "" {
+		var template_path = config.resolve_path(config.installer_template)
+		content, err := os.ReadFile(template_path)
+		if err != nil {
+			common.Log_fatal("failed to read installer template", template_path, "error:", err)
+		}
+		template = string(content)
+	}
+
+	var publisher = config.installer_publisher
+	if publisher == "" {
+		publisher = config.executable_company_name
+	}
+
+	var install_directory = "$PROGRAMFILES64\\" + escape_nsis_string(name)
+	if target_arch == "x86" {
+		install_directory = "$PROGRAMFILES\\" + escape_nsis_string(name)
+	}
+
+	var license_page = ""
+	if config.installer_license != "" {
+		license_page = "!insertmacro MUI_PAGE_LICENSE \"" +
+			escape_nsis_string(config.resolve_path(config.installer_license)) + "\""
+	}
+
+	var install_files []string
+	var uninstall_files []string
+	var redist []string
+	var directories = map[string]bool{}
+	var current_directory = "."
+	for _, file := range files {
+		var directory = path.Dir(file.name)
+		if directory != current_directory || len(install_files) == 0 {
+			install_files = append(install_files, "  SetOutPath \""+get_nsis_install_path(directory)+"\"")
+			current_directory = directory
+		}
+		install_files = append(install_files, "  File \""+escape_nsis_string(file.path)+"\"")
+		uninstall_files = append(uninstall_files, "  Delete \""+get_nsis_install_path(file.name)+"\"")
+
+		for parent := directory; parent != "."; parent = path.Dir(parent) {
+			directories[parent] = true
+		}
+
+		if directory == "redist" {
+			for prefix, args := range installer_redist_args {
+				if strings.HasPrefix(strings.ToLower(path.Base(file.name)), prefix) {
+					redist = append(redist, "  ExecWait '\""+get_nsis_install_path(file.name)+"\" "+args+"'")
+				}
+			}
+		}
+	}
+
+	// Remove nested directories first.
+	var sorted_directories []string
+	for directory := range directories {
+		sorted_directories = append(sorted_directories, directory)
+	}
+	sort.Strings(sorted_directories)
+	sort.SliceStable(sorted_directories, func(i, j int) bool {
+		return strings.Count(sorted_directories[i], "/") > strings.Count(sorted_directories[j], "/")
+	})
+	for _, directory := range sorted_directories {
+		uninstall_files = append(uninstall_files, "  RMDir \""+get_nsis_install_path(directory)+"\"")
+	}
+
+	var shortcuts []string
+	var uninstall_shortcuts []string
+	if binary_path != "" {
+		var executable = get_nsis_install_path(filepath.Base(binary_path))
+		var shortcut_name = escape_nsis_string(name) + ".lnk"
+		shortcuts = append(shortcuts,
+			"  CreateDirectory \"$SMPROGRAMS\\"+escape_nsis_string(name)+"\"",
+			"  CreateShortcut \"$SMPROGRAMS\\"+escape_nsis_string(name)+"\\"+shortcut_name+"\" \""+executable+"\"")
+		uninstall_shortcuts = append(uninstall_shortcuts,
+			"  Delete \"$SMPROGRAMS\\"+escape_nsis_string(name)+"\\"+shortcut_name+"\"",
+			"  RMDir \"$SMPROGRAMS\\"+escape_nsis_string(name)+"\"")
+		if config.installer_desktop_shortcut {
+			shortcuts = append(shortcuts, "  CreateShortcut \"$DESKTOP\\"+shortcut_name+"\" \""+executable+"\"")
+			uninstall_shortcuts = append(uninstall_shortcuts, "  Delete \"$DESKTOP\\"+shortcut_name+"\"")
+		}
+	}
+
+	var script = replace_hook_variables(template, map[string]string{
+		"name":                escape_nsis_string(name),
+		"version":             escape_nsis_string(version),
+		"publisher":           escape_nsis_string(publisher),
+		"output_file":         escape_nsis_string(installer_path),
+		"install_directory":   install_directory,
+		"license_page":        license_page,
+		"install_files":       strings.Join(install_files, "\n"),
+		"uninstall_files":     strings.Join(uninstall_files, "\n"),
+		"redist":              strings.Join(redist, "\n"),
+		"shortcuts":           strings.Join(shortcuts, "\n"),
+		"uninstall_shortcuts": strings.Join(uninstall_shortcuts, "\n"),
+	})
+	write_text_file(script_path, script)
+
+	common.Log_info("creating installer", filepath.Base(installer_path))
+
+	output, err := exec.Command(makensis, "-V2", script_path).CombinedOutput()
+	if err != nil {
+		common.Log_fatal("failed to create installer using", script_path, "error:", err,
+			"output:", strings.TrimSpace(string(output)))
+	}
+}
+
+// Returns path inside of the installation directory.
+func get_nsis_install_path(name string) string {
+	if name == "." {
+		return "$INSTDIR"
+	}
+	return "$INSTDIR\\" + escape_nsis_string(strings.ReplaceAll(name, "/", "\\"))
+}
+
+// Escapes a value that is used in a double-quoted NSIS string.
+func escape_nsis_string(value string) string {
+	return strings.NewReplacer("$", "$$", "\"", "$\\\"").Replace(value)
+}
+
+func find_makensis() string {
+	if makensis, err := exec.LookPath("makensis"); err == nil {
+		return makensis
+	}
+
+	for _, directory := range []string{os.Getenv("ProgramFiles(x86)"), os.Getenv("ProgramFiles")} {
+		var makensis = filepath.Join(directory, "NSIS", "makensis.exe")
+		if _, err := os.Stat(makensis); directory != "" && err == nil {
+			return makensis
+		}
+	}
+
+	common.Log_fatal("\"makensis\" is required to create the installer but it was not found, install NSIS and add it to PATH")
+	return ""
+}
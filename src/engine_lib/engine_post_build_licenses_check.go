@@ -0,0 +1,76 @@
+package main
+
+import (
+	"common"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Matches dependencies that are referenced in CMake files, for example "${RELATIVE_EXT_PATH}/spdlog"
+// or "${CMAKE_SOURCE_DIR}/ext/glfw".
+var cmake_ext_reference_regexp = regexp.MustCompile(`(?:EXT_PATH\}|/ext)/([A-Za-z0-9_.+-]+)`)
+
+// Makes sure that every dependency (directories of the 'ext' directory, git submodules in 'ext' and
+// dependencies referenced in CMake files from "licenses.cmake_files") has exactly one license file
+// in the "ext" directory of the build directory and that there are no license files of unknown dependencies.
+func verify_ext_licenses(config *post_build_config, ext_directory string, build_directory string) {
+	var license_directory = filepath.Join(build_directory, "ext")
+
+	// Dependency name -> where it was found.
+	var dependencies = map[string]string{}
+	entries, _ := os.ReadDir(ext_directory)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			dependencies[entry.Name()] = "ext directory"
+		}
+	}
+	for name := range get_submodule_urls(ext_directory) {
+		dependencies[name] = ".gitmodules"
+	}
+	for _, cmake_file := range config.licenses_cmake_files {
+		var path = config.resolve_path(cmake_file)
+		content, err := os.ReadFile(path)
+		if err != nil {
+			common.Log_fatal("failed to read", path, "error:", err)
+		}
+		for _, match := range cmake_ext_reference_regexp.FindAllStringSubmatch(string(content), -1) {
+			if _, found := dependencies[match[1]]; !found {
+				dependencies[match[1]] = path
+			}
+		}
+	}
+
+	var licenses = map[string]bool{}
+	license_entries, _ := os.ReadDir(license_directory)
+	for _, entry := range license_entries {
+		if !entry.IsDir() {
+			licenses[strings.TrimSuffix(entry.Name(), ".txt")] = true
+		}
+	}
+
+	var errors []string
+	for name, source := range dependencies {
+		if _, err := os.Stat(filepath.Join(ext_directory, name)); err != nil {
+			errors = append(errors, name+" (from "+source+") does not exist in the 'ext' directory "+
+				"(submodule is not checked out?)")
+		} else if !licenses[name] {
+			errors = append(errors, name+" (from "+source+") has no license file in "+license_directory+
+				" (was the \""+step_licenses+"\" step skipped?)")
+		}
+	}
+	for name := range licenses {
+		if _, found := dependencies[name]; !found {
+			errors = append(errors, license_directory+" has a license file of unknown dependency "+name)
+		}
+	}
+
+	if len(errors) != 0 {
+		sort.Strings(errors)
+		common.Log_fatal("licenses of dependencies don't match the dependencies:\n  " + strings.Join(errors, "\n  "))
+	}
+
+	common.Log_verbose("licenses of", len(dependencies), "dependency(-ies) are in", license_directory)
+}
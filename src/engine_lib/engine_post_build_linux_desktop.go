@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"common"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Name of the directory (in the build directory) with files that are generated for packages.
+const package_staging_directory_name = "package_staging"
+
+// Default template of the freedesktop entry, values in braces are replaced
+// (custom templates can be specified using "package.linux_desktop.template").
+// "@INSTALL_DIR@" is replaced by "install.sh".
+const default_desktop_entry_template = `[Desktop Entry]
+Type=Application
+Name={display_name}
+Comment={comment}
+Exec="@INSTALL_DIR@/{executable}"
+Path=@INSTALL_DIR@
+Icon={id}
+Terminal=false
+Categories={categories}
+`
+
+// Script that is added to Linux packages to install the game for the current user
+// (or system-wide if started as root) with a menu entry and icons.
+const desktop_install_script_template = `#!/bin/sh
+# Generated by engine_post_build.go.
+# Usage: ./install.sh [--uninstall]
+# PREFIX and INSTALL_DIR environment variables can be used to change installation directories.
+set -e
+
+ID={id}
+NAME={display_name}
+SOURCE_DIR="$(cd "$(dirname "$0")" && pwd)"
+
+if [ "$(id -u)" = "0" ]; then
+    PREFIX="${PREFIX:-/usr/local}"
+    INSTALL_DIR="${INSTALL_DIR:-/opt/$ID}"
+else
+    PREFIX="${PREFIX:-$HOME/.local}"
+    INSTALL_DIR="${INSTALL_DIR:-$PREFIX/lib/$ID}"
+fi
+
+if [ "$1" = "--uninstall" ]; then
+    rm -rf "$INSTALL_DIR"
+    rm -f "$PREFIX/share/applications/$ID.desktop"
+    find "$PREFIX/share/icons/hicolor" -name "$ID.png" -delete 2>/dev/null || true
+    echo "$NAME was uninstalled"
+    exit 0
+fi
+
+mkdir -p "$INSTALL_DIR" "$PREFIX/share/applications"
+cp -R "$SOURCE_DIR/." "$INSTALL_DIR/"
+rm -rf "$INSTALL_DIR/share" "$INSTALL_DIR/install.sh"
+
+sed "s|@INSTALL_DIR@|$INSTALL_DIR|g" "$SOURCE_DIR/share/applications/$ID.desktop" > "$PREFIX/share/applications/$ID.desktop"
+cp -R "$SOURCE_DIR/share/icons" "$PREFIX/share/"
+
+if command -v update-desktop-database >/dev/null 2>&1; then
+    update-desktop-database "$PREFIX/share/applications" || true
+fi
+if command -v gtk-update-icon-cache >/dev/null 2>&1; then
+    gtk-update-icon-cache -q -t "$PREFIX/share/icons/hicolor" || true
+fi
+
+echo "$NAME was installed to $INSTALL_DIR"
+`
+
+// Generates a freedesktop entry ("share/applications/<id>.desktop"), hicolor icons
+// ("share/icons/hicolor/<size>/apps/<id>.png") and "install.sh" for Linux packages.
+// Returns files to add to the package.
+func get_linux_desktop_files(config *post_build_config, build_directory string, binary_path string) []package_file {
+	if binary_path == "" {
+		common.Log_fatal("\"--binary\" is required to create desktop entry of the package")
+	}
+
+	var name = get_package_name(config, binary_path)
+	var id = config.linux_desktop_id
+	if id == "" {
+		id = name
+	}
+	var display_name = config.linux_desktop_display_name
+	if display_name == "" {
+		display_name = name
+	}
+
+	var template = default_desktop_entry_template
+	if config.linux_desktop_template != "" {
+		var template_path = config.resolve_path(config.linux_desktop_template)
+		content, err := os.ReadFile(template_path)
+		if err != nil {
+			common.Log_fatal("failed to read desktop entry template", template_path, "error:", err)
+		}
+		template = string(content)
+	}
+
+	var desktop_entry = replace_hook_variables(template, map[string]string{
+		"id":           id,
+		"display_name": display_name,
+		"comment":      config.linux_desktop_comment,
+		"executable":   filepath.Base(binary_path),
+		"categories":   strings.Join(config.linux_desktop_categories, ";") + ";",
+	})
+	var install_script = replace_hook_variables(desktop_install_script_template, map[string]string{
+		"id":           quote_shell_string(id),
+		"display_name": quote_shell_string(display_name),
+	})
+
+	var staging_directory = filepath.Join(build_directory, package_staging_directory_name)
+	var desktop_entry_path = filepath.Join(staging_directory, id+".desktop")
+	var install_script_path = filepath.Join(staging_directory, "install.sh")
+	if dry_run {
+		common.Log_info("[dry run] write", desktop_entry_path, "and", install_script_path)
+		return nil
+	}
+
+	make_directory(staging_directory)
+	write_generated_file(desktop_entry_path, desktop_entry, 0644)
+	write_generated_file(install_script_path, install_script, 0755)
+
+	var files = []package_file{
+		get_generated_package_file(desktop_entry_path, "share/applications/"+id+".desktop"),
+		get_generated_package_file(install_script_path, "install.sh"),
+	}
+
+	for _, icon := range config.linux_desktop_icons {
+		var icon_path = config.resolve_path(icon)
+		var size = get_png_size(icon_path)
+		files = append(files, get_generated_package_file(icon_path, "share/icons/hicolor/"+size+"/apps/"+id+".png"))
+	}
+
+	return files
+}
+
+// Returns size of the PNG image in form "<width>x<height>".
+func get_png_size(path string) string {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		common.Log_fatal("failed to read icon", path, "error:", err)
+	}
+
+	image_config, err := png.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		common.Log_fatal("expected icon", path, "to be a PNG image, error:", err)
+	}
+	if image_config.Width != image_config.Height {
+		common.Log_fatal("expected icon", path, "to be square but its size is",
+			strconv.Itoa(image_config.Width)+"x"+strconv.Itoa(image_config.Height))
+	}
+
+	return strconv.Itoa(image_config.Width) + "x" + strconv.Itoa(image_config.Height)
+}
+
+func get_generated_package_file(path string, name string) package_file {
+	info, err := os.Stat(path)
+	if err != nil {
+		common.Log_fatal("failed to read", path, "error:", err)
+	}
+	return package_file{path: path, name: name, info: info}
+}
+
+// Writes the file only if its content was changed (so that packages are not recreated on every build).
+func write_generated_file(path string, content string, mode os.FileMode) {
+	if old_content, err := os.ReadFile(path); err == nil && string(old_content) == content {
+		return
+	}
+
+	var err = os.WriteFile(path, []byte(content), mode)
+	if err != nil {
+		common.Log_fatal("failed to write", path, "error:", err)
+	}
+}
+
+// Returns a single-quoted shell string.
+func quote_shell_string(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "'\\''") + "'"
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"common"
+	"html"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Default template of "Info.plist" of the application bundle, values in braces are replaced
+// (custom templates can be specified using "package.macos_bundle.info_plist").
+const default_info_plist_template = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+    <key>CFBundleDevelopmentRegion</key>
+    <string>en</string>
+    <key>CFBundleExecutable</key>
+    <string>{executable}</string>
+    <key>CFBundleIdentifier</key>
+    <string>{bundle_id}</string>
+    <key>CFBundleInfoDictionaryVersion</key>
+    <string>6.0</string>
+    <key>CFBundleName</key>
+    <string>{display_name}</string>
+    <key>CFBundlePackageType</key>
+    <string>APPL</string>
+    <key>CFBundleShortVersionString</key>
+    <string>{version}</string>
+    <key>CFBundleVersion</key>
+    <string>{version}</string>
+    <key>CFBundleIconFile</key>
+    <string>{icon}</string>
+    <key>LSMinimumSystemVersion</key>
+    <string>{minimum_system_version}</string>
+    <key>NSHighResolutionCapable</key>
+    <true/>
+</dict>
+</plist>
+`
+
+// Environment variables with credentials of the Apple notary service.
+const (
+	notary_apple_id_env = "NE_NOTARY_APPLE_ID"
+	notary_team_id_env  = "NE_NOTARY_TEAM_ID"
+	notary_password_env = "NE_NOTARY_PASSWORD"
+)
+
+// Assembles "<display name>.app" bundle in the staging directory from packaged files:
+// the executable is placed into "Contents/MacOS", dylibs into "Contents/Frameworks"
+// (with install names/rpaths changed to "@rpath") and everything else (such as 'res')
+// into "Contents/Resources". Then optionally signs and notarizes the bundle.
+// Returns files of the bundle to add to the package instead of the specified files.
+func build_macos_bundle(config *post_build_config, build_directory string, binary_path string,
+	files []package_file) []package_file {
+	if binary_path == "" {
+		common.Log_fatal("\"--binary\" is required to create application bundle")
+	}
+
+	var display_name = config.macos_bundle_display_name
+	if display_name == "" {
+		display_name = get_package_name(config, binary_path)
+	}
+	var version = get_package_version(config)
+	if version == "" {
+		version = "1.0"
+	}
+
+	var staging_directory = filepath.Join(build_directory, package_staging_directory_name)
+	var bundle_name = display_name + ".app"
+	var bundle_path = filepath.Join(staging_directory, bundle_name)
+	var contents_path = filepath.Join(bundle_path, "Contents")
+	var executable_name = filepath.Base(binary_path)
+
+	// Start from scratch so that removed files don't stay in the bundle.
+	remove_all(bundle_path)
+
+	common.Log_info("creating application bundle", bundle_name)
+
+	var copies []file_copy
+	var frameworks []string
+	for _, file := range files {
+		var destination string
+		switch {
+		case file.name == executable_name:
+			destination = filepath.Join(contents_path, "MacOS", executable_name)
+		case !strings.Contains(file.name, "/") && strings.HasSuffix(file.name, ".dylib"):
+			destination = filepath.Join(contents_path, "Frameworks", file.name)
+			frameworks = append(frameworks, destination)
+		default:
+			destination = filepath.Join(contents_path, "Resources", filepath.FromSlash(file.name))
+		}
+		copies = append(copies, file_copy{src: file.path, dst: destination})
+	}
+
+	var icon = ""
+	if config.macos_bundle_icon != "" {
+		var icon_path = config.resolve_path(config.macos_bundle_icon)
+		icon = filepath.Base(icon_path)
+		copies = append(copies, file_copy{src: icon_path, dst: filepath.Join(contents_path, "Resources", icon)})
+	}
+
+	for _, item := range copies {
+		make_directory(filepath.Dir(item.dst))
+	}
+	copy_files(copies)
+
+	var template = default_info_plist_template
+	if config.macos_bundle_info_plist != "" {
+		var template_path = config.resolve_path(config.macos_bundle_info_plist)
+		content, err := os.ReadFile(template_path)
+		if err != nil {
+			common.Log_fatal("failed to read Info.plist template", template_path, "error:", err)
+		}
+		template = string(content)
+	}
+	write_generated_file(filepath.Join(contents_path, "Info.plist"), replace_hook_variables(template, map[string]string{
+		"executable":             html.EscapeString(executable_name),
+		"bundle_id":              html.EscapeString(config.macos_bundle_id),
+		"display_name":           html.EscapeString(display_name),
+		"version":                html.EscapeString(version),
+		"icon":                   html.EscapeString(icon),
+		"minimum_system_version": html.EscapeString(config.macos_bundle_minimum_system_version),
+	}), 0644)
+
+	var bundle_executable = filepath.Join(contents_path, "MacOS", executable_name)
+	fix_macos_install_names(bundle_executable, frameworks)
+
+	var identity = os.Getenv(config.macos_bundle_signing_identity_env)
+	if identity == "" {
+		common.Log_info("environment variable", config.macos_bundle_signing_identity_env,
+			"is not set, application bundle will not be signed")
+	} else {
+		sign_macos_bundle(config, bundle_path, bundle_executable, frameworks, identity)
+		if config.macos_bundle_notarize {
+			notarize_macos_bundle(bundle_path)
+		}
+	}
+
+	return collect_package_files_with_prefix(staging_directory, bundle_name)
+}
+
+// Makes the executable and dylibs load bundled dylibs from "Contents/Frameworks" using "@rpath".
+func fix_macos_install_names(executable_path string, frameworks []string) {
+	var bundled_names = map[string]bool{}
+	for _, framework := range frameworks {
+		bundled_names[filepath.Base(framework)] = true
+	}
+
+	for _, binary := range append([]string{executable_path}, frameworks...) {
+		if binary != executable_path {
+			run_binutils("install_name_tool", "-id", "@rpath/"+filepath.Base(binary), binary)
+		}
+
+		for _, dependency := range get_macos_dependencies(binary) {
+			var name = path.Base(dependency)
+			if bundled_names[name] && dependency != "@rpath/"+name {
+				run_binutils("install_name_tool", "-change", dependency, "@rpath/"+name, binary)
+			}
+		}
+	}
+
+	// The rpath might already exist if the executable was linked with it.
+	output, err := exec.Command("otool", "-l", executable_path).Output()
+	if err != nil || !strings.Contains(string(output), "@executable_path/../Frameworks") {
+		run_binutils("install_name_tool", "-add_rpath", "@executable_path/../Frameworks", executable_path)
+	}
+}
+
+// Returns install names of libraries that the Mach-O file depends on.
+func get_macos_dependencies(binary string) []string {
+	output, err := exec.Command("otool", "-L", binary).Output()
+	if err != nil {
+		common.Log_fatal("failed to get dependencies of", binary, "error:", err)
+	}
+
+	var dependencies []string
+	for _, line := range strings.Split(string(output), "\n")[1:] {
+		line = strings.TrimSpace(line)
+		if index := strings.Index(line, " (compatibility version"); index > 0 {
+			dependencies = append(dependencies, line[:index])
+		}
+	}
+	return dependencies
+}
+
+// Signs bundled dylibs, the executable and then the bundle (with hardened runtime for notarization).
+func sign_macos_bundle(config *post_build_config, bundle_path string, executable_path string, frameworks []string,
+	identity string) {
+	var args = []string{"--force", "--timestamp", "--options", "runtime", "--sign", identity}
+	if config.macos_bundle_entitlements != "" {
+		args = append(args, "--entitlements", config.resolve_path(config.macos_bundle_entitlements))
+	}
+
+	for _, file_path := range append(append(append([]string{}, frameworks...), executable_path), bundle_path) {
+		common.Log_verbose("signing", file_path)
+		run_binutils("codesign", append(args, file_path)...)
+	}
+	run_binutils("codesign", "--verify", "--strict", "--deep", bundle_path)
+
+	common.Log_info("application bundle was signed")
+}
+
+// Submits the bundle to the Apple notary service, waits for the result and staples the ticket.
+func notarize_macos_bundle(bundle_path string) {
+	var apple_id = os.Getenv(notary_apple_id_env)
+	var team_id = os.Getenv(notary_team_id_env)
+	var password = os.Getenv(notary_password_env)
+	if apple_id == "" || team_id == "" || password == "" {
+		common.Log_fatal("notarization requires", notary_apple_id_env+",", notary_team_id_env, "and", notary_password_env,
+			"environment variables")
+	}
+
+	// The notary service only accepts archives.
+	var archive_path = bundle_path + ".zip"
+	run_binutils("ditto", "-c", "-k", "--keepParent", bundle_path, archive_path)
+	defer os.Remove(archive_path)
+
+	common.Log_info("notarizing application bundle, this might take a few minutes")
+
+	// Don't use `run_binutils` to not print the password.
+	output, err := exec.Command("xcrun", "notarytool", "submit", archive_path, "--apple-id", apple_id,
+		"--team-id", team_id, "--password", password, "--wait").CombinedOutput()
+	if err != nil || !strings.Contains(string(output), "status: Accepted") {
+		common.Log_fatal("failed to notarize", bundle_path, "error:", err, "output:", strings.TrimSpace(string(output)))
+	}
+
+	run_binutils("xcrun", "stapler", "staple", bundle_path)
+
+	common.Log_info("application bundle was notarized")
+}
+
+// Returns all files from the directory (of the staging directory) with their paths
+// relative to the staging directory as names.
+func collect_package_files_with_prefix(staging_directory string, directory_name string) []package_file {
+	var files, _ = collect_package_files(filepath.Join(staging_directory, directory_name), "", nil, nil)
+	for i := range files {
+		files[i].name = directory_name + "/" + files[i].name
+	}
+	return files
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"common"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Build directory of a configuration of a multi-config generator (Visual Studio, Ninja Multi-Config, etc.).
+type configuration_directory struct {
+	name       string
+	path       string
+	is_release bool
+}
+
+// Returns directories of the specified configurations ("Debug,Release,RelWithDebInfo", CMake lists
+// separated by ';' are also accepted) in the working directory except for the current build directory.
+// Directories that don't exist yet (configurations that were not built) are reported and ignored,
+// files are deployed to them when their configuration is built.
+func get_configuration_directories(configurations string, working_directory string, build_directory string) []configuration_directory {
+	var directories []configuration_directory
+
+	for _, name := range strings.FieldsFunc(configurations, func(char rune) bool { return char == ',' || char == ';' }) {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		var path = filepath.Join(working_directory, name)
+		if filepath.Clean(path) == filepath.Clean(build_directory) {
+			continue
+		}
+
+		if info, err := os.Stat(path); err != nil || !info.IsDir() {
+			common.Log_info("build directory of configuration", name, "("+path+") does not exist, "+
+				"skipping it until this configuration is built")
+			continue
+		}
+
+		directories = append(directories, configuration_directory{
+			name: name,
+			path: path,
+			// Only "Debug" uses debug libraries, other configurations (RelWithDebInfo, MinSizeRel, etc.) are optimized.
+			is_release: !strings.EqualFold(name, "Debug"),
+		})
+	}
+
+	return directories
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"common"
+	"html"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Name (without extension) of the files (in the build directory) with licenses of all dependencies.
+const third_party_notices_file_name = "THIRD-PARTY-NOTICES"
+
+// A dependency from the 'ext' directory.
+type ext_dependency struct {
+	name         string
+	version      string // tag or commit of the git submodule, "unknown" if not available
+	license      string // SPDX identifier, see `check_license_policy`
+	license_path string
+}
+
+// Returns dependencies from copies of license files (see `find_ext_licenses`).
+func get_ext_dependencies(ext_directory string, copies []file_copy) []ext_dependency {
+	var dependencies []ext_dependency
+	for _, item := range copies {
+		var name = strings.TrimSuffix(filepath.Base(item.dst), ".txt")
+
+		var version, err = run_git(filepath.Join(ext_directory, name), "describe", "--tags", "--always")
+		if err != nil || version == "" {
+			version = "unknown"
+		}
+
+		dependencies = append(dependencies, ext_dependency{name: name, version: version, license_path: item.src})
+	}
+	return dependencies
+}
+
+// Returns paths to the files that are written by `write_third_party_notices`.
+func get_third_party_notices_paths(build_directory string) []string {
+	return []string{
+		filepath.Join(build_directory, third_party_notices_file_name+".txt"),
+		filepath.Join(build_directory, third_party_notices_file_name+".html"),
+	}
+}
+
+// Writes "THIRD-PARTY-NOTICES.txt" and "THIRD-PARTY-NOTICES.html" with names, versions
+// and license texts of all dependencies (for example to show them in the game).
+func write_third_party_notices(dependencies []ext_dependency, build_directory string) {
+	if dry_run {
+		common.Log_info("[dry run] write third-party notices to", build_directory)
+		return
+	}
+
+	var text strings.Builder
+	var page strings.Builder
+	var separator = strings.Repeat("=", 80)
+
+	text.WriteString("THIRD-PARTY SOFTWARE NOTICES\n\nThis software uses the following third-party libraries:\n\n")
+	page.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n" +
+		"<title>Third-Party Software Notices</title>\n</head>\n<body>\n" +
+		"<h1>Third-Party Software Notices</h1>\n<p>This software uses the following third-party libraries:</p>\n<ul>\n")
+	for _, dependency := range dependencies {
+		text.WriteString("- " + dependency.name + " (" + dependency.version + ", " + dependency.license + ")\n")
+		page.WriteString("<li><a href=\"#" + html.EscapeString(dependency.name) + "\">" +
+			html.EscapeString(dependency.name) + "</a> (" + html.EscapeString(dependency.version) + ", " +
+			html.EscapeString(dependency.license) + ")</li>\n")
+	}
+	page.WriteString("</ul>\n")
+
+	for _, dependency := range dependencies {
+		content, err := os.ReadFile(dependency.license_path)
+		if err != nil {
+			common.Log_fatal("failed to read license file", dependency.license_path, "error:", err)
+		}
+		var license = strings.TrimSpace(strings.ReplaceAll(string(content), "\r\n", "\n"))
+
+		text.WriteString("\n" + separator + "\n" + dependency.name + " (" + dependency.version + ")\n" +
+			separator + "\n\n" + license + "\n")
+		page.WriteString("<h2 id=\"" + html.EscapeString(dependency.name) + "\">" + html.EscapeString(dependency.name) +
+			" (" + html.EscapeString(dependency.version) + ")</h2>\n<pre>" + html.EscapeString(license) + "</pre>\n")
+	}
+	page.WriteString("</body>\n</html>\n")
+
+	var paths = get_third_party_notices_paths(build_directory)
+	write_text_file(paths[0], text.String())
+	write_text_file(paths[1], page.String())
+
+	common.Log_info("third-party notices were written to", paths[0])
+}
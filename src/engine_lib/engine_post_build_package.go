@@ -0,0 +1,558 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"common"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Supported formats of packages.
+const (
+	package_format_zip     = "zip"
+	package_format_tar_gz  = "tar.gz"
+	package_format_tar_zst = "tar.zst"
+)
+
+// Name of the file (in the package output directory) with SHA-256 of packages and redistributable packages.
+const package_checksums_file_name = "SHA256SUMS"
+
+// Name of the directory (in the build directory) that packages are written to by default.
+const default_package_directory_name = "package"
+
+// Files and directories of the build directory that are never included in packages
+// (build system files, debug information and files of the post build script).
+// Patterns without '/' are matched against names of files/directories on any level,
+// other patterns are matched against paths relative to the build directory.
+var default_package_excludes = []string{
+	symbols_directory_name, default_package_directory_name, package_staging_directory_name, stamp_file_name,
+	"CMakeFiles", "CMakeCache.txt", "*.cmake", "Makefile", "build.ninja", "*.ninja", ".ninja_*", "compile_commands.json",
+	"*.ilk", "*.pdb", "*.idb", "*.iobj", "*.ipdb", "*.exp", "*.lib", "*.map", "*.tlog", "*.a", "*.o", "*.obj", "*.dSYM",
+	"*.log", "*.sha256",
+}
+
+// A file from the build directory that is added to a package.
+type package_file struct {
+	path string // path to the file on disk
+	name string // path inside of the package (relative to the package root, uses '/' as separator)
+	info os.FileInfo
+}
+
+// Packs the build directory into distributable archives named "<name>-<version>-<platform>"
+// (in the package output directory) that contain a single "<name>-<version>" directory.
+// Returns paths to the archives.
+func package_build(config *post_build_config, ext_directory string, build_directory string, binary_path string,
+	stamps *post_build_stamps) []string {
+	var base_name = get_package_base_name(config, binary_path)
+	var output_directory = get_package_output_directory(config, build_directory)
+
+	var archive_paths []string
+	for _, format := range config.package_formats {
+		archive_paths = append(archive_paths,
+			filepath.Join(output_directory, base_name+"-"+get_package_platform()+"."+format))
+	}
+
+	// Licenses of all dependencies need to be shipped.
+	verify_ext_licenses(config, ext_directory, build_directory)
+
+	var excludes = append(append([]string{}, default_package_excludes...), config.package_excludes...)
+	var files, excluded_names = collect_package_files(build_directory, output_directory, excludes, config.package_keeps)
+	if config.linux_desktop_enabled && runtime.GOOS == "linux" {
+		files = append(files, get_linux_desktop_files(config, build_directory, binary_path)...)
+	}
+	if len(files) == 0 {
+		common.Log_fatal("no files to package in", build_directory)
+	}
+
+	var paths []string
+	var names []string
+	var total_bytes int64 = 0
+	for _, file := range files {
+		paths = append(paths, file.path)
+		names = append(names, file.name)
+		total_bytes += file.info.Size()
+	}
+
+	var build_windows_installer = config.installer_enabled && runtime.GOOS == "windows"
+	if build_windows_installer {
+		archive_paths = append(archive_paths, get_installer_path(output_directory, base_name))
+	}
+
+	// Redistributable packages are also distributed next to the packages (for example on a download page).
+	var checksum_paths = append([]string{}, archive_paths...)
+	redist_entries, _ := os.ReadDir(filepath.Join(build_directory, "redist"))
+	for _, entry := range redist_entries {
+		if entry.Type().IsRegular() && !strings.HasSuffix(entry.Name(), ".sha256") {
+			checksum_paths = append(checksum_paths, filepath.Join(build_directory, "redist", entry.Name()))
+		}
+	}
+
+	if config.package_sbom {
+		checksum_paths = append(checksum_paths, get_sbom_path(output_directory, base_name))
+	}
+
+	var outputs = append([]string{}, archive_paths...)
+	outputs = append(outputs, get_excluded_report_path(output_directory, base_name))
+	if config.package_sbom {
+		outputs = append(outputs, get_sbom_path(output_directory, base_name))
+	}
+	if config.steam_depot_enabled {
+		outputs = append(outputs, get_steam_app_build_script_path(config, output_directory))
+	}
+	if config.package_checksums {
+		outputs = append(outputs, filepath.Join(output_directory, package_checksums_file_name))
+	}
+	if config.package_checksum_files {
+		for _, checksum_path := range checksum_paths {
+			outputs = append(outputs, checksum_path+".sha256")
+		}
+	}
+
+	var inputs = fingerprint_files(append(paths, config.get_package_input_paths()...),
+		append(config.get_package_settings(), base_name, strings.Join(names, ","), strconv.FormatBool(build_windows_installer))...)
+	if stamps.is_up_to_date(step_package, inputs, outputs) {
+		common.Log_info("packages are up to date")
+		report_step_status(step_status_up_to_date)
+		return archive_paths
+	}
+
+	if dry_run {
+		for _, archive_path := range archive_paths {
+			common.Log_info("[dry run] pack", len(files), "file(-s) to", archive_path)
+		}
+		return archive_paths
+	}
+
+	var zstd = ""
+	if contains_string(config.package_formats, package_format_tar_zst) {
+		zstd = find_zstd()
+	}
+
+	common.Check_free_disk_space(output_directory, total_bytes*int64(len(archive_paths)), "packages")
+	make_directory(output_directory)
+
+	write_excluded_report(build_directory, get_excluded_report_path(output_directory, base_name), excluded_names)
+
+	if config.macos_bundle_enabled && runtime.GOOS == "darwin" {
+		files = build_macos_bundle(config, build_directory, binary_path, files)
+	}
+
+	if config.package_verify_launch {
+		verify_package_launch(config, build_directory, binary_path, files)
+	}
+
+	if build_windows_installer {
+		build_installer(config, output_directory, files, binary_path)
+	}
+
+	for _, archive_path := range archive_paths {
+		if build_windows_installer && archive_path == get_installer_path(output_directory, base_name) {
+			continue
+		}
+
+		common.Log_info("packing", len(files), "file(-s) to", filepath.Base(archive_path))
+
+		// Write to a temporary file so that an interrupted build does not leave a broken archive.
+		var temp_path = archive_path + ".tmp"
+		switch {
+		case strings.HasSuffix(archive_path, "."+package_format_zip):
+			write_zip_package(temp_path, base_name, files)
+		case strings.HasSuffix(archive_path, "."+package_format_tar_gz):
+			write_tar_package(temp_path, base_name, files, true)
+		default:
+			write_tar_zst_package(temp_path, base_name, files, zstd)
+		}
+
+		var err = os.Rename(temp_path, archive_path)
+		if err != nil {
+			common.Log_fatal("failed to rename", temp_path, "to", archive_path, "error:", err)
+		}
+
+		if info, err := os.Stat(archive_path); err == nil {
+			common.Log_info(filepath.Base(archive_path), "size is", common.Format_byte_count(info.Size()))
+		}
+	}
+
+	if config.steam_depot_enabled {
+		var app_build_path = write_steam_build_scripts(config, output_directory, base_name, files)
+		if config.steam_depot_upload {
+			upload_steam_build(config, app_build_path)
+		}
+	}
+
+	if config.package_sbom {
+		write_sbom(config, ext_directory, build_directory, binary_path, output_directory)
+	}
+
+	write_package_checksums(config, output_directory, checksum_paths)
+
+	stamps.update(step_package, inputs, outputs)
+
+	common.Log_success("packed", common.Format_byte_count(total_bytes), "to", strings.Join(archive_paths, ", "))
+
+	return archive_paths
+}
+
+// Returns custom templates and other files from the config that are used to create packages.
+func (config *post_build_config) get_package_input_paths() []string {
+	var paths []string
+	for _, file_path := range []string{config.installer_template, config.installer_license, config.linux_desktop_template,
+		config.macos_bundle_info_plist, config.macos_bundle_icon, config.macos_bundle_entitlements} {
+		if file_path != "" {
+			paths = append(paths, config.resolve_path(file_path))
+		}
+	}
+	return paths
+}
+
+// Returns settings from the config that affect content of packages.
+func (config *post_build_config) get_package_settings() []string {
+	return []string{
+		fmt.Sprint(config.package_checksums, config.package_checksum_files, config.package_sbom, config.package_verify_launch),
+		fmt.Sprint(config.installer_publisher, config.installer_desktop_shortcut),
+		fmt.Sprint(config.linux_desktop_enabled, config.linux_desktop_icons),
+		fmt.Sprint(config.macos_bundle_enabled, config.macos_bundle_id, config.macos_bundle_display_name,
+			config.macos_bundle_minimum_system_version, config.macos_bundle_signing_identity_env, config.macos_bundle_notarize),
+		fmt.Sprint(config.steam_depot_enabled, config.get_steam_depot_app_id(), config.steam_depot_id, config.steam_depot_branch,
+			config.steam_depot_description, config.steam_depot_upload),
+	}
+}
+
+// Returns "<name>-<version>" (or just "<name>" if there is no version).
+func get_package_base_name(config *post_build_config, binary_path string) string {
+	var version = get_package_version(config)
+	if version == "" {
+		return get_package_name(config, binary_path)
+	}
+	return get_package_name(config, binary_path) + "-" + version
+}
+
+func get_package_name(config *post_build_config, binary_path string) string {
+	var name = config.package_name
+	if name == "" && binary_path != "" {
+		name = strings.TrimSuffix(filepath.Base(binary_path), filepath.Ext(binary_path))
+	}
+	if name == "" {
+		common.Log_fatal("package name is unknown, specify \"package.name\" in the config or \"--binary\"")
+	}
+	return name
+}
+
+// Returns version of the package, empty if not specified.
+func get_package_version(config *post_build_config) string {
+	if config.package_version != "" {
+		return config.package_version
+	}
+	if config.executable_version != "" {
+		return config.executable_version
+	}
+	return config.engine_version
+}
+
+// Returns name of the target platform used in package names, for example "windows-x64".
+func get_package_platform() string {
+	if runtime.GOOS == "darwin" {
+		return "macos-" + target_arch
+	}
+	return runtime.GOOS + "-" + target_arch
+}
+
+func get_package_output_directory(config *post_build_config, build_directory string) string {
+	if config.package_output_directory == "" {
+		return filepath.Join(build_directory, default_package_directory_name)
+	}
+	return config.resolve_path(config.package_output_directory)
+}
+
+// Returns path to the report that is written by `write_excluded_report`.
+func get_excluded_report_path(output_directory string, base_name string) string {
+	return filepath.Join(output_directory, base_name+"-"+get_package_platform()+".excluded.txt")
+}
+
+// Writes a report with sizes of files and directories (paths relative to the build directory)
+// that were not packaged and their total size.
+func write_excluded_report(build_directory string, report_path string, excluded_names []string) {
+	var lines = []string{"# Files and directories of " + build_directory + " that were not packaged."}
+	var total_bytes int64 = 0
+	for _, name := range excluded_names {
+		var size = get_path_size(filepath.Join(build_directory, filepath.FromSlash(name)))
+		total_bytes += size
+		lines = append(lines, strconv.FormatInt(size, 10)+"\t"+name)
+	}
+	lines = append(lines, "# Total: "+strconv.FormatInt(total_bytes, 10)+" bytes ("+common.Format_byte_count(total_bytes)+")", "")
+
+	write_text_file(report_path, strings.Join(lines, "\n"))
+
+	common.Log_info("excluded", len(excluded_names), "file(-s)/directory(-ies) with", common.Format_byte_count(total_bytes),
+		"from the package, see", report_path)
+}
+
+// Returns the size of the file or the total size of files in the directory.
+func get_path_size(path string) int64 {
+	var total_bytes int64 = 0
+	filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			total_bytes += info.Size()
+		}
+		return nil
+	})
+	return total_bytes
+}
+
+// Returns files from the build directory that should be packaged (symlinks such as the link
+// to the 'res' directory are followed) and paths (relative to the build directory) of excluded
+// files and directories.
+func collect_package_files(build_directory string, output_directory string, excludes []string,
+	keeps []string) ([]package_file, []string) {
+	var files []package_file
+	var excluded_names []string
+	var visited_directories = map[string]bool{}
+	var output_directory_path, _ = filepath.Abs(output_directory)
+
+	// "is_excluded" is true for excluded directories that are only walked to find kept files.
+	var walk func(directory string, package_directory string, is_excluded bool)
+	walk = func(directory string, package_directory string, is_excluded bool) {
+		real_path, err := filepath.EvalSymlinks(directory)
+		if err != nil {
+			common.Log_fatal("failed to resolve", directory, "error:", err)
+		}
+		if visited_directories[real_path] {
+			report_warning("directory " + directory + " was already packaged (symlink loop?), skipping it")
+			return
+		}
+		visited_directories[real_path] = true
+
+		entries, err := os.ReadDir(directory)
+		if err != nil {
+			common.Log_fatal("failed to read directory", directory, "error:", err)
+		}
+
+		for _, entry := range entries {
+			var file_path = filepath.Join(directory, entry.Name())
+			var name = path.Join(package_directory, entry.Name())
+
+			if absolute_path, _ := filepath.Abs(file_path); absolute_path == output_directory_path {
+				continue
+			}
+
+			var is_entry_excluded = is_excluded
+			if matches_package_pattern(name, keeps) {
+				is_entry_excluded = false
+			} else if !is_entry_excluded && matches_package_pattern(name, excludes) {
+				is_entry_excluded = true
+			}
+			if is_entry_excluded && !(entry.IsDir() && may_contain_kept_files(name, keeps)) {
+				common.Log_verbose("excluding", name, "from the package")
+				excluded_names = append(excluded_names, name)
+				continue
+			}
+
+			// Follow symlinks.
+			info, err := os.Stat(file_path)
+			if err != nil {
+				report_warning("failed to read " + file_path + " (broken symlink?), skipping it, error: " + err.Error())
+				continue
+			}
+
+			if info.IsDir() {
+				walk(file_path, name, is_entry_excluded)
+			} else if info.Mode().IsRegular() && !is_entry_excluded {
+				files = append(files, package_file{path: file_path, name: name, info: info})
+			}
+		}
+	}
+	walk(build_directory, "", false)
+
+	return files, excluded_names
+}
+
+// Tells if the file/directory (path relative to the build directory) matches one of the patterns,
+// patterns without '/' are matched against the base name.
+func matches_package_pattern(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		var value = path.Base(name)
+		if strings.Contains(pattern, "/") {
+			value = name
+		}
+
+		matched, err := path.Match(pattern, value)
+		if err != nil {
+			common.Log_fatal("invalid package pattern", "\""+pattern+"\"", "error:", err)
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Tells if the directory (path relative to the build directory) might contain files
+// that match one of the keep patterns (that have '/').
+func may_contain_kept_files(name string, keeps []string) bool {
+	var directories = strings.Split(name, "/")
+	for _, pattern := range keeps {
+		var parts = strings.Split(pattern, "/")
+		if len(parts) <= len(directories) {
+			continue
+		}
+
+		var matched = true
+		for i, directory := range directories {
+			if ok, _ := path.Match(parts[i], directory); !ok {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+func write_zip_package(archive_path string, root string, files []package_file) {
+	archive_file, err := os.Create(archive_path)
+	if err != nil {
+		common.Log_fatal("failed to create", archive_path, "error:", err)
+	}
+	defer archive_file.Close()
+
+	var writer = zip.NewWriter(archive_file)
+	for _, file := range files {
+		header, err := zip.FileInfoHeader(file.info)
+		if err != nil {
+			common.Log_fatal("failed to create zip header for", file.path, "error:", err)
+		}
+		header.Name = root + "/" + file.name
+		header.Method = zip.Deflate
+
+		entry_writer, err := writer.CreateHeader(header)
+		if err != nil {
+			common.Log_fatal("failed to add", file.path, "to", archive_path, "error:", err)
+		}
+		write_package_file(entry_writer, file, archive_path)
+	}
+
+	err = writer.Close()
+	if err != nil {
+		common.Log_fatal("failed to write", archive_path, "error:", err)
+	}
+}
+
+func write_tar_package(archive_path string, root string, files []package_file, use_gzip bool) {
+	archive_file, err := os.Create(archive_path)
+	if err != nil {
+		common.Log_fatal("failed to create", archive_path, "error:", err)
+	}
+	defer archive_file.Close()
+
+	var output io.Writer = archive_file
+	var gzip_writer *gzip.Writer
+	if use_gzip {
+		gzip_writer = gzip.NewWriter(archive_file)
+		output = gzip_writer
+	}
+
+	var writer = tar.NewWriter(output)
+	for _, file := range files {
+		header, err := tar.FileInfoHeader(file.info, "")
+		if err != nil {
+			common.Log_fatal("failed to create tar header for", file.path, "error:", err)
+		}
+		header.Name = root + "/" + file.name
+		header.Uid, header.Gid, header.Uname, header.Gname = 0, 0, "", ""
+
+		err = writer.WriteHeader(header)
+		if err != nil {
+			common.Log_fatal("failed to add", file.path, "to", archive_path, "error:", err)
+		}
+		write_package_file(writer, file, archive_path)
+	}
+
+	err = writer.Close()
+	if err == nil && gzip_writer != nil {
+		err = gzip_writer.Close()
+	}
+	if err != nil {
+		common.Log_fatal("failed to write", archive_path, "error:", err)
+	}
+}
+
+// Writes a tar archive and compresses it using the "zstd" tool.
+func write_tar_zst_package(archive_path string, root string, files []package_file, zstd string) {
+	var tar_path = archive_path + ".tar"
+	write_tar_package(tar_path, root, files, false)
+	defer os.Remove(tar_path)
+
+	output, err := exec.Command(zstd, "-q", "-f", "-19", "-T0", tar_path, "-o", archive_path).CombinedOutput()
+	if err != nil {
+		os.Remove(tar_path)
+		common.Log_fatal("failed to compress", tar_path, "error:", err, "output:", strings.TrimSpace(string(output)))
+	}
+}
+
+// Writes "SHA256SUMS" (in format of `sha256sum`, paths are relative to the output directory)
+// and/or "<file>.sha256" files (if enabled in the config).
+func write_package_checksums(config *post_build_config, output_directory string, paths []string) {
+	if !config.package_checksums && !config.package_checksum_files {
+		return
+	}
+
+	var lines []string
+	for _, file_path := range paths {
+		var hash = get_file_sha256(file_path)
+
+		relative_path, err := filepath.Rel(output_directory, file_path)
+		if err != nil {
+			relative_path = file_path
+		}
+		lines = append(lines, hash+"  "+filepath.ToSlash(relative_path)+"\n")
+
+		if config.package_checksum_files {
+			write_text_file(file_path+".sha256", hash+"  "+filepath.Base(file_path)+"\n")
+		}
+	}
+
+	if config.package_checksums {
+		var checksums_path = filepath.Join(output_directory, package_checksums_file_name)
+		write_text_file(checksums_path, strings.Join(lines, ""))
+		common.Log_info("checksums of", len(paths), "file(-s) were written to", checksums_path)
+	}
+}
+
+func write_text_file(file_path string, content string) {
+	var err = os.WriteFile(file_path, []byte(content), 0644)
+	if err != nil {
+		common.Log_fatal("failed to write", file_path, "error:", err)
+	}
+}
+
+func find_zstd() string {
+	zstd, err := exec.LookPath("zstd")
+	if err != nil {
+		common.Log_fatal("\"zstd\" is required to create", package_format_tar_zst, "packages but it was not found in PATH")
+	}
+	return zstd
+}
+
+func write_package_file(writer io.Writer, file package_file, archive_path string) {
+	source, err := os.Open(file.path)
+	if err != nil {
+		common.Log_fatal("failed to open", file.path, "error:", err)
+	}
+	defer source.Close()
+
+	_, err = io.Copy(writer, source)
+	if err != nil {
+		common.Log_fatal("failed to add", file.path, "to", archive_path, "error:", err)
+	}
+}
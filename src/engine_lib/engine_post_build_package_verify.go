@@ -0,0 +1,59 @@
+package main
+
+import (
+	"common"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Copies packaged files to the staging directory and launches the executable from there
+// (with "smoke_test" settings) to make sure that no required file was excluded from the package.
+func verify_package_launch(config *post_build_config, build_directory string, binary_path string, files []package_file) {
+	if binary_path == "" {
+		common.Log_fatal("\"--binary\" is required to verify packages")
+	}
+
+	var executable_name = filepath.Base(binary_path)
+	var verify_directory = filepath.Join(build_directory, package_staging_directory_name, "verify")
+
+	// Start from scratch so that files from previous builds don't hide missing files.
+	remove_all(verify_directory)
+	defer remove_all(verify_directory)
+
+	var executable_path = ""
+	var has_res = false
+	var copies []file_copy
+	for _, file := range files {
+		var destination = filepath.Join(verify_directory, filepath.FromSlash(file.name))
+		make_directory(filepath.Dir(destination))
+		copies = append(copies, file_copy{src: file.path, dst: destination})
+
+		// The executable is located in "Contents/MacOS" of application bundles.
+		if file.name == executable_name || file.name == path.Join(path.Dir(file.name), "MacOS", executable_name) {
+			executable_path = destination
+		}
+		if strings.HasPrefix(file.name, "res/") || strings.Contains(file.name, "/res/") {
+			has_res = true
+		}
+	}
+
+	if executable_path == "" {
+		common.Log_fatal("package does not contain the executable", executable_name)
+	}
+	if !has_res {
+		common.Log_fatal("package does not contain the 'res' directory")
+	}
+
+	copy_files(copies)
+
+	common.Log_info("launching the packaged executable")
+
+	output, err := launch_binary(executable_path, config.smoke_test_args, config.smoke_test_timeout)
+	if err != nil {
+		print_smoke_test_output(output)
+		common.Log_fatal("packaged executable failed to run (was a required file excluded from the package?):", err)
+	}
+
+	common.Log_info("packaged executable runs")
+}
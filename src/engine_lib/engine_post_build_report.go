@@ -0,0 +1,150 @@
+package main
+
+import (
+	"common"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+// Machine-readable description of what the post build script did.
+type post_build_report struct {
+	StartedAt  string        `json:"started_at"`
+	DurationMs int64         `json:"duration_ms"`
+	TotalBytes int64         `json:"total_bytes"`
+	Steps      []step_report `json:"steps"`
+	Warnings   []string      `json:"warnings"`
+
+	start_time         time.Time
+	step_start_time    time.Time
+	current_step_index int // -1 if no step is running
+}
+
+type step_report struct {
+	Name       string        `json:"name"`
+	Status     string        `json:"status"`
+	DurationMs int64         `json:"duration_ms"`
+	Bytes      int64         `json:"bytes"`
+	Files      []file_report `json:"files"`
+	Warnings   []string      `json:"warnings"`
+}
+
+type file_report struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Bytes       int64  `json:"bytes"`
+}
+
+// Possible values of `step_report.Status`.
+const (
+	step_status_done       = "done"
+	step_status_up_to_date = "up_to_date"
+	step_status_skipped    = "skipped"
+)
+
+// Report of the current run (always collected, written only if requested).
+var current_report = post_build_report{
+	start_time:         time.Now(),
+	current_step_index: -1,
+	Steps:              []step_report{},
+	Warnings:           []string{},
+}
+
+// Marks the start of a new step.
+func report_begin_step(name string) {
+	current_report.step_start_time = time.Now()
+	current_report.Steps = append(current_report.Steps, step_report{
+		Name:     name,
+		Status:   step_status_done,
+		Files:    []file_report{},
+		Warnings: []string{},
+	})
+	current_report.current_step_index = len(current_report.Steps) - 1
+}
+
+// Marks the end of the current step.
+func report_end_step() {
+	var step = current_report.get_current_step()
+	if step == nil {
+		return
+	}
+	step.DurationMs = time.Since(current_report.step_start_time).Milliseconds()
+	current_report.current_step_index = -1
+}
+
+// Adds a step that was not executed.
+func report_skipped_step(name string) {
+	current_report.Steps = append(current_report.Steps, step_report{
+		Name:     name,
+		Status:   step_status_skipped,
+		Files:    []file_report{},
+		Warnings: []string{},
+	})
+}
+
+// Changes the status of the current step.
+func report_step_status(status string) {
+	var step = current_report.get_current_step()
+	if step != nil {
+		step.Status = status
+	}
+}
+
+// Used because files are copied from multiple goroutines.
+var report_mutex sync.Mutex
+
+// Records a copied (or downloaded) file.
+func report_file(source string, destination string, bytes int64) {
+	report_mutex.Lock()
+	defer report_mutex.Unlock()
+
+	current_report.TotalBytes += bytes
+
+	var step = current_report.get_current_step()
+	if step == nil {
+		return
+	}
+	step.Files = append(step.Files, file_report{Source: source, Destination: destination, Bytes: bytes})
+	step.Bytes += bytes
+}
+
+// Prints a warning and records it in the report.
+func report_warning(message string) {
+	common.Log_warning(message)
+
+	report_mutex.Lock()
+	defer report_mutex.Unlock()
+
+	var step = current_report.get_current_step()
+	if step != nil {
+		step.Warnings = append(step.Warnings, message)
+	} else {
+		current_report.Warnings = append(current_report.Warnings, message)
+	}
+}
+
+func (report *post_build_report) get_current_step() *step_report {
+	if report.current_step_index < 0 {
+		return nil
+	}
+	return &report.Steps[report.current_step_index]
+}
+
+// Writes the report of the current run to the specified file.
+func write_report(path string) {
+	current_report.StartedAt = current_report.start_time.Format(time.RFC3339)
+	current_report.DurationMs = time.Since(current_report.start_time).Milliseconds()
+
+	content, err := json.MarshalIndent(current_report, "", "    ")
+	if err != nil {
+		common.Log_fatal("failed to serialize report, error:", err)
+	}
+
+	err = os.WriteFile(path, content, 0644)
+	if err != nil {
+		common.Log_fatal("failed to write report", path, "error:", err)
+	}
+
+	common.Log_info("report was written to", path)
+}
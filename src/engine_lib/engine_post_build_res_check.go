@@ -0,0 +1,98 @@
+package main
+
+import (
+	"common"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Paths (relative to the 'res' directory) that should exist if not specified in the config.
+var default_res_required_paths = []string{"engine/shaders"}
+
+// Source files of assets that should not be stored in the 'res' directory if not specified in the config.
+var default_res_forbidden_patterns = []string{"*.psd", "*.blend", "*.blend1", "*.max", "*.ma", "*.mb"}
+
+// Maximum size of a file in the 'res' directory (in MiB) if not specified in the config.
+const default_res_max_file_size_mib = 100
+
+// Checks that the 'res' directory has required paths, has no forbidden files and no files
+// that exceed the maximum size.
+func check_res_directory(config *post_build_config, res_directory string) {
+	if _, err := os.Stat(res_directory); err != nil {
+		common.Log_fatal("res directory", res_directory, "does not exist")
+	}
+
+	var problems []string
+	for _, required_path := range config.res_check_required {
+		if _, err := os.Stat(filepath.Join(res_directory, filepath.FromSlash(required_path))); err != nil {
+			problems = append(problems, fmt.Sprintf("required path \"%s\" does not exist", required_path))
+		}
+	}
+
+	var max_file_size = config.res_check_max_file_size_mib * 1024 * 1024
+	var file_count = 0
+	var err = filepath.Walk(res_directory, func(file_path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relative_path, err := filepath.Rel(res_directory, file_path)
+		if err != nil || relative_path == "." {
+			return err
+		}
+		relative_path = filepath.ToSlash(relative_path)
+
+		if matches_res_pattern(relative_path, config.res_check_forbidden) {
+			problems = append(problems, fmt.Sprintf("\"%s\" is not allowed (see \"res_check.forbidden\" in the config)", relative_path))
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		file_count += 1
+		if max_file_size > 0 && info.Size() > max_file_size {
+			problems = append(problems, fmt.Sprintf("\"%s\" is too big: %s (maximum is %d MiB)",
+				relative_path, common.Format_byte_count(info.Size()), config.res_check_max_file_size_mib))
+		}
+		return nil
+	})
+	if err != nil {
+		common.Log_fatal("failed to read 'res' directory", res_directory, "error:", err)
+	}
+
+	for _, problem := range problems {
+		common.Log_error(problem)
+	}
+	if len(problems) != 0 {
+		common.Log_fatal("found", len(problems), "problem(s) in the 'res' directory", res_directory)
+	}
+
+	common.Log_success("checked", file_count, "file(s) in the 'res' directory")
+}
+
+// Tells if the file/directory (path relative to the 'res' directory) matches one of the patterns,
+// patterns without '/' are matched against the base name.
+func matches_res_pattern(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		var value = path.Base(name)
+		if strings.Contains(pattern, "/") {
+			value = name
+		}
+
+		// Extensions are matched case-insensitively ("Sketch.PSD").
+		matched, err := path.Match(strings.ToLower(pattern), strings.ToLower(value))
+		if err != nil {
+			common.Log_fatal("invalid pattern", "\""+pattern+"\"", "in \"res_check.forbidden\", error:", err)
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
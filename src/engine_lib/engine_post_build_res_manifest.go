@@ -0,0 +1,94 @@
+package main
+
+import (
+	"common"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Name of the file (in the build directory) with hashes of all files from the 'res' directory.
+const res_manifest_file_name = "res_manifest.json"
+
+// Manifest of the 'res' directory that the engine can use to detect corrupted or modified files.
+type res_manifest struct {
+	Files []res_manifest_entry `json:"files"`
+}
+
+type res_manifest_entry struct {
+	Path   string `json:"path"` // relative to the 'res' directory, uses '/' as separator
+	Size   int64  `json:"size"`
+	Sha256 string `json:"sha256"`
+}
+
+// Writes "res_manifest.json" with relative paths, sizes and SHA-256 of all files
+// from the 'res' directory of the build directory.
+func write_res_manifest(build_directory string, stamps *post_build_stamps) {
+	var manifest_path = filepath.Join(build_directory, res_manifest_file_name)
+
+	// 'res' is usually a symlink.
+	res_directory, err := filepath.EvalSymlinks(filepath.Join(build_directory, "res"))
+	if err != nil {
+		common.Log_fatal("failed to find 'res' directory in", build_directory, "error:", err)
+	}
+
+	var paths []string
+	err = filepath.Walk(res_directory, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		common.Log_fatal("failed to read 'res' directory", res_directory, "error:", err)
+	}
+	sort.Strings(paths)
+
+	var inputs = fingerprint_files(paths)
+	if stamps.is_up_to_date(step_res_manifest, inputs, []string{manifest_path}) {
+		common.Log_info("'res' manifest is up to date")
+		report_step_status(step_status_up_to_date)
+		return
+	}
+
+	if dry_run {
+		common.Log_info("[dry run] write manifest of", len(paths), "file(-s) to", manifest_path)
+		return
+	}
+
+	var manifest = res_manifest{Files: []res_manifest_entry{}}
+	for _, path := range paths {
+		relative_path, err := filepath.Rel(res_directory, path)
+		if err != nil {
+			common.Log_fatal("failed to get relative path of", path, "error:", err)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			common.Log_fatal("failed to read", path, "error:", err)
+		}
+
+		manifest.Files = append(manifest.Files, res_manifest_entry{
+			Path:   filepath.ToSlash(relative_path),
+			Size:   info.Size(),
+			Sha256: get_file_sha256(path),
+		})
+	}
+
+	content, err := json.MarshalIndent(manifest, "", "    ")
+	if err != nil {
+		common.Log_fatal("failed to serialize 'res' manifest, error:", err)
+	}
+
+	err = os.WriteFile(manifest_path, content, 0644)
+	if err != nil {
+		common.Log_fatal("failed to write", manifest_path, "error:", err)
+	}
+
+	stamps.update(step_res_manifest, inputs, []string{manifest_path})
+
+	common.Log_success("'res' manifest with", len(manifest.Files), "file(-s) was written to", manifest_path)
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"common"
+	"encoding/binary"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// Types and IDs of the resources that are written to the executable.
+const (
+	resource_type_icon       = 3
+	resource_type_group_icon = 14
+	resource_type_version    = 16
+
+	resource_id_version    = 1
+	resource_id_icon_group = 1
+
+	resource_language_en_us = 0x0409
+	resource_codepage_utf16 = 0x04B0
+)
+
+// A resource to write to the executable.
+type pe_resource struct {
+	resource_type uint16
+	id            uint16
+	data          []byte
+}
+
+// Embeds the icon and version information from the config into the executable (Windows only).
+func embed_executable_resources(config *post_build_config, binary_path string, stamps *post_build_stamps) {
+	if _, err := os.Stat(binary_path); os.IsNotExist(err) {
+		common.Log_fatal("binary", binary_path, "does not exist")
+	}
+
+	var icon_paths []string
+	if config.executable_icon != "" {
+		icon_paths = append(icon_paths, config.resolve_path(config.executable_icon))
+	}
+	var inputs = fingerprint_files(icon_paths, config.executable_product_name, config.executable_company_name,
+		config.executable_version, config.executable_description, config.executable_copyright)
+	if stamps.is_up_to_date(step_exe_resources, inputs, []string{binary_path}) {
+		common.Log_info("resources of", binary_path, "are up to date")
+		report_step_status(step_status_up_to_date)
+		return
+	}
+
+	var resources []pe_resource
+	if len(icon_paths) != 0 {
+		resources = append(resources, read_icon_resources(icon_paths[0])...)
+	}
+	if config.executable_version != "" {
+		resources = append(resources, pe_resource{
+			resource_type: resource_type_version,
+			id:            resource_id_version,
+			data:          build_version_info(config),
+		})
+	}
+	if len(resources) == 0 {
+		common.Log_info("no resources to embed")
+		return
+	}
+
+	if dry_run {
+		common.Log_info("[dry run] embed", len(resources), "resource(s) into", binary_path)
+		return
+	}
+
+	var err = update_pe_resources(binary_path, resources)
+	if err != nil {
+		common.Log_fatal("failed to update resources of", binary_path, "error:", err)
+	}
+
+	stamps.update(step_exe_resources, inputs, []string{binary_path})
+
+	common.Log_success("embedded icon/version information into", binary_path)
+}
+
+// Converts an .ico file to RT_ICON resources (one per image) and an RT_GROUP_ICON resource.
+func read_icon_resources(path string) []pe_resource {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		common.Log_fatal("failed to read icon", path, "error:", err)
+	}
+
+	// ICONDIR header: reserved, type (1 for icons), image count.
+	const header_size = 6
+	const entry_size = 16
+	if len(content) < header_size || binary.LittleEndian.Uint16(content[2:]) != 1 {
+		common.Log_fatal(path, "is not a valid .ico file")
+	}
+	var image_count = int(binary.LittleEndian.Uint16(content[4:]))
+	if image_count == 0 || len(content) < header_size+image_count*entry_size {
+		common.Log_fatal(path, "is not a valid .ico file")
+	}
+
+	var resources []pe_resource
+
+	// GRPICONDIR has the same header but its entries store resource IDs instead of file offsets.
+	var group bytes.Buffer
+	group.Write(content[:header_size])
+
+	for i := 0; i < image_count; i++ {
+		var entry = content[header_size+i*entry_size : header_size+(i+1)*entry_size]
+		var size = binary.LittleEndian.Uint32(entry[8:])
+		var offset = binary.LittleEndian.Uint32(entry[12:])
+		if uint64(offset)+uint64(size) > uint64(len(content)) {
+			common.Log_fatal(path, "is not a valid .ico file")
+		}
+
+		var id = uint16(i + 1)
+		resources = append(resources, pe_resource{
+			resource_type: resource_type_icon,
+			id:            id,
+			data:          content[offset : offset+size],
+		})
+
+		group.Write(entry[:12]) // size, colors, planes, bit count, bytes in resource
+		binary.Write(&group, binary.LittleEndian, id)
+	}
+
+	return append(resources, pe_resource{
+		resource_type: resource_type_group_icon,
+		id:            resource_id_icon_group,
+		data:          group.Bytes(),
+	})
+}
+
+// Builds VS_VERSIONINFO resource.
+func build_version_info(config *post_build_config) []byte {
+	var version = parse_file_version(config.executable_version)
+	var version_ms = uint32(version[0])<<16 | uint32(version[1])
+	var version_ls = uint32(version[2])<<16 | uint32(version[3])
+
+	var fixed_file_info bytes.Buffer
+	for _, value := range []uint32{
+		0xFEEF04BD,             // signature
+		0x00010000,             // structure version
+		version_ms, version_ls, // file version
+		version_ms, version_ls, // product version
+		0x3F,    // file flags mask
+		0,       // file flags
+		0x40004, // VOS_NT_WINDOWS32
+		1,       // VFT_APP
+		0,       // file subtype
+		0, 0,    // file date
+	} {
+		binary.Write(&fixed_file_info, binary.LittleEndian, value)
+	}
+
+	var version_text = strings.Join([]string{
+		strconv.Itoa(int(version[0])), strconv.Itoa(int(version[1])),
+		strconv.Itoa(int(version[2])), strconv.Itoa(int(version[3])),
+	}, ".")
+	var strings_to_write = [][2]string{
+		{"CompanyName", config.executable_company_name},
+		{"FileDescription", config.executable_description},
+		{"FileVersion", version_text},
+		{"LegalCopyright", config.executable_copyright},
+		{"ProductName", config.executable_product_name},
+		{"ProductVersion", config.executable_version},
+	}
+
+	var string_blocks [][]byte
+	for _, pair := range strings_to_write {
+		if pair[1] == "" {
+			continue
+		}
+		var value = encode_utf16_string(pair[1])
+		string_blocks = append(string_blocks, build_version_block(pair[0], 1, value, uint16(len(value)/2), nil))
+	}
+
+	var string_table = build_version_block("040904B0", 1, nil, 0, string_blocks)
+	var string_file_info = build_version_block("StringFileInfo", 1, nil, 0, [][]byte{string_table})
+
+	var translation = make([]byte, 4)
+	binary.LittleEndian.PutUint16(translation[0:], resource_language_en_us)
+	binary.LittleEndian.PutUint16(translation[2:], resource_codepage_utf16)
+	var var_block = build_version_block("Translation", 0, translation, uint16(len(translation)), nil)
+	var var_file_info = build_version_block("VarFileInfo", 1, nil, 0, [][]byte{var_block})
+
+	return build_version_block("VS_VERSION_INFO", 0, fixed_file_info.Bytes(), uint16(fixed_file_info.Len()),
+		[][]byte{string_file_info, var_file_info})
+}
+
+// Builds a block of the version resource: header (length, value length, type),
+// key, value and children (all aligned to 4 bytes).
+func build_version_block(key string, value_type uint16, value []byte, value_length uint16, children [][]byte) []byte {
+	var block bytes.Buffer
+	block.Write(make([]byte, 6)) // header is written at the end
+	block.Write(encode_utf16_string(key))
+
+	if len(value) != 0 {
+		pad_to_4_bytes(&block)
+		block.Write(value)
+	}
+	for _, child := range children {
+		pad_to_4_bytes(&block)
+		block.Write(child)
+	}
+
+	var result = block.Bytes()
+	binary.LittleEndian.PutUint16(result[0:], uint16(len(result)))
+	binary.LittleEndian.PutUint16(result[2:], value_length)
+	binary.LittleEndian.PutUint16(result[4:], value_type)
+	return result
+}
+
+func pad_to_4_bytes(buffer *bytes.Buffer) {
+	for buffer.Len()%4 != 0 {
+		buffer.WriteByte(0)
+	}
+}
+
+// Returns null-terminated UTF-16LE string.
+func encode_utf16_string(text string) []byte {
+	var result []byte
+	for _, char := range utf16.Encode([]rune(text + "\x00")) {
+		result = append(result, byte(char), byte(char>>8))
+	}
+	return result
+}
+
+// Parses version in form "1.2.3.4" (missing parts are zero).
+func parse_file_version(text string) [4]uint16 {
+	var version [4]uint16
+	var parts = strings.Split(text, ".")
+	if len(parts) > 4 {
+		common.Log_fatal("invalid executable version", text, "expected at most 4 numbers")
+	}
+
+	for i, part := range parts {
+		number, err := strconv.ParseUint(part, 10, 16)
+		if err != nil {
+			common.Log_fatal("invalid executable version", text, "error:", err)
+		}
+		version[i] = uint16(number)
+	}
+
+	return version
+}
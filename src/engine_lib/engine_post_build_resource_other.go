@@ -0,0 +1,10 @@
+//go:build !windows
+
+package main
+
+import "errors"
+
+// Writes resources to the executable (replacing existing resources with the same type/ID).
+func update_pe_resources(binary_path string, resources []pe_resource) error {
+	return errors.New("embedding resources is only supported on Windows")
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var kernel32 = syscall.NewLazyDLL("kernel32.dll")
+var begin_update_resource = kernel32.NewProc("BeginUpdateResourceW")
+var update_resource = kernel32.NewProc("UpdateResourceW")
+var end_update_resource = kernel32.NewProc("EndUpdateResourceW")
+
+// Writes resources to the executable (replacing existing resources with the same type/ID).
+func update_pe_resources(binary_path string, resources []pe_resource) error {
+	path, err := syscall.UTF16PtrFromString(binary_path)
+	if err != nil {
+		return err
+	}
+
+	handle, _, err := begin_update_resource.Call(uintptr(unsafe.Pointer(path)), 0)
+	if handle == 0 {
+		return err
+	}
+
+	for _, resource := range resources {
+		// Integer types/IDs are passed as MAKEINTRESOURCE values.
+		result, _, err := update_resource.Call(handle, uintptr(resource.resource_type), uintptr(resource.id),
+			resource_language_en_us, uintptr(unsafe.Pointer(&resource.data[0])), uintptr(len(resource.data)))
+		if result == 0 {
+			end_update_resource.Call(handle, 1) // discard changes
+			return err
+		}
+	}
+
+	result, _, err := end_update_resource.Call(handle, 0)
+	if result == 0 {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"common"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Software bill of materials in CycloneDX format.
+type cyclonedx_bom struct {
+	BomFormat    string                `json:"bomFormat"`
+	SpecVersion  string                `json:"specVersion"`
+	SerialNumber string                `json:"serialNumber"`
+	Version      int                   `json:"version"`
+	Metadata     cyclonedx_metadata    `json:"metadata"`
+	Components   []cyclonedx_component `json:"components"`
+}
+
+type cyclonedx_metadata struct {
+	Timestamp string              `json:"timestamp"`
+	Component cyclonedx_component `json:"component"`
+}
+
+type cyclonedx_component struct {
+	Type               string                   `json:"type"`
+	BomRef             string                   `json:"bom-ref,omitempty"`
+	Name               string                   `json:"name"`
+	Version            string                   `json:"version,omitempty"`
+	Licenses           []cyclonedx_license      `json:"licenses,omitempty"`
+	Hashes             []cyclonedx_hash         `json:"hashes,omitempty"`
+	ExternalReferences []cyclonedx_external_ref `json:"externalReferences,omitempty"`
+}
+
+type cyclonedx_license struct {
+	License struct {
+		Id   string `json:"id,omitempty"`
+		Name string `json:"name,omitempty"`
+	} `json:"license"`
+}
+
+type cyclonedx_hash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+type cyclonedx_external_ref struct {
+	Type string `json:"type"`
+	Url  string `json:"url"`
+}
+
+// Returns path to the SBOM that is written by `write_sbom`.
+func get_sbom_path(output_directory string, base_name string) string {
+	return filepath.Join(output_directory, base_name+"-"+get_package_platform()+".cdx.json")
+}
+
+// Writes CycloneDX SBOM that lists the engine, every dependency from the 'ext' directory
+// (with version, license, repository URL and commit) and redistributable packages.
+func write_sbom(config *post_build_config, ext_directory string, build_directory string, binary_path string,
+	output_directory string) string {
+	var base_name = get_package_base_name(config, binary_path)
+	var sbom_path = get_sbom_path(output_directory, base_name)
+	var submodule_urls = get_submodule_urls(ext_directory)
+
+	var dependencies = get_ext_dependencies(ext_directory, find_ext_licenses(ext_directory, ""))
+	check_license_policy(config, dependencies)
+
+	var engine = cyclonedx_component{Type: "framework", BomRef: "nameless-engine", Name: "nameless-engine",
+		Version: config.engine_version}
+	if commit, err := run_git(ext_directory, "rev-parse", "HEAD"); err == nil {
+		engine.Hashes = []cyclonedx_hash{{Alg: "SHA-1", Content: commit}}
+		if engine.Version == "" {
+			engine.Version = commit
+		}
+	}
+
+	var components = []cyclonedx_component{engine}
+	for _, dependency := range dependencies {
+		var component = cyclonedx_component{Type: "library", BomRef: dependency.name, Name: dependency.name,
+			Version: dependency.version}
+
+		var license cyclonedx_license
+		if dependency.license == spdx_unknown {
+			license.License.Name = "unknown"
+		} else {
+			license.License.Id = dependency.license
+		}
+		component.Licenses = []cyclonedx_license{license}
+
+		var directory = filepath.Join(ext_directory, dependency.name)
+		if _, err := os.Stat(filepath.Join(directory, ".git")); err == nil {
+			if commit, err := run_git(directory, "rev-parse", "HEAD"); err == nil {
+				component.Hashes = []cyclonedx_hash{{Alg: "SHA-1", Content: commit}}
+			}
+		}
+		if url, found := submodule_urls[dependency.name]; found {
+			component.ExternalReferences = []cyclonedx_external_ref{{Type: "vcs", Url: url}}
+		}
+
+		components = append(components, component)
+	}
+
+	redist_entries, _ := os.ReadDir(filepath.Join(build_directory, "redist"))
+	for _, entry := range redist_entries {
+		if !entry.Type().IsRegular() || strings.HasSuffix(entry.Name(), ".sha256") {
+			continue
+		}
+		components = append(components, cyclonedx_component{Type: "application", BomRef: entry.Name(), Name: entry.Name(),
+			Hashes: []cyclonedx_hash{{Alg: "SHA-256", Content: get_file_sha256(filepath.Join(build_directory, "redist", entry.Name()))}}})
+	}
+
+	var bom = cyclonedx_bom{
+		BomFormat:    "CycloneDX",
+		SpecVersion:  "1.5",
+		SerialNumber: "urn:uuid:" + new_uuid(),
+		Version:      1,
+		Metadata: cyclonedx_metadata{
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+			Component: cyclonedx_component{Type: "application", Name: get_package_name(config, binary_path),
+				Version: get_package_version(config)},
+		},
+		Components: components,
+	}
+
+	content, err := json.MarshalIndent(bom, "", "    ")
+	if err != nil {
+		common.Log_fatal("failed to serialize SBOM, error:", err)
+	}
+	write_text_file(sbom_path, string(content))
+
+	common.Log_info("SBOM with", len(components), "component(-s) was written to", sbom_path)
+
+	return sbom_path
+}
+
+// Returns URLs of git submodules that are located in the 'ext' directory by their directory names.
+func get_submodule_urls(ext_directory string) map[string]string {
+	var urls = map[string]string{}
+
+	repository_directory, err := run_git(ext_directory, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return urls
+	}
+
+	output, err := run_git(repository_directory, "config", "-f", ".gitmodules", "--get-regexp", `^submodule\..*\.(path|url)$`)
+	if err != nil {
+		return urls
+	}
+
+	// Lines are in form "submodule.<name>.path <value>".
+	var paths = map[string]string{}
+	var submodule_urls = map[string]string{}
+	for _, line := range strings.Split(output, "\n") {
+		var key, value, found = strings.Cut(strings.TrimSpace(line), " ")
+		if !found {
+			continue
+		}
+		if strings.HasSuffix(key, ".path") {
+			paths[strings.TrimSuffix(key, ".path")] = value
+		} else {
+			submodule_urls[strings.TrimSuffix(key, ".url")] = value
+		}
+	}
+
+	var ext_path, _ = filepath.Abs(ext_directory)
+	for name, path := range paths {
+		var submodule_path = filepath.Join(repository_directory, filepath.FromSlash(path))
+		if filepath.Dir(submodule_path) == ext_path {
+			urls[filepath.Base(submodule_path)] = submodule_urls[name]
+		}
+	}
+	return urls
+}
+
+// Returns a random (version 4) UUID.
+func new_uuid() string {
+	var bytes = make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		common.Log_fatal("failed to generate UUID, error:", err)
+	}
+	bytes[6] = (bytes[6] & 0x0f) | 0x40
+	bytes[8] = (bytes[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", bytes[0:4], bytes[4:6], bytes[6:8], bytes[8:10], bytes[10:])
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"common"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// Signs executables and libraries in the build directory using `signtool` (Windows)
+// or `osslsigncode` (other platforms). Files that already have a valid signature
+// (for example libraries from Microsoft) are not signed again.
+func sign_binaries(config *post_build_config, build_directory string, stamps *post_build_stamps) {
+	var certificate_path = os.Getenv(config.signing_certificate_env)
+	if certificate_path == "" {
+		report_warning("environment variable " + config.signing_certificate_env +
+			" is not set, binaries will not be signed")
+		report_step_status(step_status_skipped)
+		return
+	}
+	if _, err := os.Stat(certificate_path); err != nil {
+		common.Log_fatal("signing certificate", certificate_path, "does not exist")
+	}
+	var password = os.Getenv(config.signing_password_env)
+
+	var files = find_files_to_sign(config, build_directory)
+	if len(files) == 0 {
+		common.Log_info("no files to sign")
+		return
+	}
+
+	var inputs = fingerprint_files(nil, certificate_path, config.signing_tool, config.signing_timestamp_url)
+	if stamps.is_up_to_date(step_sign, inputs, files) {
+		common.Log_info("signed binaries are up to date")
+		report_step_status(step_status_up_to_date)
+		return
+	}
+
+	var tool = find_signing_tool(config.signing_tool)
+	common.Log_verbose("using signing tool", tool)
+
+	var signed_count = 0
+	for _, path := range files {
+		if dry_run {
+			common.Log_info("[dry run] sign", path)
+			continue
+		}
+
+		if is_signature_valid(config.signing_tool, tool, path) {
+			common.Log_verbose(path, "is already signed, skipping it")
+			continue
+		}
+
+		common.Log_info("signing", filepath.Base(path))
+		sign_file(config, tool, certificate_path, password, path)
+
+		if !is_signature_valid(config.signing_tool, tool, path) {
+			common.Log_fatal("signature of", path, "is not valid after signing")
+		}
+		signed_count += 1
+	}
+
+	stamps.update(step_sign, inputs, files)
+
+	common.Log_success("signed", signed_count, "file(s)")
+}
+
+// Returns files in the build directory that match signing patterns from the config.
+func find_files_to_sign(config *post_build_config, build_directory string) []string {
+	var files []string
+	var found = map[string]bool{}
+
+	for _, pattern := range config.signing_files {
+		matches, err := filepath.Glob(filepath.Join(build_directory, pattern))
+		if err != nil {
+			common.Log_fatal("invalid signing pattern", pattern, "error:", err)
+		}
+		for _, path := range matches {
+			if info, err := os.Stat(path); err != nil || info.IsDir() || found[path] {
+				continue
+			}
+			found[path] = true
+			files = append(files, path)
+		}
+	}
+
+	sort.Strings(files)
+	return files
+}
+
+// Returns path to the signing tool. `signtool` is also looked for in installed Windows SDKs.
+func find_signing_tool(name string) string {
+	if path, err := exec.LookPath(name); err == nil {
+		return path
+	}
+
+	if name == "signtool" && runtime.GOOS == "windows" {
+		var arch = "x64"
+		if target_arch == "arm64" {
+			arch = "arm64"
+		}
+		var pattern = filepath.Join(os.Getenv("ProgramFiles(x86)"), "Windows Kits", "10", "bin", "*", arch, "signtool.exe")
+		matches, _ := filepath.Glob(pattern)
+		if len(matches) != 0 {
+			// Use the latest SDK.
+			sort.Strings(matches)
+			return matches[len(matches)-1]
+		}
+	}
+
+	common.Log_fatal("signing tool", name, "was not found, make sure it's installed and added to PATH")
+	return ""
+}
+
+func sign_file(config *post_build_config, tool string, certificate_path string, password string, path string) {
+	var command *exec.Cmd
+
+	if config.signing_tool == "signtool" {
+		var args = []string{"sign", "/fd", "SHA256", "/f", certificate_path}
+		if password != "" {
+			args = append(args, "/p", password)
+		}
+		if config.signing_timestamp_url != "" {
+			args = append(args, "/tr", config.signing_timestamp_url, "/td", "SHA256")
+		}
+		command = exec.Command(tool, append(args, path)...)
+	} else {
+		var args = []string{"sign", "-pkcs12", certificate_path, "-h", "sha256"}
+		if password != "" {
+			args = append(args, "-pass", password)
+		}
+		if config.signing_timestamp_url != "" {
+			args = append(args, "-ts", config.signing_timestamp_url)
+		}
+		args = append(args, "-in", path, "-out", path+".signed")
+		command = exec.Command(tool, args...)
+	}
+
+	// Don't print the command because it might contain the password.
+	output, err := command.CombinedOutput()
+	if err != nil {
+		common.Log_fatal("failed to sign", path, "error:", err, "output:", strings.TrimSpace(string(output)))
+	}
+
+	if config.signing_tool != "signtool" {
+		err = os.Rename(path+".signed", path)
+		if err != nil {
+			common.Log_fatal("failed to replace", path, "with the signed file, error:", err)
+		}
+	}
+}
+
+// Tells if the file has a valid signature.
+func is_signature_valid(tool_name string, tool string, path string) bool {
+	var command *exec.Cmd
+	if tool_name == "signtool" {
+		command = exec.Command(tool, "verify", "/pa", path)
+	} else {
+		command = exec.Command(tool, "verify", "-in", path)
+	}
+
+	output, err := command.CombinedOutput()
+	if err != nil {
+		common.Log_verbose("signature check of", path, "failed:", strings.TrimSpace(string(output)))
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"common"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Name of the size history file in the build directory (if not specified in the config).
+const size_history_file_name = "size_history.json"
+
+// Name of the 'res' directory in the size history.
+const size_history_res_name = "res"
+
+// Sizes of a single build.
+type size_history_entry struct {
+	Timestamp string           `json:"timestamp"`
+	Commit    string           `json:"commit,omitempty"`
+	Sizes     map[string]int64 `json:"sizes"` // bytes by file name of the executable/library or "res"
+}
+
+// Measures sizes of the executable, shared libraries in the build directory and the 'res' directory,
+// appends them to the history file and compares them with the previous build.
+func track_build_size(config *post_build_config, repository_directory string, build_directory string,
+	binary_path string, stamps *post_build_stamps) {
+	var history_path = filepath.Join(build_directory, size_history_file_name)
+	if config.size_history != "" {
+		history_path = config.resolve_path(config.size_history)
+	}
+
+	var files = find_shared_libraries(build_directory)
+	if binary_path != "" {
+		files = append([]string{binary_path}, files...)
+	}
+
+	// 'res' is usually a symlink.
+	var res_files []string
+	res_directory, err := filepath.EvalSymlinks(filepath.Join(build_directory, "res"))
+	if err == nil {
+		filepath.Walk(res_directory, func(path string, info os.FileInfo, err error) error {
+			if err == nil && info.Mode().IsRegular() {
+				res_files = append(res_files, path)
+			}
+			return nil
+		})
+		sort.Strings(res_files)
+	}
+
+	// Sizes are only recorded if something was changed since the last build.
+	var inputs = fingerprint_files(append(append([]string{}, files...), res_files...), history_path)
+	if stamps.is_up_to_date(step_size, inputs, []string{history_path}) {
+		common.Log_info("sizes of the build were not changed")
+		report_step_status(step_status_up_to_date)
+		return
+	}
+
+	var entry = size_history_entry{Timestamp: time.Now().UTC().Format(time.RFC3339), Sizes: map[string]int64{}}
+	if commit, err := run_git(repository_directory, "rev-parse", "HEAD"); err == nil {
+		entry.Commit = commit
+	}
+	for _, path := range files {
+		info, err := os.Stat(path)
+		if err != nil {
+			common.Log_fatal("failed to get size of", path, "error:", err)
+		}
+		entry.Sizes[filepath.Base(path)] = info.Size()
+	}
+	if res_directory != "" {
+		entry.Sizes[size_history_res_name] = get_path_size(res_directory)
+	}
+
+	var history = read_size_history(history_path)
+	if len(history) != 0 {
+		compare_build_sizes(config, history[len(history)-1], entry)
+	} else {
+		common.Log_info("size history", history_path, "is empty, nothing to compare with")
+	}
+
+	var names []string
+	for name := range entry.Sizes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		common.Log_verbose(name+":", common.Format_byte_count(entry.Sizes[name]))
+	}
+
+	if dry_run {
+		common.Log_info("[dry run] append sizes to", history_path)
+		return
+	}
+
+	history = append(history, entry)
+	if config.size_max_entries > 0 && int64(len(history)) > config.size_max_entries {
+		history = history[int64(len(history))-config.size_max_entries:]
+	}
+	write_size_history(history_path, history)
+
+	stamps.update(step_size, inputs, []string{history_path})
+
+	common.Log_success("sizes of", len(entry.Sizes), "item(s) were added to", history_path)
+}
+
+// Returns shared libraries from the build directory.
+func find_shared_libraries(build_directory string) []string {
+	entries, err := os.ReadDir(build_directory)
+	if err != nil {
+		common.Log_fatal("failed to read build directory", build_directory, "error:", err)
+	}
+
+	var libraries []string
+	for _, entry := range entries {
+		// Skip symlinks such as "libfoo.so -> libfoo.so.1".
+		if !entry.Type().IsRegular() {
+			continue
+		}
+
+		var name = strings.ToLower(entry.Name())
+		if strings.HasSuffix(name, ".dll") || strings.HasSuffix(name, ".dylib") || strings.Contains(name, ".so") {
+			libraries = append(libraries, filepath.Join(build_directory, entry.Name()))
+		}
+	}
+
+	return libraries
+}
+
+// Reports items that grew by more than the configured percentage since the previous build.
+func compare_build_sizes(config *post_build_config, previous size_history_entry, current size_history_entry) {
+	var names []string
+	for name := range current.Sizes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var failed_items []string
+	for _, name := range names {
+		var size = current.Sizes[name]
+		previous_size, ok := previous.Sizes[name]
+		if !ok {
+			common.Log_info(name, "was added:", common.Format_byte_count(size))
+			continue
+		}
+		if previous_size == 0 || size <= previous_size {
+			continue
+		}
+
+		var growth = float64(size-previous_size) * 100 / float64(previous_size)
+		var message = fmt.Sprintf("%s grew by %.1f%% (%s -> %s)", name, growth,
+			common.Format_byte_count(previous_size), common.Format_byte_count(size))
+		if config.size_fail_percent > 0 && growth > float64(config.size_fail_percent) {
+			common.Log_error(message)
+			failed_items = append(failed_items, name)
+		} else if growth > float64(config.size_warn_percent) {
+			report_warning(message)
+		} else {
+			common.Log_verbose(message)
+		}
+	}
+
+	if len(failed_items) != 0 {
+		common.Log_fatal(strings.Join(failed_items, ", "), "grew by more than", fmt.Sprint(config.size_fail_percent)+"%",
+			"(see \"size.fail_percent\" in the config)")
+	}
+}
+
+// Reads the size history, returns an empty history if the file does not exist.
+func read_size_history(path string) []size_history_entry {
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		common.Log_fatal("failed to read size history", path, "error:", err)
+	}
+
+	var history []size_history_entry
+	err = json.Unmarshal(content, &history)
+	if err != nil {
+		common.Log_fatal("failed to parse size history", path, "error:", err)
+	}
+	return history
+}
+
+func write_size_history(path string, history []size_history_entry) {
+	content, err := json.MarshalIndent(history, "", "  ")
+	if err != nil {
+		common.Log_fatal("failed to serialize size history, error:", err)
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), os.ModePerm)
+	if err != nil {
+		common.Log_fatal("failed to create directory", filepath.Dir(path), "error:", err)
+	}
+	err = os.WriteFile(path, append(content, '\n'), 0644)
+	if err != nil {
+		common.Log_fatal("failed to write size history", path, "error:", err)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"common"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Maximum number of output lines of the binary to print if the smoke test fails.
+const smoke_test_output_line_count = 30
+
+// Starts the binary with arguments from the config and fails if it does not exit
+// successfully within the configured timeout (for example because of a missing library
+// or a broken 'res' directory).
+func run_smoke_test(config *post_build_config, binary_path string, stamps *post_build_stamps) {
+	if _, err := os.Stat(binary_path); os.IsNotExist(err) {
+		common.Log_fatal("binary", binary_path, "does not exist")
+	}
+
+	var inputs = fingerprint_files([]string{binary_path}, config.smoke_test_args...)
+	if stamps.is_up_to_date(step_smoke_test, inputs, nil) {
+		common.Log_info("smoke test already passed for this binary")
+		report_step_status(step_status_up_to_date)
+		return
+	}
+
+	if dry_run {
+		common.Log_info("[dry run] run", binary_path, strings.Join(config.smoke_test_args, " "))
+		return
+	}
+
+	var start_time = time.Now()
+	output, err := launch_binary(binary_path, config.smoke_test_args, config.smoke_test_timeout)
+	if err != nil {
+		print_smoke_test_output(output)
+		common.Log_fatal("smoke test failed:", err)
+	}
+
+	stamps.update(step_smoke_test, inputs, nil)
+
+	common.Log_success("smoke test passed in", time.Since(start_time).Round(time.Millisecond))
+}
+
+// Starts the binary (in its directory) and waits for it to exit successfully within
+// the timeout (in seconds). Returns the output of the binary.
+func launch_binary(binary_path string, args []string, timeout int64) (string, error) {
+	common.Log_info("running", filepath.Base(binary_path), strings.Join(args, " "))
+
+	var output bytes.Buffer
+	var command = exec.Command(binary_path, args...)
+	command.Dir = filepath.Dir(binary_path)
+	command.Stdout = &output
+	command.Stderr = &output
+
+	var err = command.Start()
+	if err != nil {
+		return "", err
+	}
+
+	var done = make(chan error, 1)
+	go func() {
+		done <- command.Wait()
+	}()
+
+	select {
+	case err = <-done:
+	case <-time.After(time.Duration(timeout) * time.Second):
+		command.Process.Kill()
+		<-done
+		err = fmt.Errorf("timed out after %d second(s)", timeout)
+	}
+
+	return output.String(), err
+}
+
+// Prints last lines of the output of the binary.
+func print_smoke_test_output(output string) {
+	if strings.TrimSpace(output) == "" {
+		return
+	}
+
+	var lines = strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) > smoke_test_output_line_count {
+		lines = lines[len(lines)-smoke_test_output_line_count:]
+	}
+
+	common.Log_error("last output of the binary:")
+	for _, line := range lines {
+		common.Log_error("    " + strings.TrimRight(line, "\r"))
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"common"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Identifier of licenses that were not recognized.
+const spdx_unknown = "unknown"
+
+// Licenses that are not allowed by default (copyleft licenses that require
+// releasing the source code of the game).
+var default_denied_licenses = []string{"GPL-2.0", "GPL-3.0", "AGPL-3.0"}
+
+// Phrases (lowercase, with collapsed whitespace) that identify a license, the first matching entry is used
+// so more specific licenses are listed first.
+var spdx_license_phrases = []struct {
+	id      string
+	phrases []string
+}{
+	{"AGPL-3.0", []string{"gnu affero general public license", "version 3"}},
+	{"LGPL-3.0", []string{"gnu lesser general public license", "version 3"}},
+	{"LGPL-2.1", []string{"gnu lesser general public license", "version 2.1"}},
+	{"GPL-3.0", []string{"gnu general public license", "version 3"}},
+	{"GPL-2.0", []string{"gnu general public license", "version 2"}},
+	{"MPL-2.0", []string{"mozilla public license", "2.0"}},
+	{"Apache-2.0", []string{"apache license", "version 2.0"}},
+	{"BSL-1.0", []string{"boost software license - version 1.0"}},
+	{"NCSA", []string{"university of illinois/ncsa open source license"}},
+	{"WTFPL", []string{"do what the fuck you want to public license"}},
+	{"Zlib", []string{"provided 'as-is', without any express or implied warranty", "altered source versions must be plainly marked"}},
+	{"BSD-3-Clause", []string{"redistribution and use in source and binary forms", "neither the name of"}},
+	{"BSD-2-Clause", []string{"redistribution and use in source and binary forms"}},
+	{"MIT", []string{"permission is hereby granted, free of charge"}},
+	{"ISC", []string{"permission to use, copy, modify, and/or distribute this software for any purpose"}},
+	{"Unlicense", []string{"this is free and unencumbered software released into the public domain"}},
+	{"CC0-1.0", []string{"cc0 1.0 universal"}},
+}
+
+var spdx_identifier_regexp = regexp.MustCompile(`SPDX-License-Identifier:\s*([A-Za-z0-9.+-]+)`)
+
+// Returns SPDX identifier of the license in the file (using "SPDX-License-Identifier" tag
+// or known phrases of the license text), `spdx_unknown` if the license was not recognized.
+func detect_spdx_license(path string) string {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		common.Log_fatal("failed to read license file", path, "error:", err)
+	}
+
+	if match := spdx_identifier_regexp.FindSubmatch(content); match != nil {
+		return string(match[1])
+	}
+
+	var text = strings.ToLower(strings.Join(strings.Fields(string(content)), " "))
+	for _, license := range spdx_license_phrases {
+		var matched = true
+		for _, phrase := range license.phrases {
+			if !strings.Contains(text, phrase) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return license.id
+		}
+	}
+
+	return spdx_unknown
+}
+
+// Returns license settings from the config.
+func (config *post_build_config) get_license_policy_settings() []string {
+	var settings = []string{strings.Join(config.licenses_deny, ","), strconv.FormatBool(config.licenses_allow_unknown)}
+	for name, license := range config.license_overrides {
+		settings = append(settings, name+"="+license)
+	}
+	sort.Strings(settings[2:])
+	return settings
+}
+
+// Detects licenses of dependencies and exits with an error if a license is denied by the config
+// (or is unknown). Licenses that can't be detected can be specified using "licenses.overrides".
+func check_license_policy(config *post_build_config, dependencies []ext_dependency) {
+	var violations []string
+	for i := range dependencies {
+		var dependency = &dependencies[i]
+
+		if license, found := config.license_overrides[dependency.name]; found {
+			dependency.license = license
+		} else {
+			dependency.license = detect_spdx_license(dependency.license_path)
+		}
+		common.Log_verbose("license of", dependency.name, "is", dependency.license)
+
+		if dependency.license == spdx_unknown && !config.licenses_allow_unknown {
+			violations = append(violations, dependency.name+" has unknown license "+
+				"(specify it in \"licenses.overrides\" of the config)")
+		} else if contains_string(config.licenses_deny, strings.TrimSuffix(strings.TrimSuffix(dependency.license, "-only"), "-or-later")) {
+			violations = append(violations, dependency.name+" uses denied license "+dependency.license)
+		}
+	}
+
+	if len(violations) != 0 {
+		common.Log_fatal("license check failed:\n  " + strings.Join(violations, "\n  "))
+	}
+}
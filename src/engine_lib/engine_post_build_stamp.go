@@ -0,0 +1,106 @@
+package main
+
+import (
+	"common"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Name of the file (in the build directory) that stores information about inputs/outputs
+// of the previously executed steps.
+const stamp_file_name = "post_build_stamp.json"
+
+// Stores fingerprints of inputs and outputs of executed steps so that steps
+// with unchanged inputs and outputs can be skipped.
+type post_build_stamps struct {
+	path  string
+	force bool
+
+	Steps map[string]step_stamp `json:"steps"`
+}
+
+type step_stamp struct {
+	Inputs  string `json:"inputs"`
+	Outputs string `json:"outputs"`
+}
+
+// Loads stamps from the build directory. If "force" is true all steps will be considered outdated.
+func load_post_build_stamps(build_directory string, force bool) *post_build_stamps {
+	var stamps = &post_build_stamps{
+		path:  filepath.Join(build_directory, stamp_file_name),
+		force: force,
+		Steps: map[string]step_stamp{},
+	}
+
+	content, err := os.ReadFile(stamps.path)
+	if err != nil {
+		return stamps
+	}
+
+	err = json.Unmarshal(content, stamps)
+	if err != nil || stamps.Steps == nil {
+		// Broken stamp file, just run everything.
+		report_warning("ignoring invalid stamp file " + stamps.path)
+		stamps.Steps = map[string]step_stamp{}
+	}
+
+	return stamps
+}
+
+// Tells if the step was already executed with the same inputs and its outputs were not changed since then.
+func (stamps *post_build_stamps) is_up_to_date(step string, inputs string, outputs []string) bool {
+	if stamps.force {
+		return false
+	}
+
+	var stamp, exists = stamps.Steps[step]
+	if !exists {
+		return false
+	}
+
+	return stamp.Inputs == inputs && stamp.Outputs == fingerprint_files(outputs)
+}
+
+// Remembers inputs/outputs of the executed step and saves the stamp file.
+func (stamps *post_build_stamps) update(step string, inputs string, outputs []string) {
+	if dry_run {
+		return
+	}
+
+	stamps.Steps[step] = step_stamp{Inputs: inputs, Outputs: fingerprint_files(outputs)}
+
+	content, err := json.MarshalIndent(stamps, "", "    ")
+	if err != nil {
+		common.Log_fatal("failed to serialize stamps, error:", err)
+	}
+
+	err = os.WriteFile(stamps.path, content, 0644)
+	if err != nil {
+		common.Log_fatal("failed to write stamp file", stamps.path, "error:", err)
+	}
+}
+
+// Returns a hash of paths, sizes and modification times of the specified files
+// and additional values (such as URLs or settings that affect the result).
+func fingerprint_files(paths []string, extra_values ...string) string {
+	var hasher = sha256.New()
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			fmt.Fprintf(hasher, "%s|missing\n", path)
+			continue
+		}
+		fmt.Fprintf(hasher, "%s|%d|%d\n", path, info.Size(), info.ModTime().UnixNano())
+	}
+
+	for _, value := range extra_values {
+		fmt.Fprintf(hasher, "%s\n", value)
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil))
+}
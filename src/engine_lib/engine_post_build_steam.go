@@ -0,0 +1,95 @@
+package main
+
+import (
+	"common"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+)
+
+// Name of the file that Steam API reads the app ID from when the game is not started by Steam.
+const steam_appid_file_name = "steam_appid.txt"
+
+// Copies Steam API library from the Steamworks SDK to the target directories and
+// creates "steam_appid.txt" in debug builds (in release builds the file is removed
+// from the build directory because it should not be shipped).
+func deploy_steam_api(config *post_build_config, target_directories []string, build_directory string,
+	is_release bool, stamps *post_build_stamps) {
+	var library_path = filepath.Join(config.resolve_path(config.steam_sdk_path), get_steam_api_library_path())
+	if _, err := os.Stat(library_path); os.IsNotExist(err) {
+		common.Log_fatal("Steam API library", library_path, "does not exist, check \"steam.sdk_path\" in the config")
+	}
+
+	var copies []file_copy
+	for _, directory := range target_directories {
+		copies = append(copies, file_copy{src: library_path, dst: filepath.Join(directory, filepath.Base(library_path))})
+	}
+
+	var outputs = get_copy_destinations(copies)
+	if !is_release {
+		for _, directory := range target_directories {
+			outputs = append(outputs, filepath.Join(directory, steam_appid_file_name))
+		}
+	}
+
+	var inputs = fingerprint_files([]string{library_path}, strconv.FormatInt(config.steam_app_id, 10),
+		strconv.FormatBool(is_release))
+	if stamps.is_up_to_date(step_steam, inputs, outputs) {
+		common.Log_info("Steam API is up to date")
+		report_step_status(step_status_up_to_date)
+		return
+	}
+
+	check_binaries_arch([]string{library_path})
+
+	for _, item := range copies {
+		copy(item.src, item.dst)
+	}
+
+	if is_release {
+		var appid_path = filepath.Join(build_directory, steam_appid_file_name)
+		if _, err := os.Stat(appid_path); err == nil {
+			common.Log_info("removing", appid_path, "from the release build")
+			remove_all(appid_path)
+		}
+	} else {
+		for _, directory := range target_directories {
+			write_steam_appid_file(filepath.Join(directory, steam_appid_file_name), config.steam_app_id)
+		}
+	}
+
+	stamps.update(step_steam, inputs, outputs)
+
+	common.Log_success("Steam API was deployed for app ID", config.steam_app_id)
+}
+
+// Returns path to the Steam API library relative to the Steamworks SDK directory.
+func get_steam_api_library_path() string {
+	switch runtime.GOOS {
+	case "windows":
+		if target_arch == "x86" {
+			return filepath.Join("redistributable_bin", "steam_api.dll")
+		}
+		return filepath.Join("redistributable_bin", "win64", "steam_api64.dll")
+	case "darwin":
+		return filepath.Join("redistributable_bin", "osx", "libsteam_api.dylib")
+	default:
+		if target_arch == "x86" {
+			return filepath.Join("redistributable_bin", "linux32", "libsteam_api.so")
+		}
+		return filepath.Join("redistributable_bin", "linux64", "libsteam_api.so")
+	}
+}
+
+func write_steam_appid_file(path string, app_id int64) {
+	if dry_run {
+		common.Log_info("[dry run] write", path)
+		return
+	}
+
+	var err = os.WriteFile(path, []byte(strconv.FormatInt(app_id, 10)), 0644)
+	if err != nil {
+		common.Log_fatal("failed to write", path, "error:", err)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"common"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Environment variables with credentials of the Steam account that uploads builds.
+const (
+	steam_username_env = "NE_STEAM_USERNAME"
+	steam_password_env = "NE_STEAM_PASSWORD"
+)
+
+// Name of the directory (in the package output directory) with Steam build scripts and content.
+const steam_depot_directory_name = "steam"
+
+// Returns path to the app build script that is created by `write_steam_build_scripts`.
+func get_steam_app_build_script_path(config *post_build_config, output_directory string) string {
+	return filepath.Join(output_directory, steam_depot_directory_name,
+		"app_build_"+strconv.FormatInt(config.get_steam_depot_app_id(), 10)+".vdf")
+}
+
+// Returns app ID from the "package.steam_depot" section or from the "steam" section.
+func (config *post_build_config) get_steam_depot_app_id() int64 {
+	if config.steam_depot_app_id != 0 {
+		return config.steam_depot_app_id
+	}
+	return config.steam_app_id
+}
+
+// Copies packaged files to the Steam content directory and writes app build and depot build
+// scripts for steamcmd (`+run_app_build`). Returns path to the app build script.
+func write_steam_build_scripts(config *post_build_config, output_directory string, base_name string,
+	files []package_file) string {
+	var app_id = strconv.FormatInt(config.get_steam_depot_app_id(), 10)
+	var depot_id = strconv.FormatInt(config.steam_depot_id, 10)
+	var steam_directory = filepath.Join(output_directory, steam_depot_directory_name)
+	var content_directory = filepath.Join(steam_directory, "content")
+	var app_build_path = get_steam_app_build_script_path(config, output_directory)
+
+	// steamcmd accepts '/' as path separator on all platforms.
+	var content_root = filepath.ToSlash(content_directory)
+
+	// Start from scratch so that removed files are not uploaded.
+	remove_all(content_directory)
+
+	var copies []file_copy
+	for _, file := range files {
+		var destination = filepath.Join(content_directory, filepath.FromSlash(file.name))
+		make_directory(filepath.Dir(destination))
+		copies = append(copies, file_copy{src: file.path, dst: destination})
+	}
+	copy_files(copies)
+
+	var description = config.steam_depot_description
+	if description == "" {
+		description = base_name
+	}
+
+	var app_build = []string{
+		"\"AppBuild\"",
+		"{",
+		"\t\"AppID\" " + quote_vdf_string(app_id),
+		"\t\"Desc\" " + quote_vdf_string(description),
+		"\t\"ContentRoot\" " + quote_vdf_string(content_root),
+		"\t\"BuildOutput\" " + quote_vdf_string(filepath.ToSlash(filepath.Join(steam_directory, "output"))),
+		"\t\"Preview\" \"0\"",
+	}
+	if config.steam_depot_branch != "" {
+		app_build = append(app_build, "\t\"SetLive\" "+quote_vdf_string(config.steam_depot_branch))
+	}
+	app_build = append(app_build,
+		"\t\"Depots\"",
+		"\t{",
+		"\t\t"+quote_vdf_string(depot_id)+" "+quote_vdf_string("depot_build_"+depot_id+".vdf"),
+		"\t}",
+		"}",
+		"")
+
+	var depot_build = []string{
+		"\"DepotBuild\"",
+		"{",
+		"\t\"DepotID\" " + quote_vdf_string(depot_id),
+		"\t\"ContentRoot\" " + quote_vdf_string(content_root),
+		"\t\"FileMapping\"",
+		"\t{",
+		"\t\t\"LocalPath\" \"*\"",
+		"\t\t\"DepotPath\" \".\"",
+		"\t\t\"Recursive\" \"1\"",
+		"\t}",
+		"}",
+		"",
+	}
+
+	write_text_file(app_build_path, strings.Join(app_build, "\n"))
+	write_text_file(filepath.Join(steam_directory, "depot_build_"+depot_id+".vdf"), strings.Join(depot_build, "\n"))
+
+	common.Log_info("Steam build scripts were written to", steam_directory)
+
+	return app_build_path
+}
+
+// Uploads the build using steamcmd.
+func upload_steam_build(config *post_build_config, app_build_path string) {
+	var username = os.Getenv(steam_username_env)
+	var password = os.Getenv(steam_password_env)
+	if username == "" || password == "" {
+		common.Log_fatal("uploading to Steam requires", steam_username_env, "and", steam_password_env, "environment variables")
+	}
+
+	steamcmd, err := exec.LookPath(config.steam_depot_steamcmd)
+	if err != nil {
+		common.Log_fatal("\"steamcmd\" was not found, specify \"package.steam_depot.steamcmd\" in the config, error:", err)
+	}
+
+	common.Log_info("uploading build to Steam, this might take a while")
+
+	// Don't use `run_binutils` to not print the password.
+	var command = exec.Command(steamcmd, "+login", username, password, "+run_app_build", app_build_path, "+quit")
+	output, err := command.CombinedOutput()
+	common.Log_verbose(strings.ReplaceAll(string(output), password, "***"))
+	if err != nil {
+		common.Log_fatal("failed to upload build to Steam using", app_build_path, "error:", err)
+	}
+
+	common.Log_success("build was uploaded to Steam depot", config.steam_depot_id)
+}
+
+// Returns a quoted VDF string.
+func quote_vdf_string(value string) string {
+	return "\"" + strings.ReplaceAll(value, "\"", "'") + "\""
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"common"
+	"debug/elf"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Name of the directory (in the build directory) with split debug information.
+// Should not be included in the game package.
+const symbols_directory_name = "symbols"
+
+// Moves debug information of the executable and shared libraries in the build directory
+// to "symbols/<name>.debug" files and strips the binaries (Linux only).
+// Stripped binaries reference their debug files using `.gnu_debuglink` so that debug
+// information can be loaded by debuggers/crash report tools (for example using
+// `set debug-file-directory` in gdb).
+func strip_binaries(binary_path string, build_directory string, stamps *post_build_stamps) {
+	var files = find_files_to_strip(binary_path, build_directory)
+	if len(files) == 0 {
+		common.Log_info("no binaries to strip")
+		return
+	}
+
+	var symbols_directory = filepath.Join(build_directory, symbols_directory_name)
+	var outputs = append([]string{}, files...)
+	for _, path := range files {
+		outputs = append(outputs, filepath.Join(symbols_directory, filepath.Base(path)+".debug"))
+	}
+
+	var inputs = fingerprint_files(nil, strings.Join(files, ","))
+	if stamps.is_up_to_date(step_strip, inputs, outputs) {
+		common.Log_info("stripped binaries are up to date")
+		report_step_status(step_status_up_to_date)
+		return
+	}
+
+	make_directory(symbols_directory)
+
+	var stripped_count = 0
+	for _, path := range files {
+		var debug_path = filepath.Join(symbols_directory, filepath.Base(path)+".debug")
+
+		if !has_debug_info(path) {
+			common.Log_verbose(path, "has no debug information, skipping it")
+			continue
+		}
+
+		if dry_run {
+			common.Log_info("[dry run] strip", path, "to", debug_path)
+			continue
+		}
+
+		common.Log_info("stripping", filepath.Base(path))
+
+		run_binutils("objcopy", "--only-keep-debug", path, debug_path)
+		run_binutils("objcopy", "--strip-debug", "--strip-unneeded", path)
+		run_binutils("objcopy", "--add-gnu-debuglink="+debug_path, path)
+
+		stripped_count += 1
+	}
+
+	stamps.update(step_strip, inputs, outputs)
+
+	common.Log_success("stripped", stripped_count, "binary(-ies), debug information is in", symbols_directory)
+}
+
+// Returns the executable (if specified) and shared libraries from the build directory.
+func find_files_to_strip(binary_path string, build_directory string) []string {
+	var files []string
+	if binary_path != "" {
+		files = append(files, binary_path)
+	}
+
+	entries, err := os.ReadDir(build_directory)
+	if err != nil {
+		common.Log_fatal("failed to read build directory", build_directory, "error:", err)
+	}
+
+	for _, entry := range entries {
+		// Skip symlinks such as "libfoo.so -> libfoo.so.1".
+		if !entry.Type().IsRegular() || !strings.Contains(entry.Name(), ".so") {
+			continue
+		}
+
+		var path = filepath.Join(build_directory, entry.Name())
+		if path == binary_path || !is_elf_file(path) {
+			continue
+		}
+		files = append(files, path)
+	}
+
+	return files
+}
+
+func is_elf_file(path string) bool {
+	file, err := elf.Open(path)
+	if err != nil {
+		return false
+	}
+	file.Close()
+	return true
+}
+
+// Tells if the ELF file has DWARF debug information.
+func has_debug_info(path string) bool {
+	file, err := elf.Open(path)
+	if err != nil {
+		common.Log_fatal("failed to read", path, "error:", err)
+	}
+	defer file.Close()
+
+	return file.Section(".debug_info") != nil
+}
+
+func run_binutils(tool string, args ...string) {
+	common.Log_verbose("running", tool, strings.Join(args, " "))
+
+	output, err := exec.Command(tool, args...).CombinedOutput()
+	if err != nil {
+		common.Log_fatal("failed to run", tool, strings.Join(args, " "), "error:", err,
+			"output:", strings.TrimSpace(string(output)))
+	}
+}
@@ -0,0 +1,404 @@
+package main
+
+import (
+	"bytes"
+	"common"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Extensions of image files that are checked (other files in the 'res' directory are ignored).
+var texture_extensions = []string{"png", "jpg", "jpeg", "gif", "bmp", "tga", "psd", "hdr", "exr", "webp", "tif", "tiff", "dds", "ktx", "ktx2"}
+
+// Formats that are allowed if not specified in the config: formats supported by stb_image
+// and containers of compressed textures.
+var default_texture_formats = []string{"png", "jpg", "gif", "bmp", "tga", "hdr", "dds", "ktx2"}
+
+// Colorspaces of textures that can be required in the config.
+const (
+	texture_colorspace_srgb   = "srgb"
+	texture_colorspace_linear = "linear"
+)
+
+// Information about an image file that is read from its header.
+type texture_info struct {
+	width        int    // 0 if unknown
+	height       int    // 0 if unknown
+	block_width  int    // 0 if the texture is not block-compressed
+	block_height int    // 0 if the texture is not block-compressed
+	colorspace   string // empty if the file has no colorspace information
+}
+
+// Checks image files in the 'res' directory using rules from the config: allowed formats,
+// dimensions and colorspace.
+func check_textures(config *post_build_config, res_directory string) {
+	var files []string
+	var err = filepath.Walk(res_directory, func(file_path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if file_path != res_directory && strings.HasPrefix(info.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if contains_string(texture_extensions, get_texture_format(file_path)) {
+			files = append(files, file_path)
+		}
+		return nil
+	})
+	if err != nil {
+		common.Log_fatal("failed to read 'res' directory", res_directory, "error:", err)
+	}
+	sort.Strings(files)
+
+	var problems []string
+	for _, file_path := range files {
+		relative_path, err := filepath.Rel(res_directory, file_path)
+		if err != nil {
+			common.Log_fatal("failed to get relative path of", file_path, "error:", err)
+		}
+		relative_path = filepath.ToSlash(relative_path)
+
+		var rule = find_texture_rule(config.texture_rules, relative_path)
+		for _, problem := range check_texture(file_path, rule) {
+			problems = append(problems, relative_path+": "+problem)
+		}
+	}
+
+	for _, problem := range problems {
+		common.Log_error(problem)
+	}
+	if len(problems) != 0 {
+		common.Log_fatal("found", len(problems), "problem(s) in textures of the 'res' directory", res_directory)
+	}
+
+	common.Log_success("checked", len(files), "texture(s) in the 'res' directory")
+}
+
+// Returns lowercase extension of the file without the dot ("jpeg" is returned as "jpg").
+func get_texture_format(file_path string) string {
+	var format = strings.TrimPrefix(strings.ToLower(filepath.Ext(file_path)), ".")
+	if format == "jpeg" {
+		return "jpg"
+	}
+	return format
+}
+
+// Returns the rule with the longest directory that contains the file (path relative to the 'res' directory),
+// the first rule (with an empty directory) has default settings.
+func find_texture_rule(rules []config_texture_rule, relative_path string) config_texture_rule {
+	var result = rules[0]
+	for _, rule := range rules[1:] {
+		if strings.HasPrefix(relative_path, rule.directory+"/") && len(rule.directory) >= len(result.directory) {
+			result = rule
+		}
+	}
+	return result
+}
+
+// Returns the directory of the rule in the form that is used by find_texture_rule and find_audio_rule.
+func clean_rule_directory(directory string) string {
+	return strings.Trim(path.Clean(filepath.ToSlash(directory)), "/")
+}
+
+// Checks the image file using the rule, returns found problems.
+func check_texture(file_path string, rule config_texture_rule) []string {
+	var format = get_texture_format(file_path)
+	if !contains_string(rule.formats, format) {
+		return []string{fmt.Sprintf("format \"%s\" is not allowed, expected one of: %s", format, strings.Join(rule.formats, ", "))}
+	}
+
+	info, err := read_texture_info(file_path, format)
+	if err != nil {
+		return []string{"failed to read the image header: " + err.Error()}
+	}
+
+	var problems []string
+	if info.width == 0 || info.height == 0 {
+		common.Log_verbose("size of", file_path, "is unknown, only its format and colorspace are checked")
+	} else {
+		if rule.max_size > 0 && (int64(info.width) > rule.max_size || int64(info.height) > rule.max_size) {
+			problems = append(problems, fmt.Sprintf("size %dx%d exceeds the maximum of %d", info.width, info.height, rule.max_size))
+		}
+		if rule.power_of_two && (!is_power_of_two(info.width) || !is_power_of_two(info.height)) {
+			problems = append(problems, fmt.Sprintf("size %dx%d is not a power of two", info.width, info.height))
+		}
+		if info.block_width != 0 && (info.width%info.block_width != 0 || info.height%info.block_height != 0) {
+			problems = append(problems, fmt.Sprintf("size %dx%d is not a multiple of the compression block size %dx%d",
+				info.width, info.height, info.block_width, info.block_height))
+		}
+	}
+
+	if rule.colorspace != "" {
+		if info.colorspace == "" {
+			report_warning(fmt.Sprintf("%s has no colorspace information, expected %s", file_path, rule.colorspace))
+		} else if info.colorspace != rule.colorspace {
+			problems = append(problems, fmt.Sprintf("colorspace is %s but %s is expected", info.colorspace, rule.colorspace))
+		}
+	}
+
+	return problems
+}
+
+func is_power_of_two(value int) bool {
+	return value > 0 && value&(value-1) == 0
+}
+
+// Reads size, compression and colorspace of the image file from its header.
+func read_texture_info(file_path string, format string) (texture_info, error) {
+	file, err := os.Open(file_path)
+	if err != nil {
+		return texture_info{}, err
+	}
+	defer file.Close()
+
+	// Headers of all supported formats (and PNG chunks before the image data) are usually small.
+	var header = make([]byte, 64*1024)
+	count, err := io.ReadFull(file, header)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return texture_info{}, err
+	}
+	header = header[:count]
+
+	switch format {
+	case "png":
+		return read_png_info(header)
+	case "jpg", "gif":
+		image_config, _, err := image.DecodeConfig(bytes.NewReader(header))
+		if err != nil {
+			return texture_info{}, err
+		}
+		return texture_info{width: image_config.Width, height: image_config.Height}, nil
+	case "bmp":
+		if len(header) < 26 || string(header[:2]) != "BM" {
+			return texture_info{}, errors.New("not a BMP file")
+		}
+		var height = int(int32(binary.LittleEndian.Uint32(header[22:])))
+		if height < 0 {
+			height = -height // top-down image
+		}
+		return texture_info{width: int(int32(binary.LittleEndian.Uint32(header[18:]))), height: height}, nil
+	case "tga":
+		if len(header) < 18 {
+			return texture_info{}, errors.New("not a TGA file")
+		}
+		return texture_info{width: int(binary.LittleEndian.Uint16(header[12:])), height: int(binary.LittleEndian.Uint16(header[14:]))}, nil
+	case "hdr":
+		return read_hdr_info(header)
+	case "exr":
+		// Values are always linear.
+		return texture_info{colorspace: texture_colorspace_linear}, nil
+	case "dds":
+		return read_dds_info(header)
+	case "ktx2":
+		return read_ktx2_info(header)
+	}
+
+	return texture_info{}, nil
+}
+
+// Reads IHDR and colorspace chunks of a PNG file.
+func read_png_info(header []byte) (texture_info, error) {
+	if len(header) < 33 || string(header[:8]) != "\x89PNG\r\n\x1a\n" || string(header[12:16]) != "IHDR" {
+		return texture_info{}, errors.New("not a PNG file")
+	}
+
+	var info = texture_info{
+		width:  int(binary.BigEndian.Uint32(header[16:])),
+		height: int(binary.BigEndian.Uint32(header[20:])),
+	}
+
+	// Chunks that describe the colorspace come before the image data.
+	for offset := 8; offset+8 <= len(header); {
+		var length = int(binary.BigEndian.Uint32(header[offset:]))
+		var chunk_type = string(header[offset+4 : offset+8])
+		if chunk_type == "IDAT" || chunk_type == "IEND" {
+			break
+		}
+
+		switch chunk_type {
+		case "sRGB", "iCCP":
+			// ICC profiles are almost always sRGB-like.
+			info.colorspace = texture_colorspace_srgb
+		case "gAMA":
+			// Gamma 1.0 (stored as 100000) means linear values.
+			if info.colorspace == "" && offset+12 <= len(header) {
+				if binary.BigEndian.Uint32(header[offset+8:]) == 100000 {
+					info.colorspace = texture_colorspace_linear
+				} else {
+					info.colorspace = texture_colorspace_srgb
+				}
+			}
+		}
+
+		offset += 12 + length // length, type, data and CRC
+	}
+
+	return info, nil
+}
+
+// Reads the resolution line of a Radiance HDR file ("-Y 512 +X 1024").
+func read_hdr_info(header []byte) (texture_info, error) {
+	if !bytes.HasPrefix(header, []byte("#?")) {
+		return texture_info{}, errors.New("not a Radiance HDR file")
+	}
+
+	for _, line := range strings.Split(string(header), "\n") {
+		var fields = strings.Fields(line)
+		if len(fields) != 4 || (fields[0] != "-Y" && fields[0] != "+Y") {
+			continue
+		}
+
+		height, height_err := strconv.Atoi(fields[1])
+		width, width_err := strconv.Atoi(fields[3])
+		if height_err != nil || width_err != nil {
+			break
+		}
+		return texture_info{width: width, height: height, colorspace: texture_colorspace_linear}, nil
+	}
+
+	return texture_info{}, errors.New("resolution of the Radiance HDR file was not found")
+}
+
+// Colorspaces of block-compressed DXGI formats (BC1-BC7), empty for typeless formats.
+var dxgi_compressed_formats = map[uint32]string{
+	70: "", 71: texture_colorspace_linear, 72: texture_colorspace_srgb, // BC1
+	73: "", 74: texture_colorspace_linear, 75: texture_colorspace_srgb, // BC2
+	76: "", 77: texture_colorspace_linear, 78: texture_colorspace_srgb, // BC3
+	79: "", 80: texture_colorspace_linear, 81: texture_colorspace_linear, // BC4
+	82: "", 83: texture_colorspace_linear, 84: texture_colorspace_linear, // BC5
+	94: "", 95: texture_colorspace_linear, 96: texture_colorspace_linear, // BC6H
+	97: "", 98: texture_colorspace_linear, 99: texture_colorspace_srgb, // BC7
+}
+
+// Colorspaces of uncompressed DXGI formats that have sRGB variants, empty for typeless formats.
+var dxgi_color_formats = map[uint32]string{
+	27: "", 28: texture_colorspace_linear, 29: texture_colorspace_srgb, // R8G8B8A8
+	90: "", 87: texture_colorspace_linear, 91: texture_colorspace_srgb, // B8G8R8A8
+	92: "", 88: texture_colorspace_linear, 93: texture_colorspace_srgb, // B8G8R8X8
+}
+
+// Reads a DDS header (and the DX10 header if present).
+func read_dds_info(header []byte) (texture_info, error) {
+	if len(header) < 128 || string(header[:4]) != "DDS " {
+		return texture_info{}, errors.New("not a DDS file")
+	}
+
+	var info = texture_info{
+		height: int(binary.LittleEndian.Uint32(header[12:])),
+		width:  int(binary.LittleEndian.Uint32(header[16:])),
+	}
+
+	const pixel_format_fourcc = 0x4
+	var pixel_format_flags = binary.LittleEndian.Uint32(header[80:])
+	if pixel_format_flags&pixel_format_fourcc == 0 {
+		// Uncompressed data without colorspace information.
+		return info, nil
+	}
+
+	var fourcc = string(header[84:88])
+	if fourcc != "DX10" {
+		switch fourcc {
+		case "DXT1", "DXT2", "DXT3", "DXT4", "DXT5", "ATI1", "ATI2", "BC4U", "BC4S", "BC5U", "BC5S":
+			info.block_width, info.block_height = 4, 4
+		}
+		return info, nil
+	}
+
+	if len(header) < 148 {
+		return texture_info{}, errors.New("DX10 header of the DDS file is missing")
+	}
+	var dxgi_format = binary.LittleEndian.Uint32(header[128:])
+	if colorspace, ok := dxgi_compressed_formats[dxgi_format]; ok {
+		info.block_width, info.block_height = 4, 4
+		info.colorspace = colorspace
+	} else if colorspace, ok := dxgi_color_formats[dxgi_format]; ok {
+		info.colorspace = colorspace
+	}
+
+	return info, nil
+}
+
+// Vulkan formats that have sRGB variants, block sizes are 0 for uncompressed formats.
+var vulkan_color_formats = []struct {
+	unorm        uint32
+	srgb         uint32
+	block_width  int
+	block_height int
+}{
+	{37, 43, 0, 0}, {44, 50, 0, 0}, // R8G8B8A8, B8G8R8A8
+	{131, 132, 4, 4}, {133, 134, 4, 4}, {135, 136, 4, 4}, {137, 138, 4, 4}, {145, 146, 4, 4}, // BC1 RGB, BC1 RGBA, BC2, BC3, BC7
+	{147, 148, 4, 4}, {149, 150, 4, 4}, {151, 152, 4, 4}, // ETC2
+	{157, 158, 4, 4}, {159, 160, 5, 4}, {161, 162, 5, 5}, {163, 164, 6, 5}, {165, 166, 6, 6}, {167, 168, 8, 5}, // ASTC
+	{169, 170, 8, 6}, {171, 172, 8, 8}, {173, 174, 10, 5}, {175, 176, 10, 6}, {177, 178, 10, 8}, {179, 180, 10, 10},
+	{181, 182, 12, 10}, {183, 184, 12, 12},
+}
+
+// Block-compressed Vulkan formats without sRGB variants (BC4, BC5, BC6H, EAC).
+var vulkan_linear_compressed_formats = []uint32{139, 140, 141, 142, 143, 144, 153, 154, 155, 156}
+
+// Reads a KTX2 header and the transfer function from the data format descriptor.
+func read_ktx2_info(header []byte) (texture_info, error) {
+	if len(header) < 80 || string(header[:12]) != "\xabKTX 20\xbb\r\n\x1a\n" {
+		return texture_info{}, errors.New("not a KTX2 file")
+	}
+
+	var info = texture_info{
+		width:  int(binary.LittleEndian.Uint32(header[20:])),
+		height: int(binary.LittleEndian.Uint32(header[24:])),
+	}
+
+	var vk_format = binary.LittleEndian.Uint32(header[12:])
+	for _, format := range vulkan_color_formats {
+		if vk_format == format.unorm || vk_format == format.srgb {
+			info.block_width, info.block_height = format.block_width, format.block_height
+			info.colorspace = texture_colorspace_linear
+			if vk_format == format.srgb {
+				info.colorspace = texture_colorspace_srgb
+			}
+			return info, nil
+		}
+	}
+	for _, format := range vulkan_linear_compressed_formats {
+		if vk_format == format {
+			info.block_width, info.block_height = 4, 4
+			info.colorspace = texture_colorspace_linear
+			return info, nil
+		}
+	}
+
+	if vk_format != 0 {
+		return info, nil
+	}
+
+	// Basis Universal textures (VK_FORMAT_UNDEFINED) are transcoded to 4x4 blocks,
+	// the colorspace is stored in the data format descriptor.
+	info.block_width, info.block_height = 4, 4
+	const transfer_function_linear = 1
+	const transfer_function_srgb = 2
+	var dfd_offset = int(binary.LittleEndian.Uint32(header[48:]))
+	if dfd_offset+16 <= len(header) {
+		// Total size (4 bytes), descriptor type and version (8 bytes), color model, primaries, transfer function.
+		switch header[dfd_offset+4+10] {
+		case transfer_function_linear:
+			info.colorspace = texture_colorspace_linear
+		case transfer_function_srgb:
+			info.colorspace = texture_colorspace_srgb
+		}
+	}
+
+	return info, nil
+}
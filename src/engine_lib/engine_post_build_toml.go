@@ -0,0 +1,561 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Minimal TOML parser used to read post build configuration files.
+//
+// Supports tables, arrays of tables, dotted keys, basic and literal strings, integers,
+// floats, booleans, (multi-line) arrays and inline tables. Multi-line strings and
+// date/time values are not supported.
+//
+// Tables are returned as `map[string]interface{}`, arrays as `[]interface{}`,
+// integers as `int64`, floats as `float64`.
+
+type toml_parser struct {
+	text string
+	pos  int
+	line int
+
+	// Paths (see "toml_get_or_create_table") of tables defined by "[table]" headers.
+	defined_tables map[string]bool
+	// Paths of tables and arrays defined by key/value pairs (they can't be extended by headers).
+	inline_values map[string]bool
+}
+
+func parse_toml(text string) (map[string]interface{}, error) {
+	var parser = toml_parser{text: text, pos: 0, line: 1, defined_tables: map[string]bool{}, inline_values: map[string]bool{}}
+	var root = map[string]interface{}{}
+	var current_table = root
+	var current_path = ""
+
+	for {
+		parser.skip_whitespace_and_comments(true)
+		if parser.is_eof() {
+			break
+		}
+
+		if parser.peek() == '[' {
+			var is_array_of_tables = strings.HasPrefix(parser.text[parser.pos:], "[[")
+			if is_array_of_tables {
+				parser.pos += 2
+			} else {
+				parser.pos += 1
+			}
+
+			parser.skip_whitespace_and_comments(false)
+			keys, err := parser.parse_key()
+			if err != nil {
+				return nil, err
+			}
+			parser.skip_whitespace_and_comments(false)
+
+			var closing = "]"
+			if is_array_of_tables {
+				closing = "]]"
+			}
+			if !strings.HasPrefix(parser.text[parser.pos:], closing) {
+				return nil, parser.error("expected \"" + closing + "\"")
+			}
+			parser.pos += len(closing)
+
+			if is_array_of_tables {
+				current_table, current_path, err = toml_append_array_table(root, keys)
+			} else {
+				current_table, current_path, err = toml_get_or_create_table(root, keys)
+			}
+			if err != nil {
+				return nil, parser.error(err.Error())
+			}
+
+			var header = strings.Repeat("[", len(closing)) + strings.Join(keys, ".") + closing
+			for path := range parser.inline_values {
+				if current_path == path || strings.HasPrefix(current_path, path+".") || strings.HasPrefix(current_path, path+"[") {
+					return nil, parser.error("header " + header + " extends a value that is defined by a key/value pair")
+				}
+			}
+			if !is_array_of_tables {
+				if parser.defined_tables[current_path] {
+					return nil, parser.error("table " + header + " is defined more than once")
+				}
+				parser.defined_tables[current_path] = true
+			}
+		} else {
+			keys, value, err := parser.parse_key_value(current_table)
+			if err != nil {
+				return nil, err
+			}
+
+			switch value.(type) {
+			case map[string]interface{}, []interface{}:
+				parser.inline_values[toml_join_path(current_path, keys)] = true
+			}
+		}
+
+		// Expect end of line.
+		parser.skip_whitespace_and_comments(false)
+		if !parser.is_eof() && parser.peek() != '\n' && parser.peek() != '\r' {
+			return nil, parser.error("expected end of line")
+		}
+	}
+
+	return root, nil
+}
+
+// Reads and parses the specified TOML file.
+func parse_toml_file(path string) (map[string]interface{}, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	table, err := parse_toml(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return table, nil
+}
+
+func (parser *toml_parser) is_eof() bool {
+	return parser.pos >= len(parser.text)
+}
+
+func (parser *toml_parser) peek() byte {
+	return parser.text[parser.pos]
+}
+
+func (parser *toml_parser) error(message string) error {
+	return fmt.Errorf("line %d: %s", parser.line, message)
+}
+
+func (parser *toml_parser) skip_whitespace_and_comments(skip_newlines bool) {
+	for !parser.is_eof() {
+		var char = parser.peek()
+		if char == ' ' || char == '\t' {
+			parser.pos += 1
+		} else if char == '#' {
+			for !parser.is_eof() && parser.peek() != '\n' {
+				parser.pos += 1
+			}
+		} else if skip_newlines && (char == '\n' || char == '\r') {
+			if char == '\n' {
+				parser.line += 1
+			}
+			parser.pos += 1
+		} else {
+			break
+		}
+	}
+}
+
+func is_bare_key_char(char byte) bool {
+	return (char >= 'a' && char <= 'z') || (char >= 'A' && char <= 'Z') || (char >= '0' && char <= '9') ||
+		char == '_' || char == '-'
+}
+
+// Parses a (possibly dotted) key.
+func (parser *toml_parser) parse_key() ([]string, error) {
+	var keys []string
+
+	for {
+		parser.skip_whitespace_and_comments(false)
+		if parser.is_eof() {
+			return nil, parser.error("expected a key")
+		}
+
+		var char = parser.peek()
+		if char == '"' || char == '\'' {
+			key, err := parser.parse_string()
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, key)
+		} else {
+			var start = parser.pos
+			for !parser.is_eof() && is_bare_key_char(parser.peek()) {
+				parser.pos += 1
+			}
+			if start == parser.pos {
+				return nil, parser.error("expected a key")
+			}
+			keys = append(keys, parser.text[start:parser.pos])
+		}
+
+		parser.skip_whitespace_and_comments(false)
+		if !parser.is_eof() && parser.peek() == '.' {
+			parser.pos += 1
+			continue
+		}
+
+		return keys, nil
+	}
+}
+
+// Parses a key/value pair and adds the value to the table, returns the (dotted) key and the value.
+func (parser *toml_parser) parse_key_value(table map[string]interface{}) ([]string, interface{}, error) {
+	keys, err := parser.parse_key()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	parser.skip_whitespace_and_comments(false)
+	if parser.is_eof() || parser.peek() != '=' {
+		return nil, nil, parser.error("expected \"=\" after key \"" + strings.Join(keys, ".") + "\"")
+	}
+	parser.pos += 1
+	parser.skip_whitespace_and_comments(false)
+
+	value, err := parser.parse_value()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	target_table, _, err := toml_get_or_create_table(table, keys[:len(keys)-1])
+	if err != nil {
+		return nil, nil, parser.error(err.Error())
+	}
+
+	var last_key = keys[len(keys)-1]
+	if _, exists := target_table[last_key]; exists {
+		return nil, nil, parser.error("key \"" + strings.Join(keys, ".") + "\" is defined more than once")
+	}
+	target_table[last_key] = value
+
+	return keys, value, nil
+}
+
+func (parser *toml_parser) parse_value() (interface{}, error) {
+	if parser.is_eof() {
+		return nil, parser.error("expected a value")
+	}
+
+	var char = parser.peek()
+	switch {
+	case char == '"' || char == '\'':
+		return parser.parse_string()
+	case char == '[':
+		return parser.parse_array()
+	case char == '{':
+		return parser.parse_inline_table()
+	case strings.HasPrefix(parser.text[parser.pos:], "true"):
+		parser.pos += len("true")
+		return true, nil
+	case strings.HasPrefix(parser.text[parser.pos:], "false"):
+		parser.pos += len("false")
+		return false, nil
+	default:
+		return parser.parse_number()
+	}
+}
+
+func (parser *toml_parser) parse_string() (string, error) {
+	if strings.HasPrefix(parser.text[parser.pos:], "\"\"\"") || strings.HasPrefix(parser.text[parser.pos:], "'''") {
+		return "", parser.error("multi-line strings are not supported")
+	}
+
+	var quote = parser.peek()
+	parser.pos += 1
+
+	var result strings.Builder
+	for {
+		if parser.is_eof() || parser.peek() == '\n' {
+			return "", parser.error("unterminated string")
+		}
+
+		var char = parser.peek()
+		parser.pos += 1
+
+		if char == quote {
+			return result.String(), nil
+		}
+
+		if char != '\\' || quote == '\'' {
+			result.WriteByte(char)
+			continue
+		}
+
+		// Escape sequence.
+		if parser.is_eof() {
+			return "", parser.error("unterminated string")
+		}
+		var escaped = parser.peek()
+		parser.pos += 1
+		switch escaped {
+		case '"', '\\':
+			result.WriteByte(escaped)
+		case 'n':
+			result.WriteByte('\n')
+		case 't':
+			result.WriteByte('\t')
+		case 'r':
+			result.WriteByte('\r')
+		case 'b':
+			result.WriteByte('\b')
+		case 'f':
+			result.WriteByte('\f')
+		case 'u', 'U':
+			var length = 4
+			if escaped == 'U' {
+				length = 8
+			}
+			if parser.pos+length > len(parser.text) {
+				return "", parser.error("invalid unicode escape sequence")
+			}
+			code, err := strconv.ParseUint(parser.text[parser.pos:parser.pos+length], 16, 32)
+			if err != nil {
+				return "", parser.error("invalid unicode escape sequence")
+			}
+			result.WriteRune(rune(code))
+			parser.pos += length
+		default:
+			return "", parser.error("unknown escape sequence \"\\" + string(escaped) + "\"")
+		}
+	}
+}
+
+func (parser *toml_parser) parse_array() ([]interface{}, error) {
+	parser.pos += 1 // skip '['
+	var array = []interface{}{}
+
+	for {
+		parser.skip_whitespace_and_comments(true)
+		if parser.is_eof() {
+			return nil, parser.error("unterminated array")
+		}
+		if parser.peek() == ']' {
+			parser.pos += 1
+			return array, nil
+		}
+
+		value, err := parser.parse_value()
+		if err != nil {
+			return nil, err
+		}
+		array = append(array, value)
+
+		parser.skip_whitespace_and_comments(true)
+		if parser.is_eof() {
+			return nil, parser.error("unterminated array")
+		}
+		if parser.peek() == ',' {
+			parser.pos += 1
+		} else if parser.peek() != ']' {
+			return nil, parser.error("expected \",\" or \"]\" in array")
+		}
+	}
+}
+
+func (parser *toml_parser) parse_inline_table() (map[string]interface{}, error) {
+	parser.pos += 1 // skip '{'
+	var table = map[string]interface{}{}
+
+	parser.skip_whitespace_and_comments(false)
+	if !parser.is_eof() && parser.peek() == '}' {
+		parser.pos += 1
+		return table, nil
+	}
+
+	for {
+		var _, _, err = parser.parse_key_value(table)
+		if err != nil {
+			return nil, err
+		}
+
+		parser.skip_whitespace_and_comments(false)
+		if parser.is_eof() {
+			return nil, parser.error("unterminated inline table")
+		}
+		if parser.peek() == '}' {
+			parser.pos += 1
+			return table, nil
+		}
+		if parser.peek() != ',' {
+			return nil, parser.error("expected \",\" or \"}\" in inline table")
+		}
+		parser.pos += 1
+	}
+}
+
+func (parser *toml_parser) parse_number() (interface{}, error) {
+	var start = parser.pos
+	for !parser.is_eof() {
+		var char = parser.peek()
+		if (char >= '0' && char <= '9') || char == '+' || char == '-' || char == '_' || char == '.' ||
+			char == 'e' || char == 'E' || char == 'x' || char == 'o' || char == 'b' ||
+			(char >= 'a' && char <= 'f') || (char >= 'A' && char <= 'F') {
+			parser.pos += 1
+		} else {
+			break
+		}
+	}
+
+	var raw = parser.text[start:parser.pos]
+	if raw == "" {
+		return nil, parser.error("expected a value")
+	}
+	var invalid_value = parser.error("invalid value \"" + raw + "\"")
+
+	// Hexadecimal, octal and binary integers (no sign is allowed).
+	if len(raw) > 2 && raw[0] == '0' && (raw[1] == 'x' || raw[1] == 'o' || raw[1] == 'b') {
+		var base = map[byte]int{'x': 16, 'o': 8, 'b': 2}[raw[1]]
+		var digits = raw[2:]
+		if !is_valid_toml_digits(digits, base) {
+			return nil, invalid_value
+		}
+		integer, err := strconv.ParseInt(strings.ReplaceAll(digits, "_", ""), base, 64)
+		if err != nil {
+			return nil, invalid_value
+		}
+		return integer, nil
+	}
+
+	var unsigned = strings.TrimLeft(raw, "+-")
+	if len(raw)-len(unsigned) > 1 {
+		return nil, invalid_value
+	}
+
+	// Split "1.5e-3" into "1", "5" and "-3".
+	var mantissa, exponent = unsigned, ""
+	var has_exponent = false
+	if index := strings.IndexAny(unsigned, "eE"); index != -1 {
+		mantissa, exponent = unsigned[:index], unsigned[index+1:]
+		has_exponent = true
+	}
+	var integer_part, fraction = mantissa, ""
+	var has_fraction = false
+	if index := strings.IndexByte(mantissa, '.'); index != -1 {
+		integer_part, fraction = mantissa[:index], mantissa[index+1:]
+		has_fraction = true
+	}
+
+	// Leading zeros are not allowed.
+	if !is_valid_toml_digits(integer_part, 10) || (len(integer_part) > 1 && integer_part[0] == '0') {
+		return nil, invalid_value
+	}
+	if has_fraction && !is_valid_toml_digits(fraction, 10) {
+		return nil, invalid_value
+	}
+	if has_exponent && len(exponent) > 0 && (exponent[0] == '+' || exponent[0] == '-') {
+		exponent = exponent[1:]
+	}
+	if has_exponent && !is_valid_toml_digits(exponent, 10) {
+		return nil, invalid_value
+	}
+
+	var text = strings.ReplaceAll(raw, "_", "")
+	if !has_fraction && !has_exponent {
+		integer, err := strconv.ParseInt(text, 10, 64)
+		if err != nil {
+			return nil, invalid_value
+		}
+		return integer, nil
+	}
+
+	float, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return nil, invalid_value
+	}
+	return float, nil
+}
+
+// Returns true if the text is a non-empty sequence of digits of the specified base where
+// each underscore is between two digits.
+func is_valid_toml_digits(text string, base int) bool {
+	if text == "" {
+		return false
+	}
+
+	for i := 0; i < len(text); i++ {
+		if text[i] == '_' {
+			if i == 0 || i == len(text)-1 || text[i-1] == '_' {
+				return false
+			}
+			continue
+		}
+		if _, err := strconv.ParseUint(text[i:i+1], base, 8); err != nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Appends keys to the path of a table (quoted keys separated by '.', tables of arrays of tables
+// are followed by their index, for example: "a"[1]."b").
+func toml_join_path(path string, keys []string) string {
+	for _, key := range keys {
+		if path != "" {
+			path += "."
+		}
+		path += strconv.Quote(key)
+	}
+	return path
+}
+
+// Returns a nested table (creating missing tables) and its path (see "toml_join_path"). If some key
+// points to an array of tables the last table of the array is used.
+func toml_get_or_create_table(table map[string]interface{}, keys []string) (map[string]interface{}, string, error) {
+	var current = table
+	var path = ""
+	for _, key := range keys {
+		path = toml_join_path(path, []string{key})
+
+		var value, exists = current[key]
+		if !exists {
+			var new_table = map[string]interface{}{}
+			current[key] = new_table
+			current = new_table
+			continue
+		}
+
+		switch typed := value.(type) {
+		case map[string]interface{}:
+			current = typed
+		case []interface{}:
+			if len(typed) == 0 {
+				return nil, "", fmt.Errorf("key \"%s\" is not a table", key)
+			}
+			last_table, ok := typed[len(typed)-1].(map[string]interface{})
+			if !ok {
+				return nil, "", fmt.Errorf("key \"%s\" is not a table", key)
+			}
+			current = last_table
+			path += "[" + strconv.Itoa(len(typed)-1) + "]"
+		default:
+			return nil, "", fmt.Errorf("key \"%s\" is not a table", key)
+		}
+	}
+
+	return current, path, nil
+}
+
+// Appends a new table to the array of tables specified by keys, returns the new table and its path.
+func toml_append_array_table(table map[string]interface{}, keys []string) (map[string]interface{}, string, error) {
+	parent, parent_path, err := toml_get_or_create_table(table, keys[:len(keys)-1])
+	if err != nil {
+		return nil, "", err
+	}
+
+	var last_key = keys[len(keys)-1]
+	var path = toml_join_path(parent_path, []string{last_key})
+	var new_table = map[string]interface{}{}
+
+	var value, exists = parent[last_key]
+	if !exists {
+		parent[last_key] = []interface{}{new_table}
+		return new_table, path + "[0]", nil
+	}
+
+	array, ok := value.([]interface{})
+	if !ok {
+		return nil, "", fmt.Errorf("key \"%s\" is not an array of tables", last_key)
+	}
+	parent[last_key] = append(array, new_table)
+
+	return new_table, path + "[" + strconv.Itoa(len(array)) + "]", nil
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTomlNumber(t *testing.T) {
+	var tests = []struct {
+		text     string
+		expected interface{} // nil if the value is invalid
+	}{
+		// Decimal integers.
+		{"0", int64(0)},
+		{"+0", int64(0)},
+		{"-0", int64(0)},
+		{"42", int64(42)},
+		{"+42", int64(42)},
+		{"-17", int64(-17)},
+		{"1_000", int64(1000)},
+		{"5_349_221", int64(5349221)},
+		{"9223372036854775807", int64(9223372036854775807)},
+		{"-9223372036854775808", int64(-9223372036854775808)},
+		{"010", nil},
+		{"-01", nil},
+		{"00", nil},
+		{"_1", nil},
+		{"1_", nil},
+		{"1__0", nil},
+		{"+-1", nil},
+		{"--1", nil},
+		{"9223372036854775808", nil},
+
+		// Hexadecimal, octal and binary integers.
+		{"0xff", int64(255)},
+		{"0xDEAD_BEEF", int64(0xDEADBEEF)},
+		{"0o755", int64(0755)},
+		{"0b1101", int64(13)},
+		{"0x_ff", nil},
+		{"0xff_", nil},
+		{"0o8", nil},
+		{"0b2", nil},
+		{"0x", nil},
+		{"+0xff", nil},
+		{"-0b1", nil},
+		{"0X1", nil},
+
+		// Floats.
+		{"1.5", 1.5},
+		{"+1.0", 1.0},
+		{"-0.01", -0.01},
+		{"0.5", 0.5},
+		{"5e+22", 5e+22},
+		{"1e06", 1e06},
+		{"-2E-2", -2e-2},
+		{"6.626e-34", 6.626e-34},
+		{"224_617.445_991", 224617.445991},
+		{"1.", nil},
+		{".5", nil},
+		{"01.5", nil},
+		{"1.e5", nil},
+		{"1e", nil},
+		{"1e+-5", nil},
+		{"1e_5", nil},
+		{"1_.5", nil},
+		{"0x1p-2", nil},
+	}
+
+	for _, test := range tests {
+		table, err := parse_toml("value = " + test.text + "\n")
+		if test.expected == nil {
+			if err == nil {
+				t.Errorf("%q: expected an error, got %v (%T)", test.text, table["value"], table["value"])
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.text, err)
+			continue
+		}
+		if table["value"] != test.expected {
+			t.Errorf("%q: expected %v (%T), got %v (%T)", test.text, test.expected, test.expected,
+				table["value"], table["value"])
+		}
+	}
+}
+
+func TestParseToml(t *testing.T) {
+	type table = map[string]interface{}
+	type array = []interface{}
+
+	var tests = []struct {
+		name     string
+		text     string
+		expected table // nil if the text is invalid
+	}{
+		// Tables.
+		{"table", "[a]\nx = 1\n", table{"a": table{"x": int64(1)}}},
+		{"nested table", "[a.b]\nx = 1\n[a]\ny = 2\n", table{"a": table{"b": table{"x": int64(1)}, "y": int64(2)}}},
+		{"quoted table name", "[\"a.b\"]\nx = 1\n", table{"a.b": table{"x": int64(1)}}},
+		{"dotted keys", "a.b.c = 1\na.d = 2\n", table{"a": table{"b": table{"c": int64(1)}, "d": int64(2)}}},
+		{"empty table", "[a]\n", table{"a": table{}}},
+		{"unclosed table header", "[a\n", nil},
+		{"table over value", "a = 1\n[a]\n", nil},
+
+		// Arrays of tables.
+		{"array of tables", "[[a]]\nx = 1\n[[a]]\nx = 2\n", table{"a": array{table{"x": int64(1)}, table{"x": int64(2)}}}},
+		{"subtable of array of tables", "[[a]]\n[a.b]\nx = 1\n[[a]]\n[a.b]\nx = 2\n",
+			table{"a": array{table{"b": table{"x": int64(1)}}, table{"b": table{"x": int64(2)}}}}},
+		{"array of tables over table", "[a]\n[[a]]\n", nil},
+		{"array of tables over array", "a = [1]\n[[a]]\n", nil},
+
+		// Inline tables and arrays.
+		{"inline table", "a = { x = 1, y.z = \"s\" }\n", table{"a": table{"x": int64(1), "y": table{"z": "s"}}}},
+		{"empty inline table", "a = {}\n", table{"a": table{}}},
+		{"nested inline table", "a = { b = { c = true } }\n", table{"a": table{"b": table{"c": true}}}},
+		{"array", "a = [1, 2.5, \"s\", false]\n", table{"a": array{int64(1), 2.5, "s", false}}},
+		{"empty array", "a = []\n", table{"a": array{}}},
+		{"nested arrays", "a = [[1, 2], [\"s\"]]\n", table{"a": array{array{int64(1), int64(2)}, array{"s"}}}},
+		{"multi-line array", "a = [\n  1,\n  2, # comment\n]\n", table{"a": array{int64(1), int64(2)}}},
+		{"array of inline tables", "a = [{ x = 1 }, { x = 2 }]\n", table{"a": array{table{"x": int64(1)}, table{"x": int64(2)}}}},
+		{"unterminated array", "a = [1, 2\n", nil},
+		{"missing comma in array", "a = [1 2]\n", nil},
+		{"unterminated inline table", "a = { x = 1\n", nil},
+		{"table over inline table", "a = { x = 1 }\n[a]\n", nil},
+		{"subtable of inline table", "a = { x = 1 }\n[a.b]\n", nil},
+
+		// Strings.
+		{"basic string", "a = \"text\"\n", table{"a": "text"}},
+		{"basic string escapes", "a = \"\\\"q\\\" \\\\ \\t\\n\\r\\b\\f\"\n", table{"a": "\"q\" \\ \t\n\r\b\f"}},
+		{"unicode escapes", "a = \"\\u00e9\\U0001F600\"\n", table{"a": "é😀"}},
+		{"literal string", "a = 'C:\\path\\no\\escapes'\n", table{"a": "C:\\path\\no\\escapes"}},
+		{"literal string with double quotes", "a = 'say \"hi\"'\n", table{"a": "say \"hi\""}},
+		{"hash in string", "a = \"# not a comment\"\n", table{"a": "# not a comment"}},
+		{"unknown escape", "a = \"\\q\"\n", nil},
+		{"invalid unicode escape", "a = \"\\u00g0\"\n", nil},
+		{"unterminated string", "a = \"text\n", nil},
+		{"multi-line string", "a = \"\"\"text\"\"\"\n", nil},
+
+		// Comments.
+		{"comments", "# comment\na = 1 # comment\n[b] # comment\n# comment\nc = 2\n",
+			table{"a": int64(1), "b": table{"c": int64(2)}}},
+		{"comment without newline at the end", "a = 1 # comment", table{"a": int64(1)}},
+		{"only comments", "# comment\n\n# comment\n", table{}},
+		{"windows line endings", "a = 1\r\n[b]\r\nc = 2\r\n", table{"a": int64(1), "b": table{"c": int64(2)}}},
+
+		// Duplicates.
+		{"duplicate key", "a = 1\na = 2\n", nil},
+		{"duplicate key in table", "[t]\na = 1\na = 2\n", nil},
+		{"duplicate dotted key", "a.b = 1\na.b = 2\n", nil},
+		{"duplicate key in inline table", "a = { x = 1, x = 2 }\n", nil},
+		{"duplicate table", "[a]\nx = 1\n[a]\ny = 2\n", nil},
+		{"duplicate nested table", "[a.b]\n[a]\n[a.b]\n", nil},
+		{"duplicate table with quoted name", "[a]\n[\"a\"]\n", nil},
+		{"duplicate subtable of array of tables", "[[a]]\n[a.b]\n[a.b]\n", nil},
+
+		// Syntax errors.
+		{"missing value", "a =\n", nil},
+		{"missing equals sign", "a 1\n", nil},
+		{"two values on a line", "a = 1 b = 2\n", nil},
+	}
+
+	for _, test := range tests {
+		result, err := parse_toml(test.text)
+		if test.expected == nil {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %v", test.name, result)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, result)
+		}
+	}
+}
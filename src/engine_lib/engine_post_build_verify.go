@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"common"
+	"debug/pe"
+	"encoding/binary"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Compares hashes/file versions of libraries in the build directory with the values from the config.
+func verify_runtime_libs(config *post_build_config, build_directory string) {
+	var mismatch_count = 0
+	var checked_count = 0
+
+	for _, entry := range config.verify {
+		if !is_entry_enabled(entry.platforms, nil, false) {
+			continue
+		}
+
+		var path = filepath.Join(build_directory, entry.file)
+		if dry_run {
+			common.Log_info("[dry run] verify", path)
+			continue
+		}
+		checked_count += 1
+
+		if entry.sha256 != "" {
+			var actual_sha256 = get_file_sha256(path)
+			if !strings.EqualFold(actual_sha256, entry.sha256) {
+				common.Log_error("SHA-256 of", path, "is", actual_sha256, "but expected", entry.sha256)
+				mismatch_count += 1
+				continue
+			}
+		}
+
+		if entry.version != "" {
+			version, err := get_pe_file_version(path)
+			if err != nil {
+				common.Log_error("failed to read file version of", path, "error:", err)
+				mismatch_count += 1
+				continue
+			}
+			if version != entry.version {
+				common.Log_error("file version of", path, "is", version, "but expected", entry.version)
+				mismatch_count += 1
+				continue
+			}
+		}
+
+		common.Log_verbose(path, "matches the expected version")
+	}
+
+	if mismatch_count != 0 {
+		common.Log_fatal(mismatch_count, "runtime library(-ies) don't match the expected versions "+
+			"(a stale library was probably copied, try cleaning 'ext' and the build directory)")
+	}
+
+	common.Log_success("verified", checked_count, "runtime library(-ies)")
+}
+
+// Returns file version (in the form "major.minor.build.revision") from the version resource of a PE file.
+func get_pe_file_version(path string) (string, error) {
+	file, err := pe.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	var resource_section = file.Section(".rsrc")
+	if resource_section == nil {
+		return "", fmt.Errorf("file has no resources")
+	}
+
+	data, err := resource_section.Data()
+	if err != nil {
+		return "", err
+	}
+
+	// Look for VS_FIXEDFILEINFO by its signature.
+	var signature = []byte{0xBD, 0x04, 0xEF, 0xFE}
+	var offset = bytes.Index(data, signature)
+	if offset < 0 || offset+16 > len(data) {
+		return "", fmt.Errorf("file has no version resource")
+	}
+
+	var version_ms = binary.LittleEndian.Uint32(data[offset+8:])
+	var version_ls = binary.LittleEndian.Uint32(data[offset+12:])
+
+	return fmt.Sprintf("%d.%d.%d.%d", version_ms>>16, version_ms&0xFFFF, version_ls>>16, version_ls&0xFFFF), nil
+}
@@ -1,3 +1,7 @@
 module engine_post_build
 
 go 1.18
+
+require common v0.0.0
+
+replace common => ../.scripts/common
@@ -7,6 +7,7 @@
 
 // Custom.
 #include "misc/Globals.h"
+#include "build_info.h"
 
 // External.
 #include "spdlog/spdlog.h"
@@ -91,6 +92,15 @@ namespace ne {
         pSpdLogger =
             std::unique_ptr<spdlog::logger>(new spdlog::logger("MainLogger", {consoleSink, fileSink}));
         pSpdLogger->set_pattern("[%H:%M:%S] [%^%l%$] %v");
+
+        // Log build information (generated by the post build script).
+        info(
+            std::format(
+                "engine version: {}, commit: {}, branch: {}",
+                sBuildVersion.empty() ? "unknown" : sBuildVersion,
+                sBuildCommit,
+                sBuildBranch),
+            "");
     }
 
     std::string Logger::getDateTime() {
@@ -12,7 +12,7 @@
 #include "catch2/catch_session.hpp"
 #include "misc/Globals.h"
 
-int main() {
+int main(int argc, char* argv[]) {
 // Enable run-time memory check for debug builds.
 #if defined(DEBUG) && defined(WIN32)
     _CrtSetDbgFlag(_CRTDBG_ALLOC_MEM_DF | _CRTDBG_LEAK_CHECK_DF);
@@ -34,5 +34,5 @@ int main() {
         std::filesystem::remove_all(basePath);
     }
 
-    Catch::Session().run();
+    return Catch::Session().run(argc, argv);
 }
\ No newline at end of file